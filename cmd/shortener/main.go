@@ -57,6 +57,7 @@ func main() {
 		log.Fatalf("App init err: %v err", err)
 	}
 	defer app.Repository.CloseDB()
+	defer app.GeoIP.Close()
 
 	server := http.Server{
 		Addr:    config.ServerAdr,
@@ -69,7 +70,9 @@ func main() {
 	var grpcServer *grpc.Server
 	interceptors := grpc.ChainUnaryInterceptor(
 		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-			if info.FullMethod == "/proto.ShortenerService/GetUserURLs" {
+			switch info.FullMethod {
+			case "/proto.ShortenerService/GetUserURLs", "/proto.ShortenerService/GetUserTags", "/proto.ShortenerService/UpdateTags",
+				"/proto.ShortenerService/UpdateVariantWeights", "/proto.ShortenerService/GetLinkStats":
 				return cookie.OnlyAuthorizedMiddlewareGRPC(ctx, req, info, handler)
 			}
 			return handler(ctx, req)
@@ -84,6 +87,7 @@ func main() {
 			return cookie.MetadataCheckMiddlewareGRPC(ctx, req, info, handler, app)
 		},
 	)
+	streamInterceptors := grpc.ChainStreamInterceptor(cookie.OnlyAuthorizedStreamGRPC)
 	if config.EnableHTTPS {
 		certFile := fmt.Sprintf("%s%vcert.pem", config.SSLCertPath, os.PathSeparator)
 		keyFile := fmt.Sprintf("%s%vprivate.key", config.SSLCertPath, os.PathSeparator)
@@ -92,15 +96,23 @@ func main() {
 			app.Logger.Sugar().Fatalf("failed to load server key pair: %v", err)
 		}
 		creds := credentials.NewServerTLSFromCert(&cert)
-		grpcServer = grpc.NewServer(grpc.Creds(creds), interceptors)
+		grpcServer = grpc.NewServer(grpc.Creds(creds), interceptors, streamInterceptors)
 	} else {
-		grpcServer = grpc.NewServer(interceptors)
+		grpcServer = grpc.NewServer(interceptors, streamInterceptors)
 	}
 
 	proto.RegisterShortenerServiceServer(grpcServer, grpcShortener.NewShortenerService(app))
 	reflection.Register(grpcServer)
 
-	wg, errChan := app.Repository.DeleteManager.SubcribeOnTask(mainContext)
+	clicksWG := app.Clicks.Run(mainContext)
+	healthWG := app.Health.Run(mainContext)
+	webhooksWG := app.Webhooks.Run(mainContext)
+
+	// The delete manager stops on its own context: its last batch notifies the webhooks,
+	// so it has to finish before the rest of the app stops.
+	deleteContext, deleteCancel := context.WithCancel(context.Background())
+	defer deleteCancel()
+	wg, errChan := app.Repository.DeleteManager.SubcribeOnTask(deleteContext)
 	go func() {
 		defer close(app.Repository.DeleteManager.TaskChan)
 		wg.Wait()
@@ -144,10 +156,21 @@ func main() {
 		app.Logger.Sugar().Info("Shutting down server")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		// Click streams never end by themselves, closing them lets the servers drain.
+		app.Events.Close()
 		if err := server.Shutdown(ctx); err != nil {
 			app.Logger.Sugar().Fatal("Server forced to shutdown:", err)
 		}
 		grpcServer.GracefulStop()
+		deleteCancel()
+		for err := range errChan {
+			app.Logger.Sugar().Errorf("Delete url err: %v", err)
+		}
+		wg.Wait()
+		MainCancel()
+		clicksWG.Wait()
+		healthWG.Wait()
+		webhooksWG.Wait()
 		app.Logger.Sugar().Info("Server exiting")
 	}
 
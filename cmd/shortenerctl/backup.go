@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/backup"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/configs"
+	"go.uber.org/zap"
+)
+
+// migrate copies every link of the configured storage to another one.
+func migrate(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	toDB := flags.String("to-db", "", "DSN of the target PostgreSQL database")
+	toFile := flags.String("to-file", "", "path of the target storage file")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if (*toDB == "") == (*toFile == "") || flags.NArg() != 0 {
+		return fmt.Errorf("%w: migrate takes either -to-db or -to-file", errUsage)
+	}
+
+	target, err := app.CreateStorage(ctx, configs.Config{
+		DBAddress:       *toDB,
+		FileStoragePath: *toFile,
+		DedupScope:      env.config.DedupScope,
+	}, zap.NewNop())
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	result, err := backup.Copy(ctx, env.store, target)
+	printResult(env.out, result)
+	return err
+}
+
+// backupArchive writes every link to an archive file, or to stdout if the path is "-".
+func backupArchive(ctx context.Context, env *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: backup takes one archive path", errUsage)
+	}
+
+	path := args[0]
+	if path == "-" {
+		_, err := backup.Write(ctx, env.store, env.out)
+		return err
+	}
+
+	// The archive is written to a temporary file first, so a failed backup never replaces a good one.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	footer, err := backup.Write(ctx, env.store, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "records: %d\nurls:    %d\nusers:   %d\n", footer.Records, footer.Stats.URLs, footer.Stats.Users)
+	return nil
+}
+
+// restoreBackup loads an archive into the configured storage, which must be empty.
+func restoreBackup(ctx context.Context, env *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: restore-backup takes one archive path", errUsage)
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result, err := backup.Restore(ctx, r, env.store)
+	if errors.Is(err, backup.ErrNotEmpty) {
+		return fmt.Errorf("%w, run clean -force first", err)
+	}
+	printResult(env.out, result)
+	return err
+}
+
+// printResult prints the number of copied records.
+func printResult(out io.Writer, result backup.Result) {
+	fmt.Fprintf(out, "records: %d\ncreated: %d\nskipped: %d\n", result.Records, result.Created, result.Skipped)
+}
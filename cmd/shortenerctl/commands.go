@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/configs"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/file"
+)
+
+// errUsage is returned when a command is called with wrong arguments.
+var errUsage = errors.New("invalid usage")
+
+// env is what the commands work with.
+type env struct {
+	config configs.Config
+	store  storage.Storage
+	out    io.Writer
+}
+
+// command is a subcommand of shortenerctl.
+type command struct {
+	args string
+	help string
+	run  func(ctx context.Context, env *env, args []string) error
+}
+
+// commands are the supported subcommands by name.
+var commands = map[string]command{
+	"inspect": {
+		args: "<id>",
+		help: "show a short link",
+		run:  inspect,
+	},
+	"list": {
+		args: "[-tag t] <user id>",
+		help: "list the links of a user",
+		run:  list,
+	},
+	"delete": {
+		args: "<id>...",
+		help: "delete links of any user",
+		run:  forceDelete,
+	},
+	"restore": {
+		args: "<id>...",
+		help: "restore deleted links",
+		run:  restore,
+	},
+	"stats": {
+		args: "",
+		help: "print the number of links and users",
+		run:  stats,
+	},
+	"compact": {
+		args: "",
+		help: "drop deleted and repeated records from file storage",
+		run:  compact,
+	},
+	"clean": {
+		args: "-force",
+		help: "remove every link",
+		run:  clean,
+	},
+	"migrate": {
+		args: "-to-db dsn | -to-file path",
+		help: "copy every link to an empty storage and verify the copy",
+		run:  migrate,
+	},
+	"backup": {
+		args: "<archive | ->",
+		help: "write every link to a backup archive",
+		run:  backupArchive,
+	},
+	"restore-backup": {
+		args: "<archive | ->",
+		help: "load a backup archive into an empty storage and verify it",
+		run:  restoreBackup,
+	},
+}
+
+// commandNames returns the names of the commands in alphabetical order.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run runs the command named by the first argument.
+func run(ctx context.Context, env *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+	return cmd.run(ctx, env, args[1:])
+}
+
+// inspect prints every stored field of a short link.
+func inspect(ctx context.Context, env *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: inspect takes one id", errUsage)
+	}
+
+	savedURL, err := env.store.Get(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], storage.ErrURLNotFound)
+	}
+
+	w := tabwriter.NewWriter(env.out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "short_url:\t%s\n", savedURL.ShortURL)
+	fmt.Fprintf(w, "original_url:\t%s\n", savedURL.OriginalURL)
+	fmt.Fprintf(w, "user_id:\t%s\n", savedURL.UserID)
+	fmt.Fprintf(w, "deleted:\t%t\n", savedURL.IsDeleted)
+	if len(savedURL.Tags) > 0 {
+		fmt.Fprintf(w, "tags:\t%s\n", strings.Join(savedURL.Tags, ", "))
+	}
+	return w.Flush()
+}
+
+// list prints the links of a user as a table.
+func list(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	tag := flags.String("tag", "", "only links with the tag")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: list takes one user id", errUsage)
+	}
+
+	w := tabwriter.NewWriter(env.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHORT URL\tORIGINAL URL\tDELETED\tTAGS")
+	err := env.store.ExportByUser(ctx, flags.Arg(0), storage.URLFilter{Tag: *tag}, func(savedURL storage.SavedURL) error {
+		_, err := fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", savedURL.ShortURL, savedURL.OriginalURL, savedURL.IsDeleted, strings.Join(savedURL.Tags, ","))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// forceDelete deletes links on behalf of their owners.
+func forceDelete(ctx context.Context, env *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: delete takes at least one id", errUsage)
+	}
+
+	return forEachID(args, env.out, func(id string) error {
+		savedURL, err := env.store.Get(ctx, id)
+		if err != nil {
+			return storage.ErrURLNotFound
+		}
+		_, err = env.store.Delete(ctx, []models.DeleteTask{{URL: id, UserID: savedURL.UserID}})
+		return err
+	}, "deleted")
+}
+
+// restore restores deleted links.
+func restore(ctx context.Context, env *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: restore takes at least one id", errUsage)
+	}
+
+	return forEachID(args, env.out, func(id string) error {
+		return env.store.Restore(ctx, id)
+	}, "restored")
+}
+
+// forEachID applies fn to every id and reports the result of each one.
+// It goes on after failures and returns an error if any id failed.
+func forEachID(ids []string, out io.Writer, fn func(id string) error, done string) error {
+	failed := 0
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			fmt.Fprintf(out, "%s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s\n", id, done)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links failed", failed, len(ids))
+	}
+	return nil
+}
+
+// stats prints the number of links and users.
+func stats(ctx context.Context, env *env, args []string) error {
+	stats, err := env.store.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "urls:  %d\nusers: %d\n", stats.URLs, stats.Users)
+	return nil
+}
+
+// compact rewrites the storage file without deleted and repeated records.
+func compact(ctx context.Context, env *env, args []string) error {
+	fileStorage, ok := env.store.(*file.FileStorage)
+	if !ok {
+		return errors.New("compact is only supported for file storage")
+	}
+
+	dropped, err := fileStorage.Compact(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "dropped %d records\n", dropped)
+	return nil
+}
+
+// clean removes every link. It requires -force.
+func clean(ctx context.Context, env *env, args []string) error {
+	flags := flag.NewFlagSet("clean", flag.ContinueOnError)
+	force := flags.Bool("force", false, "confirm removing every link")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if !*force {
+		return errors.New("clean removes every link, run it with -force")
+	}
+
+	if err := env.store.Clean(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "storage cleaned")
+	return nil
+}
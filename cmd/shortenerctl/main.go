@@ -0,0 +1,75 @@
+// Command shortenerctl manages the shortener data without a running server.
+//
+// It reads the same flags, environment variables and JSON config as the server
+// and works with the configured PostgreSQL or file storage:
+//
+//	shortenerctl [config flags] <command> [arguments]
+//
+// Run it without a command to see the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/configs"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"go.uber.org/zap"
+)
+
+func main() {
+	flag.Usage = usage
+
+	config, err := configs.ParseFlags()
+	if err != nil && !errors.Is(err, configs.ErrParseConfigJson) {
+		log.Fatalf("Parse flags err: %v\n", err)
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := app.CreateStorage(ctx, *config, zap.NewNop())
+	if err != nil {
+		log.Fatalf("Storage init err: %v\n", err)
+	}
+	defer store.Close()
+
+	if _, ok := store.(*memory.MemoryStorage); ok {
+		log.Fatalln("No persistent storage is configured, set -d or -f")
+	}
+
+	if err := run(ctx, &env{config: *config, store: store, out: os.Stdout}, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// usage prints the commands and the config flags.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [config flags] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, name := range commandNames() {
+		fmt.Fprintf(w, "  %s %s\t%s\n", name, commands[name].args, commands[name].help)
+	}
+	w.Flush()
+	fmt.Fprintln(out, "\nConfig flags:")
+	flag.PrintDefaults()
+}
@@ -3,29 +3,69 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/clicks"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/clientip"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/configs"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/deletemanager"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/domains"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/events"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/geoip"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/health"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/importer"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/logger"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/repository"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/screening"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/cache"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/file"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/sql"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/unfurl"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlnormalizer"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/usermanager"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/webhooks"
 	"go.uber.org/zap"
 )
 
+const (
+	// screeningReloadInterval is how often block and allow list files are checked for changes.
+	screeningReloadInterval = 5 * time.Second
+	// reputationTimeout limits a single request to the reputation service.
+	reputationTimeout = 2 * time.Second
+	// unfurlTimeout limits fetching the preview tags of a destination, a crawler waits for it.
+	unfurlTimeout = 3 * time.Second
+	// unfurlCacheTTL and unfurlCacheSize bound the cache of fetched preview tags.
+	unfurlCacheTTL  = time.Hour
+	unfurlCacheSize = 1000
+)
+
 // App represents the main application structure.
-// It includes fields for storage, logger, context, user manager, delete manager, and redirect host.
+// It includes fields for storage, logger, context, user manager, delete manager, import jobs, short domains, redirect host,
+// the GeoIP database with the resolver of client addresses used by geo-targeting, the click recorder,
+// the unfurler that serves link previews to crawlers, the checker of link destinations, nil if checks are disabled,
+// the dispatcher of the webhooks of link events and the bus streaming the clicks to their watchers.
 type App struct {
-	Repository    *repository.Repository
-	Logger        *zap.Logger
-	context       context.Context
-	UserManager   *usermanager.UserManager
-	RedirectHost  string
-	TrustedSubnet string
+	Repository     *repository.Repository
+	Logger         *zap.Logger
+	context        context.Context
+	UserManager    *usermanager.UserManager
+	Imports        *importer.Manager
+	Domains        *domains.Registry
+	RedirectHost   string
+	RedirectStatus int
+	TrustedSubnet  string
+	GeoIP          *geoip.Reader
+	ClientIP       *clientip.Resolver
+	Clicks         *clicks.Recorder
+	Unfurler       *unfurl.Unfurler
+	Health         *health.Checker
+	Webhooks       *webhooks.Dispatcher
+	Events         *events.Bus
 }
 
 // CreateApp creates a new instance of the App object.
@@ -36,8 +76,51 @@ func CreateApp(ctx context.Context, conf configs.Config) (*App, error) {
 		return nil, fmt.Errorf("error creating App %v", err)
 	}
 
-	storage, err := createStorage(ctx, conf, logger)
+	storage, err := CreateStorage(ctx, conf, logger)
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
+
+	storage, err = createCache(ctx, conf, storage, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
+
+	screener, err := createScreener(ctx, conf, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
+
+	domains, err := domains.NewRegistry(conf.RedirectHost, conf.Domains)
+	if err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
+
+	redirectStatus := conf.RedirectStatus
+	if redirectStatus == 0 {
+		redirectStatus = http.StatusTemporaryRedirect
+	}
+	if err := repository.ValidateRedirectStatus(redirectStatus); err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
 
+	clientIP, err := clientip.NewResolver(conf.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("error creating App %v", err)
+	}
+
+	var geoIP *geoip.Reader
+	if conf.GeoIPDBPath != "" {
+		geoIP, err = geoip.Open(conf.GeoIPDBPath)
+		if err != nil {
+			return nil, fmt.Errorf("error creating App %v", err)
+		}
+	}
+
+	dispatcher := webhooks.NewDispatcher(storage, webhooks.Options{Logger: logger})
+
+	healthChecker, err := createHealthChecker(conf, storage, logger, brokenLinksNotifier(domains, dispatcher, logger))
 	if err != nil {
 		return nil, fmt.Errorf("error creating App %v", err)
 	}
@@ -45,32 +128,220 @@ func CreateApp(ctx context.Context, conf configs.Config) (*App, error) {
 	usermanager := &usermanager.UserManager{Storage: storage}
 
 	deletemanager := deletemanager.NewDeleteManager(storage)
+	deletemanager.OnDelete = deletedLinksNotifier(domains, dispatcher)
+
+	normalizer := urlnormalizer.NewNormalizer(conf.AllowedSchemes, conf.StripTracking)
+
+	repository := repository.NewRepository(storage, deletemanager, domains, normalizer, screener)
+	repository.Webhooks = dispatcher
 
 	return &App{
-		Repository:    repository.NewRepository(storage, deletemanager, conf.RedirectHost),
-		Logger:        logger,
-		context:       ctx,
-		UserManager:   usermanager,
-		RedirectHost:  conf.RedirectHost,
-		TrustedSubnet: conf.TrustedSubnet,
+		Repository:     repository,
+		Logger:         logger,
+		context:        ctx,
+		UserManager:    usermanager,
+		Imports:        importer.NewManager(),
+		Domains:        domains,
+		RedirectHost:   conf.RedirectHost,
+		RedirectStatus: redirectStatus,
+		TrustedSubnet:  conf.TrustedSubnet,
+		GeoIP:          geoIP,
+		ClientIP:       clientIP,
+		Clicks:         clicks.NewRecorder(storage, logger, conf.VisitorSecret),
+		Unfurler:       unfurl.NewUnfurler(unfurl.NewHTTPFetcher(unfurlTimeout), unfurlCacheTTL, unfurlCacheSize),
+		Health:         healthChecker,
+		Webhooks:       dispatcher,
+		Events:         events.NewBus(),
 	}, nil
 }
 
-// createStorage initializes the storage based on the configuration.
-func createStorage(ctx context.Context, conf configs.Config, logger *zap.Logger) (storage.Storage, error) {
-	var storage storage.Storage
-	var err error
+// RecordClick records a redirect to the link, sends it to the webhooks and streams it to the watchers of its owner.
+// The country of the visit is looked up in the GeoIP database if it isn't known.
+func (a *App) RecordClick(savedURL storage.SavedURL, variantID string, visit clicks.Visit) {
+	if visit.Country == "" {
+		visit.Country = a.GeoIP.Country(visit.IP)
+	}
+	a.Clicks.RecordVisit(storage.Click{ShortURL: savedURL.ShortURL, Variant: variantID}, visit)
+	shortURL := a.Repository.ShortURL(savedURL.ShortURL)
+	a.Webhooks.Queue(savedURL.UserID, webhooks.Event{
+		Type:    webhooks.EventClicked,
+		Link:    webhooks.Link{ShortURL: shortURL, OriginalURL: savedURL.OriginalURL},
+		Variant: variantID,
+	})
+	a.Events.Publish(events.Click{
+		ShortURL:    shortURL,
+		UserID:      savedURL.UserID,
+		OriginalURL: savedURL.OriginalURL,
+		Variant:     variantID,
+		Time:        time.Now(),
+	})
+}
+
+// deletedLinksNotifier returns the callback that sends the events of deleted links to the webhooks.
+// It gets only the links a delete changed, so links of other users and links deleted before are not reported.
+func deletedLinksNotifier(domains *domains.Registry, dispatcher *webhooks.Dispatcher) func(context.Context, []storage.SavedURL) {
+	return func(ctx context.Context, deleted []storage.SavedURL) {
+		for _, savedURL := range deleted {
+			if !dispatcher.Subscribed(ctx, savedURL.UserID, webhooks.EventDeleted) {
+				continue
+			}
+			dispatcher.Emit(ctx, savedURL.UserID, webhooks.Event{
+				Type: webhooks.EventDeleted,
+				Link: webhooks.Link{ShortURL: domains.ShortURL(savedURL.ShortURL), OriginalURL: savedURL.OriginalURL, Tags: savedURL.Tags},
+			})
+		}
+	}
+}
+
+// brokenLinksNotifier returns the callback that logs the links the health checker found broken
+// and sends their events to the webhooks. It is called once when a link breaks, not on every check.
+func brokenLinksNotifier(domains *domains.Registry, dispatcher *webhooks.Dispatcher, logger *zap.Logger) func(health.Event) {
+	return func(event health.Event) {
+		logger.Sugar().Infow("link is broken", "short_url", event.ShortURL, "user_id", event.UserID,
+			"original_url", event.OriginalURL, "status", event.Health.Status, "error", event.Health.Error)
+
+		ctx := context.Background()
+		if !dispatcher.Subscribed(ctx, event.UserID, webhooks.EventBroken) {
+			return
+		}
+		linkHealth := event.Health
+		err := dispatcher.Emit(ctx, event.UserID, webhooks.Event{
+			Type:   webhooks.EventBroken,
+			Link:   webhooks.Link{ShortURL: domains.ShortURL(event.ShortURL), OriginalURL: event.OriginalURL},
+			Health: &linkHealth,
+		})
+		if err != nil {
+			logger.Sugar().Errorf("failed to send the event of a broken link: %v", err)
+		}
+	}
+}
+
+// createHealthChecker creates the checker of link destinations, or nil if the checks are disabled.
+// onBreak is called for every link that broke.
+func createHealthChecker(conf configs.Config, store storage.Storage, logger *zap.Logger, onBreak func(health.Event)) (*health.Checker, error) {
+	if conf.HealthInterval == "" || conf.HealthInterval == "0" {
+		return nil, nil
+	}
+	interval, err := time.ParseDuration(conf.HealthInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health check interval: %w", err)
+	}
+	if interval <= 0 {
+		return nil, nil
+	}
+
+	var hostDelay time.Duration
+	if conf.HealthHostDelay != "" {
+		hostDelay, err = time.ParseDuration(conf.HealthHostDelay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check host delay: %w", err)
+		}
+	}
+
+	return health.NewChecker(store, health.Options{
+		Interval:    interval,
+		Concurrency: conf.HealthConcurrency,
+		HostDelay:   hostDelay,
+		OnBreak:     onBreak,
+		Logger:      logger,
+	}), nil
+}
+
+// CreateStorage initializes the storage based on the configuration.
+func CreateStorage(ctx context.Context, conf configs.Config, logger *zap.Logger) (storage.Storage, error) {
+	var store storage.Storage
+
+	dedupScope, err := storage.ParseDedupScope(conf.DedupScope)
+	if err != nil {
+		return nil, err
+	}
 
 	if conf.DBAddress != "" {
-		storage, err = sql.NewPostgresStorage(ctx, conf.DBAddress, logger)
+		store, err = sql.NewPostgresStorage(ctx, conf.DBAddress, logger, dedupScope)
 		if err != nil {
 			return nil, err
 		}
 	} else if path := conf.FileStoragePath; path != "" {
-		storage = &file.FileStorage{FilePath: path}
+		store = &file.FileStorage{FilePath: path, DedupScope: dedupScope}
 	} else {
-		storage = &memory.MemoryStorage{}
+		store = &memory.MemoryStorage{DedupScope: dedupScope}
+	}
+	store.Init(ctx)
+	return store, nil
+}
+
+// createCache wraps the storage with the redirect cache unless it is disabled.
+func createCache(ctx context.Context, conf configs.Config, store storage.Storage, logger *zap.Logger) (storage.Storage, error) {
+	if conf.CacheSize <= 0 {
+		return store, nil
 	}
-	storage.Init(ctx)
-	return storage, nil
+
+	ttl, err := time.ParseDuration(conf.CacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache ttl: %w", err)
+	}
+	missTTL, err := time.ParseDuration(conf.CacheMissTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache miss ttl: %w", err)
+	}
+
+	backend, err := createCacheBackend(ctx, conf, store)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.NewCachedStorage(ctx, store, cache.Options{
+		Size:    conf.CacheSize,
+		TTL:     ttl,
+		MissTTL: missTTL,
+		Backend: backend,
+		Logger:  logger,
+	})
+}
+
+// createCacheBackend creates the cache shared by the instances, or nil if none is configured.
+// The postgres backend keeps it in the database of the storage.
+func createCacheBackend(ctx context.Context, conf configs.Config, store storage.Storage) (cache.Backend, error) {
+	switch conf.CacheBackend {
+	case "":
+		return nil, nil
+	case "postgres":
+		postgres, ok := store.(*sql.PostgresStorage)
+		if !ok {
+			return nil, errors.New("the postgres cache backend needs the database storage")
+		}
+		return postgres.CacheBackend(ctx)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, expected postgres", conf.CacheBackend)
+	}
+}
+
+// createScreener initializes URL screening based on the configuration.
+// Block and allow lists are reloaded when their files change.
+func createScreener(ctx context.Context, conf configs.Config, logger *zap.Logger) (*screening.Screener, error) {
+	var allowlist, blocklist *screening.List
+	var checkers []screening.Checker
+	var err error
+
+	if conf.AllowlistPath != "" {
+		allowlist, err = screening.LoadList(conf.AllowlistPath, logger)
+		if err != nil {
+			return nil, err
+		}
+		go allowlist.Watch(ctx, screeningReloadInterval)
+	}
+
+	if conf.BlocklistPath != "" {
+		blocklist, err = screening.LoadList(conf.BlocklistPath, logger)
+		if err != nil {
+			return nil, err
+		}
+		go blocklist.Watch(ctx, screeningReloadInterval)
+	}
+
+	if conf.ReputationURL != "" {
+		checkers = append(checkers, screening.NewReputationChecker(conf.ReputationURL, reputationTimeout))
+	}
+
+	return screening.NewScreener(allowlist, blocklist, logger, checkers...), nil
 }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/domains"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/health"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/webhooks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBrokenLinksNotifier(t *testing.T) {
+	var broken atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	store := &memory.MemoryStorage{DedupScope: storage.DedupNone}
+	assert.NoError(t, store.Init(ctx))
+	_, err := store.Save(ctx, *storage.NewSavedURL("abc", server.URL+"/page", "alice"))
+	assert.NoError(t, err)
+
+	registry, err := domains.NewRegistry("http://localhost:8080", "")
+	assert.NoError(t, err)
+	dispatcher := webhooks.NewDispatcher(store, webhooks.Options{PollInterval: time.Hour})
+	webhook, err := dispatcher.Register(ctx, "alice", "https://crm.example.com/hooks", []string{webhooks.EventBroken})
+	assert.NoError(t, err)
+
+	checker := health.NewChecker(store, health.Options{
+		Interval: time.Nanosecond,
+		Client:   server.Client(),
+		OnBreak:  brokenLinksNotifier(registry, dispatcher, zap.NewNop()),
+	})
+
+	assert.NoError(t, checker.CheckAll(ctx))
+	deliveries, err := dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries, "a healthy link sends no event")
+
+	broken.Store(true)
+	assert.NoError(t, checker.CheckAll(ctx))
+	assert.NoError(t, checker.CheckAll(ctx))
+
+	deliveries, err = dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1, "a link that stays broken is reported once") {
+		assert.Equal(t, webhooks.EventBroken, deliveries[0].Event)
+		var event webhooks.Event
+		assert.NoError(t, json.Unmarshal(deliveries[0].Payload, &event))
+		assert.Equal(t, "http://localhost:8080/abc", event.Link.ShortURL)
+		if assert.NotNil(t, event.Health) {
+			assert.Equal(t, http.StatusNotFound, event.Health.Status)
+		}
+	}
+}
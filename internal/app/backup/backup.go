@@ -0,0 +1,278 @@
+// Package backup copies every record between storages and reads and writes portable backup archives.
+//
+// An archive is a gzip compressed file with one JSON object per line: a header,
+// one line per stored URL and a footer with the number of records and the stats
+// of the backed up storage.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+)
+
+const (
+	// archiveFormat identifies backup archives.
+	archiveFormat = "shortener-backup"
+	// archiveVersion is the version of the archive layout.
+	archiveVersion = 1
+	// chunkSize is the number of records written to the target storage at once.
+	chunkSize = 1000
+	// maxLineSize limits a single archive line.
+	maxLineSize = 1024 * 1024
+)
+
+var (
+	// ErrNotEmpty is returned when the target storage already holds links.
+	ErrNotEmpty = errors.New("target storage is not empty")
+	// ErrInvalidArchive is returned when the input is not a complete backup archive.
+	ErrInvalidArchive = errors.New("invalid backup archive")
+	// ErrVerification is returned when the stats of the target storage differ from the expected ones.
+	ErrVerification = errors.New("verification failed")
+)
+
+// Header is the first line of an archive.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Footer is the last line of an archive.
+type Footer struct {
+	Records int           `json:"records"`
+	Stats   storage.Stats `json:"stats"`
+}
+
+// line is a single line of an archive. Exactly one field is set.
+type line struct {
+	Header *Header           `json:"header,omitempty"`
+	URL    *storage.SavedURL `json:"url,omitempty"`
+	Footer *Footer           `json:"footer,omitempty"`
+}
+
+// Result counts the records written to the target storage.
+// Skipped records conflicted with links that were already written.
+type Result struct {
+	Records int
+	Created int
+	Skipped int
+}
+
+// Copy streams every record from src to dst, keeping short URLs, owners, deleted flags and tags,
+// and then verifies that both storages report the same stats. dst must be empty.
+func Copy(ctx context.Context, src, dst storage.Storage) (Result, error) {
+	if err := checkEmpty(ctx, dst); err != nil {
+		return Result{}, err
+	}
+
+	loader := newLoader(dst)
+	if err := src.ExportAll(ctx, func(savedURL storage.SavedURL) error {
+		return loader.add(ctx, savedURL)
+	}); err != nil {
+		return loader.result, err
+	}
+	if err := loader.flush(ctx); err != nil {
+		return loader.result, err
+	}
+
+	want, err := src.GetStats(ctx)
+	if err != nil {
+		return loader.result, err
+	}
+	return loader.result, Verify(ctx, dst, want)
+}
+
+// Write writes an archive with every record of src to w.
+// The footer holds the stats computed from the written records, which are checked against src.GetStats
+// to detect changes made while the backup was running.
+func Write(ctx context.Context, src storage.Storage, w io.Writer) (Footer, error) {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+
+	if err := encoder.Encode(line{Header: &Header{
+		Format:    archiveFormat,
+		Version:   archiveVersion,
+		CreatedAt: time.Now().UTC(),
+	}}); err != nil {
+		return Footer{}, err
+	}
+
+	var footer Footer
+	users := make(map[string]bool)
+	err := src.ExportAll(ctx, func(savedURL storage.SavedURL) error {
+		footer.Records++
+		if !savedURL.IsDeleted {
+			footer.Stats.URLs++
+			users[savedURL.UserID] = true
+		}
+		return encoder.Encode(line{URL: &savedURL})
+	})
+	if err != nil {
+		return footer, err
+	}
+	footer.Stats.Users = len(users)
+
+	if err := encoder.Encode(line{Footer: &footer}); err != nil {
+		return footer, err
+	}
+	if err := gz.Close(); err != nil {
+		return footer, err
+	}
+
+	stats, err := src.GetStats(ctx)
+	if err != nil {
+		return footer, err
+	}
+	if stats != footer.Stats {
+		return footer, fmt.Errorf("%w: storage changed during the backup, archive has %+v, storage has %+v", ErrVerification, footer.Stats, stats)
+	}
+	return footer, nil
+}
+
+// Restore loads every record of the archive into dst and verifies the stats against the footer.
+// dst must be empty.
+func Restore(ctx context.Context, r io.Reader, dst storage.Storage) (Result, error) {
+	if err := checkEmpty(ctx, dst); err != nil {
+		return Result{}, err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	header, err := readHeader(scanner)
+	if err != nil {
+		return Result{}, err
+	}
+	if header.Format != archiveFormat || header.Version != archiveVersion {
+		return Result{}, fmt.Errorf("%w: unsupported format %q version %d", ErrInvalidArchive, header.Format, header.Version)
+	}
+
+	loader := newLoader(dst)
+	var footer *Footer
+	for scanner.Scan() {
+		if footer != nil {
+			return loader.result, fmt.Errorf("%w: data after the footer", ErrInvalidArchive)
+		}
+
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return loader.result, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		switch {
+		case l.URL != nil:
+			if err := loader.add(ctx, *l.URL); err != nil {
+				return loader.result, err
+			}
+		case l.Footer != nil:
+			footer = l.Footer
+		default:
+			return loader.result, fmt.Errorf("%w: unexpected line", ErrInvalidArchive)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return loader.result, err
+	}
+	if footer == nil {
+		return loader.result, fmt.Errorf("%w: archive is truncated", ErrInvalidArchive)
+	}
+	if err := loader.flush(ctx); err != nil {
+		return loader.result, err
+	}
+
+	if loader.result.Records != footer.Records {
+		return loader.result, fmt.Errorf("%w: archive has %d records, footer expects %d", ErrInvalidArchive, loader.result.Records, footer.Records)
+	}
+	return loader.result, Verify(ctx, dst, footer.Stats)
+}
+
+// Verify checks that the storage reports the expected stats.
+func Verify(ctx context.Context, store storage.Storage, want storage.Stats) error {
+	got, err := store.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: expected %d urls and %d users, got %d urls and %d users", ErrVerification, want.URLs, want.Users, got.URLs, got.Users)
+	}
+	return nil
+}
+
+// readHeader reads the first line of the archive.
+func readHeader(scanner *bufio.Scanner) (Header, error) {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return Header{}, err
+		}
+		return Header{}, fmt.Errorf("%w: archive is empty", ErrInvalidArchive)
+	}
+
+	var l line
+	if err := json.Unmarshal(scanner.Bytes(), &l); err != nil || l.Header == nil {
+		return Header{}, fmt.Errorf("%w: header is missing", ErrInvalidArchive)
+	}
+	return *l.Header, nil
+}
+
+// checkEmpty returns ErrNotEmpty if the storage holds any record, including deleted ones.
+func checkEmpty(ctx context.Context, store storage.Storage) error {
+	return store.ExportAll(ctx, func(storage.SavedURL) error {
+		return ErrNotEmpty
+	})
+}
+
+// loader writes records to a storage in chunks.
+type loader struct {
+	dst    storage.Storage
+	chunk  []storage.SavedURL
+	result Result
+}
+
+// newLoader creates a new loader.
+func newLoader(dst storage.Storage) *loader {
+	return &loader{dst: dst, chunk: make([]storage.SavedURL, 0, chunkSize)}
+}
+
+// add queues a record and writes the chunk when it is full.
+func (l *loader) add(ctx context.Context, savedURL storage.SavedURL) error {
+	l.chunk = append(l.chunk, savedURL)
+	if len(l.chunk) < chunkSize {
+		return nil
+	}
+	return l.flush(ctx)
+}
+
+// flush writes the queued records.
+func (l *loader) flush(ctx context.Context) error {
+	if len(l.chunk) == 0 {
+		return nil
+	}
+
+	results, err := l.dst.Import(ctx, l.chunk)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		l.result.Records++
+		if result.Status == storage.SaveCreated {
+			l.result.Created++
+		} else {
+			l.result.Skipped++
+		}
+	}
+	l.chunk = l.chunk[:0]
+	return nil
+}
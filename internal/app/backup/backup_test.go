@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSource(t *testing.T) *memory.MemoryStorage {
+	ctx := context.Background()
+	m := &memory.MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	tagged := *storage.NewSavedURL("tagged", "https://example.com/", "alice")
+	tagged.Tags = []string{"docs"}
+	_, err := m.SaveArray(ctx, []storage.SavedURL{
+		tagged,
+		*storage.NewSavedURL("gone", "https://example.org/", "alice"),
+		*storage.NewSavedURL("bob1", "https://example.net/", "bob"),
+	}, false)
+	assert.NoError(t, err)
+	_, err = m.Delete(ctx, []models.DeleteTask{{URL: "gone", UserID: "alice"}})
+	assert.NoError(t, err)
+	return m
+}
+
+func exportAll(t *testing.T, store storage.Storage) []storage.SavedURL {
+	var urls []storage.SavedURL
+	assert.NoError(t, store.ExportAll(context.Background(), func(savedURL storage.SavedURL) error {
+		urls = append(urls, savedURL)
+		return nil
+	}))
+	return urls
+}
+
+func TestWriteRestore(t *testing.T) {
+	ctx := context.Background()
+	src := newSource(t)
+
+	var archive bytes.Buffer
+	footer, err := Write(ctx, src, &archive)
+	assert.NoError(t, err)
+	assert.Equal(t, Footer{Records: 3, Stats: storage.Stats{URLs: 2, Users: 2}}, footer)
+
+	dst := &memory.MemoryStorage{}
+	assert.NoError(t, dst.Init(ctx))
+	result, err := Restore(ctx, &archive, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Records: 3, Created: 3}, result)
+	assert.ElementsMatch(t, exportAll(t, src), exportAll(t, dst))
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	src := newSource(t)
+
+	dst := &memory.MemoryStorage{}
+	assert.NoError(t, dst.Init(ctx))
+	result, err := Copy(ctx, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Records: 3, Created: 3}, result)
+	assert.ElementsMatch(t, exportAll(t, src), exportAll(t, dst))
+
+	_, err = Copy(ctx, src, dst)
+	assert.ErrorIs(t, err, ErrNotEmpty)
+}
+
+func TestRestoreInvalidArchive(t *testing.T) {
+	ctx := context.Background()
+
+	var archive bytes.Buffer
+	_, err := Write(ctx, newSource(t), &archive)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not gzip", data: []byte("shortUrl,originalUrl\n")},
+		{name: "truncated", data: archive.Bytes()[:archive.Len()/2]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := &memory.MemoryStorage{}
+			assert.NoError(t, dst.Init(ctx))
+			_, err := Restore(ctx, bytes.NewReader(test.data), dst)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package clicks records served redirects in the storage without slowing the redirects down.
+//
+// Visitors are identified by a hash of their address and user agent, so unique visitors can be
+// counted without storing addresses. The hash is salted with a key derived from a secret and the
+// UTC day, so hashes of different days can't be linked to each other. The secret is shared by all
+// instances of the service, which then hash a visitor the same way and count it once per day.
+// Without a secret every run uses a random one, and a visitor may be counted once per instance
+// or restart on the same day.
+package clicks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/targeting"
+	"go.uber.org/zap"
+)
+
+const (
+	// bufferSize is the number of clicks waiting to be saved before new ones are dropped.
+	bufferSize = 4096
+	// batchSize is the number of clicks saved at once.
+	batchSize = 500
+	// flushInterval is how often clicks are saved when there are fewer than batchSize of them.
+	flushInterval = time.Second
+)
+
+// Visit describes the client of a click.
+type Visit struct {
+	IP        net.IP
+	UserAgent string
+	// Referrer is the URL of the page the visitor came from.
+	Referrer string
+	// Country is the ISO code of the client location, empty if it's unknown.
+	Country string
+}
+
+// Recorder buffers clicks and saves them in batches.
+type Recorder struct {
+	storage storage.Storage
+	logger  *zap.Logger
+	clicks  chan storage.Click
+	dropped atomic.Int64
+
+	secret  []byte
+	mu      sync.Mutex
+	saltDay string
+	salt    []byte
+}
+
+// NewRecorder creates a recorder that saves clicks to the storage once Run is started.
+// The daily salts of visitor hashes are derived from the secret, a random one if it's empty.
+func NewRecorder(store storage.Storage, logger *zap.Logger, secret string) *Recorder {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Recorder{
+		storage: store,
+		logger:  logger,
+		clicks:  make(chan storage.Click, bufferSize),
+		secret:  key,
+	}
+}
+
+// Record queues the click. It never blocks: if the buffer is full the click is dropped and counted.
+func (r *Recorder) Record(click storage.Click) {
+	if click.Time.IsZero() {
+		click.Time = time.Now()
+	}
+	select {
+	case r.clicks <- click:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// RecordVisit queues the click with the visitor, the referrer domain, the browser, the OS and the country of the visit.
+func (r *Recorder) RecordVisit(click storage.Click, visit Visit) {
+	if click.Time.IsZero() {
+		click.Time = time.Now()
+	}
+	client := targeting.Parse(visit.UserAgent)
+	click.Visitor = r.visitor(visit, click.Time)
+	click.Referrer = ReferrerDomain(visit.Referrer)
+	click.Browser = client.Browser
+	click.OS = client.OS
+	click.Country = visit.Country
+	r.Record(click)
+}
+
+// visitor returns the hash that identifies the client of the visit on the day of the click,
+// or an empty string if nothing is known about the client.
+func (r *Recorder) visitor(visit Visit, clickTime time.Time) string {
+	if visit.IP == nil && visit.UserAgent == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.saltOf(clickTime))
+	mac.Write([]byte(visit.IP.String()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(visit.UserAgent))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// saltOf returns the salt of the UTC day of the time, the HMAC-SHA256 of the day keyed with the secret.
+// The salt of the current day is kept, so it's computed once a day.
+func (r *Recorder) saltOf(t time.Time) []byte {
+	day := t.UTC().Format(time.DateOnly)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.saltDay != day {
+		mac := hmac.New(sha256.New, r.secret)
+		mac.Write([]byte(day))
+		r.salt, r.saltDay = mac.Sum(nil), day
+	}
+	return r.salt
+}
+
+// ReferrerDomain returns the domain of the referrer URL without "www.", or an empty string
+// if there is no referrer or it has no domain.
+func ReferrerDomain(referrer string) string {
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// Dropped returns the number of clicks dropped because the buffer was full.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Run saves the queued clicks until the context is done and then saves the rest.
+// The returned WaitGroup is done when the last clicks are saved.
+func (r *Recorder) Run(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]storage.Click, 0, batchSize)
+		for {
+			select {
+			case click := <-r.clicks:
+				batch = append(batch, click)
+				if len(batch) >= batchSize {
+					batch = r.flush(ctx, batch)
+				}
+			case <-ticker.C:
+				batch = r.flush(ctx, batch)
+			case <-ctx.Done():
+				for {
+					select {
+					case click := <-r.clicks:
+						batch = append(batch, click)
+					default:
+						r.flush(context.Background(), batch)
+						return
+					}
+				}
+			}
+		}
+	}()
+	return &wg
+}
+
+// flush saves the batch and returns it emptied. Clicks that can't be saved are logged and dropped,
+// so a storage outage doesn't grow the buffer without bounds.
+func (r *Recorder) flush(ctx context.Context, batch []storage.Click) []storage.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := r.storage.SaveClicks(ctx, batch); err != nil && r.logger != nil {
+		r.logger.Sugar().Errorf("failed to save %d clicks: %v", len(batch), err)
+	}
+	return batch[:0]
+}
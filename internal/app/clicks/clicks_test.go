@@ -0,0 +1,86 @@
+package clicks
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderSavesOnShutdown(t *testing.T) {
+	store := &memory.MemoryStorage{}
+	assert.NoError(t, store.Init(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	recorder := NewRecorder(store, nil, "")
+	wg := recorder.Run(ctx)
+
+	recorder.Record(storage.Click{ShortURL: "abc", Variant: "a"})
+	recorder.Record(storage.Click{ShortURL: "abc", Variant: "b"})
+	recorder.Record(storage.Click{ShortURL: "abc", Variant: "a"})
+	recorder.Record(storage.Click{ShortURL: "other"})
+	cancel()
+	wg.Wait()
+
+	stats, err := store.GetClickStats(context.Background(), "abc", storage.ClickFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Clicks)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, stats.Variants)
+}
+
+func TestRecordDoesNotBlock(t *testing.T) {
+	recorder := NewRecorder(nil, nil, "")
+	for i := 0; i < bufferSize+10; i++ {
+		recorder.Record(storage.Click{ShortURL: "abc"})
+	}
+	assert.Equal(t, int64(10), recorder.Dropped())
+}
+
+func TestRecordVisit(t *testing.T) {
+	recorder := NewRecorder(nil, nil, "")
+	day := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	visit := Visit{
+		IP:        net.ParseIP("203.0.113.7"),
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		Referrer:  "https://www.Google.com/search?q=shortener",
+		Country:   "DE",
+	}
+
+	recorder.RecordVisit(storage.Click{ShortURL: "abc", Time: day}, visit)
+	recorder.RecordVisit(storage.Click{ShortURL: "abc", Time: day.Add(time.Hour)}, visit)
+	recorder.RecordVisit(storage.Click{ShortURL: "abc", Time: day.Add(24 * time.Hour)}, visit)
+	recorder.RecordVisit(storage.Click{ShortURL: "abc", Time: day}, Visit{})
+
+	first, again, nextDay, unknown := <-recorder.clicks, <-recorder.clicks, <-recorder.clicks, <-recorder.clicks
+	assert.Equal(t, "google.com", first.Referrer)
+	assert.Equal(t, "chrome", first.Browser)
+	assert.Equal(t, "windows", first.OS)
+	assert.Equal(t, "DE", first.Country)
+	assert.NotEmpty(t, first.Visitor)
+	assert.NotContains(t, first.Visitor, "203.0.113.7")
+	assert.Equal(t, first.Visitor, again.Visitor, "a visitor keeps the hash for the day")
+	assert.NotEqual(t, first.Visitor, nextDay.Visitor, "the hash changes with the day")
+	assert.Empty(t, unknown.Visitor)
+}
+
+func TestVisitorSharedSecret(t *testing.T) {
+	day := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	visit := Visit{IP: net.ParseIP("203.0.113.7"), UserAgent: "curl/8.0"}
+
+	first, second := NewRecorder(nil, nil, "secret"), NewRecorder(nil, nil, "secret")
+	assert.Equal(t, first.visitor(visit, day), second.visitor(visit, day.Add(time.Hour)), "instances with the same secret hash a visitor the same way")
+	assert.NotEqual(t, first.visitor(visit, day), first.visitor(visit, day.Add(24*time.Hour)))
+	assert.NotEqual(t, first.visitor(visit, day), NewRecorder(nil, nil, "other").visitor(visit, day))
+	assert.NotEqual(t, NewRecorder(nil, nil, "").visitor(visit, day), NewRecorder(nil, nil, "").visitor(visit, day), "without a secret every run has its own")
+}
+
+func TestReferrerDomain(t *testing.T) {
+	assert.Equal(t, "news.example.com", ReferrerDomain("https://news.example.com:8443/story?id=1"))
+	assert.Equal(t, "example.com", ReferrerDomain("http://WWW.example.com/"))
+	assert.Empty(t, ReferrerDomain(""))
+	assert.Empty(t, ReferrerDomain("android-app"))
+}
@@ -0,0 +1,97 @@
+// Package clientip determines the address of the client that sent a request.
+//
+// Forwarding headers can be set by anyone, so they are only used when the request
+// comes from a trusted proxy, and only the addresses appended by trusted proxies are skipped.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver finds client addresses behind the trusted proxies.
+// A nil Resolver trusts no proxies.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver creates a resolver that trusts the proxies in the comma separated CIDR blocks or addresses.
+// Without trusted proxies the remote address of the connection is the client.
+func NewResolver(proxies string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+// FromRequest returns the address of the client, or nil if it can't be determined.
+// If the connection comes from a trusted proxy, X-Forwarded-For is read from right to left
+// and the first address that is not a trusted proxy is the client. X-Real-IP is used if
+// X-Forwarded-For is missing.
+func (r *Resolver) FromRequest(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return r.Resolve(net.ParseIP(host), req.Header.Values("X-Forwarded-For"), req.Header.Get("X-Real-IP"))
+}
+
+// Resolve returns the address of the client of a connection from remote with the forwarding headers.
+func (r *Resolver) Resolve(remote net.IP, forwardedFor []string, realIP string) net.IP {
+	if remote == nil || r == nil || !r.isTrusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range forwardedFor {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+			return ip
+		}
+		return remote
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// A malformed hop can't be trusted, the last valid address before it is the best guess.
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrusted reports whether the address belongs to a trusted proxy.
+func (r *Resolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
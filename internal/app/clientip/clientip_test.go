@@ -0,0 +1,52 @@
+package clientip
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	resolver, err := NewResolver("10.0.0.0/8, 192.168.1.1")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remote       string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{name: "direct connection", remote: "81.2.69.142", want: "81.2.69.142"},
+		{name: "untrusted remote ignores headers", remote: "81.2.69.142", forwardedFor: []string{"89.160.20.112"}, want: "81.2.69.142"},
+		{name: "trusted proxy", remote: "10.0.0.5", forwardedFor: []string{"89.160.20.112"}, want: "89.160.20.112"},
+		{name: "spoofed hop is skipped", remote: "10.0.0.5", forwardedFor: []string{"1.1.1.1, 89.160.20.112, 192.168.1.1"}, want: "89.160.20.112"},
+		{name: "several headers", remote: "10.0.0.5", forwardedFor: []string{"1.1.1.1", "89.160.20.112"}, want: "89.160.20.112"},
+		{name: "real ip", remote: "10.0.0.5", realIP: "89.160.20.112", want: "89.160.20.112"},
+		{name: "only proxies", remote: "10.0.0.5", forwardedFor: []string{"10.0.0.7"}, want: "10.0.0.7"},
+		{name: "malformed hop", remote: "10.0.0.5", forwardedFor: []string{"89.160.20.112, unknown"}, want: "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolver.Resolve(net.ParseIP(tt.remote), tt.forwardedFor, tt.realIP)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	resolver, err := NewResolver("")
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	req.RemoteAddr = "81.2.69.142:5000"
+	req.Header.Set("X-Forwarded-For", "89.160.20.112")
+	assert.Equal(t, "81.2.69.142", resolver.FromRequest(req).String())
+}
+
+func TestNewResolverInvalid(t *testing.T) {
+	_, err := NewResolver("10.0.0.0/33")
+	assert.Error(t, err)
+}
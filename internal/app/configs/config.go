@@ -12,15 +12,33 @@ import (
 
 // Config holds the application's configuration.
 type Config struct {
-	ServerAdr       string `json:"server_address"`
-	RedirectHost    string `json:"redirect_host"`
-	LogLevel        string `json:"log_level"`
-	FileStoragePath string `json:"file_storage_path"`
-	DBAddress       string `json:"database_dsn"`
-	EnableHTTPS     bool   `json:"enable_https"`
-	SSLCertPath     string `json:"cert_path"`
-	TrustedSubnet   string `json:"trusted_subnet"`
-	GRPCServerAdr   string `json:"grpc_server_address"`
+	ServerAdr         string `json:"server_address"`
+	RedirectHost      string `json:"redirect_host"`
+	Domains           string `json:"domains"`
+	RedirectStatus    int    `json:"redirect_status"`
+	LogLevel          string `json:"log_level"`
+	FileStoragePath   string `json:"file_storage_path"`
+	DBAddress         string `json:"database_dsn"`
+	EnableHTTPS       bool   `json:"enable_https"`
+	SSLCertPath       string `json:"cert_path"`
+	TrustedSubnet     string `json:"trusted_subnet"`
+	GRPCServerAdr     string `json:"grpc_server_address"`
+	AllowedSchemes    string `json:"allowed_schemes"`
+	StripTracking     bool   `json:"strip_tracking_params"`
+	BlocklistPath     string `json:"blocklist_path"`
+	AllowlistPath     string `json:"allowlist_path"`
+	ReputationURL     string `json:"reputation_url"`
+	DedupScope        string `json:"dedup_scope"`
+	CacheSize         int    `json:"cache_size"`
+	CacheTTL          string `json:"cache_ttl"`
+	CacheMissTTL      string `json:"cache_miss_ttl"`
+	CacheBackend      string `json:"cache_backend"`
+	GeoIPDBPath       string `json:"geoip_db_path"`
+	TrustedProxies    string `json:"trusted_proxies"`
+	HealthInterval    string `json:"health_check_interval"`
+	HealthConcurrency int    `json:"health_check_concurrency"`
+	HealthHostDelay   string `json:"health_check_host_delay"`
+	VisitorSecret     string `json:"visitor_secret"`
 }
 
 // ErrParseConfigJson is returned when the config file cant be parsed.
@@ -38,6 +56,8 @@ func ParseFlags() (*Config, error) {
 	os.Environ()
 	flag.StringVar(&serverConfig.ServerAdr, "a", ":8080", "Serer address")
 	flag.StringVar(&serverConfig.RedirectHost, "b", "http://localhost:8080", "Redirection host")
+	flag.StringVar(&serverConfig.Domains, "domains", "", "Comma separated base URLs of additional short domains")
+	flag.IntVar(&serverConfig.RedirectStatus, "redirect-status", 307, "Default HTTP status of redirects: 301, 302, 307 or 308")
 	flag.StringVar(&serverConfig.LogLevel, "ll", "info", "Loglevel")
 	flag.StringVar(&serverConfig.FileStoragePath, "f", "/tmp/short-url-db.json", "File storage path")
 	flag.StringVar(&serverConfig.DBAddress, "d", "", "DB address")
@@ -46,6 +66,22 @@ func ParseFlags() (*Config, error) {
 	flag.StringVar(&jsonConfigPath, "c", "", "JSON config")
 	flag.StringVar(&serverConfig.TrustedSubnet, "t", "", "Trusted subnet")
 	flag.StringVar(&serverConfig.GRPCServerAdr, "g", ":50051", "GRPC server address")
+	flag.StringVar(&serverConfig.AllowedSchemes, "schemes", "http,https", "Comma separated list of allowed URL schemes")
+	flag.BoolVar(&serverConfig.StripTracking, "strip-tracking", false, "Strip tracking parameters from URLs")
+	flag.StringVar(&serverConfig.BlocklistPath, "blocklist", "", "Domain and regexp blocklist file path")
+	flag.StringVar(&serverConfig.AllowlistPath, "allowlist", "", "Domain and regexp allowlist file path")
+	flag.StringVar(&serverConfig.ReputationURL, "reputation-url", "", "URL reputation service endpoint")
+	flag.StringVar(&serverConfig.DedupScope, "dedup", "global", "Deduplication scope of original URLs: global, user or none")
+	flag.IntVar(&serverConfig.CacheSize, "cache-size", 10000, "Number of redirects cached in memory, 0 disables the cache")
+	flag.StringVar(&serverConfig.CacheTTL, "cache-ttl", "1m", "Lifetime of cached redirects")
+	flag.StringVar(&serverConfig.CacheMissTTL, "cache-miss-ttl", "10s", "Lifetime of cached lookups of unknown short URLs")
+	flag.StringVar(&serverConfig.CacheBackend, "cache-backend", "", "Cache shared by all instances: postgres, which needs the database storage, or empty for none")
+	flag.StringVar(&serverConfig.GeoIPDBPath, "geoip-db", "", "MaxMind-format GeoIP country database path")
+	flag.StringVar(&serverConfig.TrustedProxies, "trusted-proxies", "", "Comma separated CIDR blocks of proxies whose X-Forwarded-For header is trusted")
+	flag.StringVar(&serverConfig.HealthInterval, "health-interval", "24h", "How often the destinations of links are checked, 0 disables the checks")
+	flag.IntVar(&serverConfig.HealthConcurrency, "health-concurrency", 8, "Number of hosts checked at once")
+	flag.StringVar(&serverConfig.HealthHostDelay, "health-host-delay", "1s", "Pause between two checks of destinations on the same host")
+	flag.StringVar(&serverConfig.VisitorSecret, "visitor-secret", "", "Secret shared by all instances that the daily salt of visitor hashes is derived from")
 	flag.Parse()
 
 	if jsonPath, exist := os.LookupEnv("CONFIG"); exist {
@@ -70,6 +106,16 @@ func ParseFlags() (*Config, error) {
 		serverConfig.RedirectHost = redirectHost
 	}
 
+	if domains, exist := os.LookupEnv("DOMAINS"); exist {
+		serverConfig.Domains = domains
+	}
+
+	if redirectStatus, exist := os.LookupEnv("REDIRECT_STATUS"); exist {
+		if value, err := strconv.Atoi(redirectStatus); err == nil {
+			serverConfig.RedirectStatus = value
+		}
+	}
+
 	if logLevel, exist := os.LookupEnv("LOGGER_LEVEL"); exist {
 		serverConfig.LogLevel = logLevel
 	}
@@ -90,6 +136,76 @@ func ParseFlags() (*Config, error) {
 		serverConfig.GRPCServerAdr = grpcServerAdress
 	}
 
+	if allowedSchemes, exist := os.LookupEnv("ALLOWED_SCHEMES"); exist {
+		serverConfig.AllowedSchemes = allowedSchemes
+	}
+
+	if stripTracking, exist := os.LookupEnv("STRIP_TRACKING_PARAMS"); exist {
+		if value, err := strconv.ParseBool(stripTracking); err == nil {
+			serverConfig.StripTracking = value
+		}
+	}
+
+	if blocklistPath, exist := os.LookupEnv("BLOCKLIST_PATH"); exist {
+		serverConfig.BlocklistPath = blocklistPath
+	}
+
+	if allowlistPath, exist := os.LookupEnv("ALLOWLIST_PATH"); exist {
+		serverConfig.AllowlistPath = allowlistPath
+	}
+
+	if reputationURL, exist := os.LookupEnv("REPUTATION_URL"); exist {
+		serverConfig.ReputationURL = reputationURL
+	}
+
+	if dedupScope, exist := os.LookupEnv("DEDUP_SCOPE"); exist {
+		serverConfig.DedupScope = dedupScope
+	}
+
+	if cacheSize, exist := os.LookupEnv("CACHE_SIZE"); exist {
+		if value, err := strconv.Atoi(cacheSize); err == nil {
+			serverConfig.CacheSize = value
+		}
+	}
+
+	if cacheTTL, exist := os.LookupEnv("CACHE_TTL"); exist {
+		serverConfig.CacheTTL = cacheTTL
+	}
+
+	if cacheMissTTL, exist := os.LookupEnv("CACHE_MISS_TTL"); exist {
+		serverConfig.CacheMissTTL = cacheMissTTL
+	}
+
+	if cacheBackend, exist := os.LookupEnv("CACHE_BACKEND"); exist {
+		serverConfig.CacheBackend = cacheBackend
+	}
+
+	if geoIPDBPath, exist := os.LookupEnv("GEOIP_DB_PATH"); exist {
+		serverConfig.GeoIPDBPath = geoIPDBPath
+	}
+
+	if trustedProxies, exist := os.LookupEnv("TRUSTED_PROXIES"); exist {
+		serverConfig.TrustedProxies = trustedProxies
+	}
+
+	if healthInterval, exist := os.LookupEnv("HEALTH_CHECK_INTERVAL"); exist {
+		serverConfig.HealthInterval = healthInterval
+	}
+
+	if healthConcurrency, exist := os.LookupEnv("HEALTH_CHECK_CONCURRENCY"); exist {
+		if value, err := strconv.Atoi(healthConcurrency); err == nil {
+			serverConfig.HealthConcurrency = value
+		}
+	}
+
+	if healthHostDelay, exist := os.LookupEnv("HEALTH_CHECK_HOST_DELAY"); exist {
+		serverConfig.HealthHostDelay = healthHostDelay
+	}
+
+	if visitorSecret, exist := os.LookupEnv("VISITOR_SECRET"); exist {
+		serverConfig.VisitorSecret = visitorSecret
+	}
+
 	jsonConfig, err := createConfigFromFile(jsonConfigPath)
 
 	if err != nil {
@@ -126,6 +242,12 @@ func (c *Config) updateConfig(config Config) {
 	if c.RedirectHost == "" && config.RedirectHost != "" {
 		c.RedirectHost = config.RedirectHost
 	}
+	if c.Domains == "" && config.Domains != "" {
+		c.Domains = config.Domains
+	}
+	if c.RedirectStatus == 0 && config.RedirectStatus != 0 {
+		c.RedirectStatus = config.RedirectStatus
+	}
 	if c.LogLevel == "" && config.LogLevel != "" {
 		c.LogLevel = config.LogLevel
 	}
@@ -145,4 +267,52 @@ func (c *Config) updateConfig(config Config) {
 	if c.GRPCServerAdr == "" && config.GRPCServerAdr != "" {
 		c.GRPCServerAdr = config.GRPCServerAdr
 	}
+	if c.AllowedSchemes == "" && config.AllowedSchemes != "" {
+		c.AllowedSchemes = config.AllowedSchemes
+	}
+	if !c.StripTracking && config.StripTracking {
+		c.StripTracking = config.StripTracking
+	}
+	if c.BlocklistPath == "" && config.BlocklistPath != "" {
+		c.BlocklistPath = config.BlocklistPath
+	}
+	if c.AllowlistPath == "" && config.AllowlistPath != "" {
+		c.AllowlistPath = config.AllowlistPath
+	}
+	if c.ReputationURL == "" && config.ReputationURL != "" {
+		c.ReputationURL = config.ReputationURL
+	}
+	if c.DedupScope == "" && config.DedupScope != "" {
+		c.DedupScope = config.DedupScope
+	}
+	if c.CacheSize == 0 && config.CacheSize != 0 {
+		c.CacheSize = config.CacheSize
+	}
+	if c.CacheTTL == "" && config.CacheTTL != "" {
+		c.CacheTTL = config.CacheTTL
+	}
+	if c.CacheMissTTL == "" && config.CacheMissTTL != "" {
+		c.CacheMissTTL = config.CacheMissTTL
+	}
+	if c.CacheBackend == "" && config.CacheBackend != "" {
+		c.CacheBackend = config.CacheBackend
+	}
+	if c.GeoIPDBPath == "" && config.GeoIPDBPath != "" {
+		c.GeoIPDBPath = config.GeoIPDBPath
+	}
+	if c.TrustedProxies == "" && config.TrustedProxies != "" {
+		c.TrustedProxies = config.TrustedProxies
+	}
+	if c.HealthInterval == "" && config.HealthInterval != "" {
+		c.HealthInterval = config.HealthInterval
+	}
+	if c.HealthConcurrency == 0 && config.HealthConcurrency != 0 {
+		c.HealthConcurrency = config.HealthConcurrency
+	}
+	if c.HealthHostDelay == "" && config.HealthHostDelay != "" {
+		c.HealthHostDelay = config.HealthHostDelay
+	}
+	if c.VisitorSecret == "" && config.VisitorSecret != "" {
+		c.VisitorSecret = config.VisitorSecret
+	}
 }
@@ -92,6 +92,39 @@ func OnlyAuthorizedMiddlewareGRPC(ctx context.Context, req interface{}, info *gr
 	return handler(ctx, req)
 }
 
+// authorizedStream is a server stream whose context carries the user ID.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the user ID.
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// OnlyAuthorizedStreamGRPC is a gRPC stream interceptor that checks if the user is authorized
+// and puts the user ID into the stream context.
+func OnlyAuthorizedStreamGRPC(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, ok := metadata.FromIncomingContext(ss.Context())
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+	}
+
+	values := md.Get("jwtToken")
+	if len(values) == 0 {
+		return status.Errorf(codes.Unauthenticated, "no jwt token provided")
+	}
+
+	userID, err := getUserID(values[0])
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "invalid jwt token")
+	}
+
+	ctx := context.WithValue(ss.Context(), UserID("UserID"), userID)
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
 // MetadataCheckMiddlewareGRPC is a gRPC interceptor that checks for a JWT token in the metadata and generates a cookie.
 func MetadataCheckMiddlewareGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler, app *app.App) (interface{}, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
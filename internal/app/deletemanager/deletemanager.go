@@ -12,10 +12,12 @@ import (
 )
 
 // DeleteManager is responsible for managing deletion tasks and processing them.
+// OnDelete, if set, is called with the URLs every batch of tasks deleted in the storage.
 type DeleteManager struct {
 	Storage  storage.Storage
 	TaskChan chan models.DeleteTask
 	Logger   *zap.Logger
+	OnDelete func(ctx context.Context, deleted []storage.SavedURL)
 }
 
 // NewDeleteManager creates a new instance of DeleteManager with the provided storage.
@@ -41,19 +43,22 @@ func (m *DeleteManager) SubcribeOnTask(ctx context.Context) (*sync.WaitGroup, ch
 
 			case <-ticker.C:
 				if len(taskSlice) > 0 {
-					err := m.Storage.Delete(ctx, taskSlice)
+					deleted, err := m.Storage.Delete(ctx, taskSlice)
 					if err != nil {
 						errChan <- err
 						continue
 					}
+					m.deleted(ctx, deleted)
 					taskSlice = nil
 				}
 
 			case <-ctx.Done():
 				if len(taskSlice) > 0 {
-					err := m.Storage.Delete(context.Background(), taskSlice)
+					deleted, err := m.Storage.Delete(context.Background(), taskSlice)
 					if err != nil {
 						errChan <- err
+					} else {
+						m.deleted(context.Background(), deleted)
 					}
 				}
 				wg.Done()
@@ -64,3 +69,10 @@ func (m *DeleteManager) SubcribeOnTask(ctx context.Context) (*sync.WaitGroup, ch
 	}()
 	return &wg, errChan
 }
+
+// deleted calls OnDelete with the URLs a batch deleted.
+func (m *DeleteManager) deleted(ctx context.Context, deleted []storage.SavedURL) {
+	if m.OnDelete != nil && len(deleted) > 0 {
+		m.OnDelete(ctx, deleted)
+	}
+}
@@ -0,0 +1,124 @@
+// Package domains manages the short domains links are created on.
+//
+// The redirect host is the default domain. Links on it are stored under their
+// short ID, links on additional domains under a key that includes the domain,
+// see storage.DomainKey.
+package domains
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+)
+
+// ErrUnknownDomain is returned when a link is created on a domain that is not configured.
+var ErrUnknownDomain = errors.New("unknown domain")
+
+// Domain is a short domain.
+type Domain struct {
+	// Name is the host of the domain, for example brand.link. It is empty for the default domain.
+	Name string
+	// BaseURL is the prefix of the short URLs, for example https://brand.link.
+	BaseURL string
+}
+
+// Registry holds the configured domains.
+type Registry struct {
+	defaultDomain Domain
+	defaultHost   string
+	domains       map[string]Domain
+}
+
+// NewRegistry creates a registry with redirectHost as the default domain
+// and the comma separated base URLs of the additional domains.
+func NewRegistry(redirectHost string, domains string) (*Registry, error) {
+	r := &Registry{
+		defaultDomain: Domain{BaseURL: strings.TrimSuffix(redirectHost, "/")},
+		domains:       make(map[string]Domain),
+	}
+	if u, err := url.Parse(redirectHost); err == nil {
+		r.defaultHost = strings.ToLower(u.Host)
+	}
+
+	for _, baseURL := range strings.Split(domains, ",") {
+		baseURL = strings.TrimSpace(baseURL)
+		if baseURL == "" {
+			continue
+		}
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid domain %q, expected a base URL like https://brand.link", baseURL)
+		}
+
+		name := strings.ToLower(u.Host)
+		if name == r.defaultHost {
+			continue
+		}
+		r.domains[name] = Domain{Name: name, BaseURL: u.Scheme + "://" + name}
+	}
+	return r, nil
+}
+
+// Lookup returns the domain with the host or base URL.
+// An empty name is the default domain.
+func (r *Registry) Lookup(name string) (Domain, error) {
+	if name == "" {
+		return r.defaultDomain, nil
+	}
+	if u, err := url.Parse(name); err == nil && u.Host != "" {
+		name = u.Host
+	}
+	name = strings.ToLower(name)
+
+	if name == r.defaultHost {
+		return r.defaultDomain, nil
+	}
+	if domain, ok := r.domains[name]; ok {
+		return domain, nil
+	}
+	return Domain{}, fmt.Errorf("%w: %s", ErrUnknownDomain, name)
+}
+
+// ForHost returns the domain a request with the Host header was sent to.
+// Unknown hosts resolve to the default domain.
+func (r *Registry) ForHost(host string) Domain {
+	if domain, ok := r.domains[strings.ToLower(host)]; ok {
+		return domain
+	}
+	return r.defaultDomain
+}
+
+// Key returns the storage key of the short ID on the domain.
+func (r *Registry) Key(domain Domain, id string) string {
+	return storage.DomainKey(domain.Name, id)
+}
+
+// ShortURL returns the full short URL of a stored key.
+func (r *Registry) ShortURL(key string) string {
+	name, id := storage.SplitKey(key)
+	if name == "" {
+		return r.defaultDomain.BaseURL + "/" + id
+	}
+	if domain, ok := r.domains[name]; ok {
+		return domain.BaseURL + "/" + id
+	}
+	// The domain was removed from the configuration, its links are still reachable over HTTPS if it is served.
+	return "https://" + name + "/" + id
+}
+
+// KeyOf converts a full short URL on a configured domain to its storage key.
+// Anything else, such as a short ID or a key, is returned unchanged.
+func (r *Registry) KeyOf(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return ref
+	}
+	domain, err := r.Lookup(u.Host)
+	if err != nil {
+		return ref
+	}
+	return r.Key(domain, strings.TrimPrefix(u.Path, "/"))
+}
@@ -0,0 +1,49 @@
+package domains
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry("http://localhost:8080", "https://Brand-A.link, https://brand-b.link/")
+	assert.NoError(t, err)
+
+	brandA, err := registry.Lookup("brand-a.link")
+	assert.NoError(t, err)
+	assert.Equal(t, Domain{Name: "brand-a.link", BaseURL: "https://brand-a.link"}, brandA)
+
+	brandB, err := registry.Lookup("https://brand-b.link")
+	assert.NoError(t, err)
+	assert.Equal(t, "brand-b.link", brandB.Name)
+
+	defaultDomain, err := registry.Lookup("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", defaultDomain.Name)
+
+	_, err = registry.Lookup("other.link")
+	assert.ErrorIs(t, err, ErrUnknownDomain)
+
+	assert.Equal(t, "abc", registry.Key(defaultDomain, "abc"))
+	assert.NotEqual(t, registry.Key(brandA, "abc"), registry.Key(brandB, "abc"))
+
+	assert.Equal(t, "http://localhost:8080/abc", registry.ShortURL("abc"))
+	assert.Equal(t, "https://brand-a.link/abc", registry.ShortURL(registry.Key(brandA, "abc")))
+
+	assert.Equal(t, brandA, registry.ForHost("brand-a.link"))
+	assert.Equal(t, defaultDomain, registry.ForHost("localhost:8080"))
+	assert.Equal(t, defaultDomain, registry.ForHost("unknown.link"))
+
+	assert.Equal(t, registry.Key(brandB, "abc"), registry.KeyOf("https://brand-b.link/abc"))
+	assert.Equal(t, "abc", registry.KeyOf("http://localhost:8080/abc"))
+	assert.Equal(t, "abc", registry.KeyOf("abc"))
+}
+
+func TestNewRegistryInvalidDomain(t *testing.T) {
+	_, err := NewRegistry("http://localhost:8080", "brand.link")
+	assert.Error(t, err)
+
+	_, err = NewRegistry("http://localhost:8080", "https://brand.link/path")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+// Package events publishes the clicks on links to the watchers of their owners as they happen.
+//
+// The bus lives in the process: every instance of the service publishes the redirects it serves,
+// so a watcher sees the clicks served by the instance it is connected to.
+// Publishing never blocks. Every watcher has a buffer of its own, and clicks that don't fit into
+// the buffer of a slow watcher are dropped for that watcher and counted, so it can tell it missed some.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bufferSize is the number of clicks waiting for a watcher before new ones are dropped.
+const bufferSize = 256
+
+// Click is a redirect served for a link.
+type Click struct {
+	// ShortURL is the full short URL of the link.
+	ShortURL string
+	// UserID is the owner of the link.
+	UserID      string
+	OriginalURL string
+	// Variant is the A/B variant the click was sent to.
+	Variant string
+	Time    time.Time
+}
+
+// Bus delivers the published clicks to the subscriptions of the owners of the links.
+type Bus struct {
+	mu            sync.RWMutex
+	closed        bool
+	subscriptions map[string]map[*Subscription]struct{}
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[string]map[*Subscription]struct{})}
+}
+
+// Publish sends the click to the subscriptions of its owner without waiting for them.
+// A nil bus does nothing.
+func (b *Bus) Publish(click Click) {
+	if b == nil {
+		return
+	}
+	if click.Time.IsZero() {
+		click.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for subscription := range b.subscriptions[click.UserID] {
+		select {
+		case subscription.clicks <- click:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the clicks on the links of the user.
+// The subscription must be closed when it is no longer read.
+// After the bus is closed it returns a subscription that is already closed.
+func (b *Bus) Subscribe(userID string) *Subscription {
+	subscription := &Subscription{bus: b, userID: userID, clicks: make(chan Click, bufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		subscription.closeOnce.Do(func() { close(subscription.clicks) })
+		return subscription
+	}
+	if b.subscriptions[userID] == nil {
+		b.subscriptions[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[userID][subscription] = struct{}{}
+	return subscription
+}
+
+// Close closes every subscription, so the watchers finish before the server stops.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for userID, subscriptions := range b.subscriptions {
+		for subscription := range subscriptions {
+			subscription.closeOnce.Do(func() { close(subscription.clicks) })
+		}
+		delete(b.subscriptions, userID)
+	}
+}
+
+// Subscription receives the clicks on the links of a user.
+type Subscription struct {
+	bus       *Bus
+	userID    string
+	clicks    chan Click
+	dropped   atomic.Int64
+	closeOnce sync.Once
+}
+
+// Clicks returns the channel of the clicks. It is closed when the subscription or the bus is closed.
+func (s *Subscription) Clicks() <-chan Click {
+	return s.clicks
+}
+
+// TakeDropped returns the number of clicks dropped since the last call because the buffer was full.
+func (s *Subscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if subscriptions, ok := s.bus.subscriptions[s.userID]; ok {
+		delete(subscriptions, s)
+		if len(subscriptions) == 0 {
+			delete(s.bus.subscriptions, s.userID)
+		}
+	}
+	s.closeOnce.Do(func() { close(s.clicks) })
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	bus := NewBus()
+	alice := bus.Subscribe("alice")
+	defer alice.Close()
+	bob := bus.Subscribe("bob")
+	defer bob.Close()
+
+	bus.Publish(Click{ShortURL: "http://localhost:8080/abc", UserID: "alice"})
+
+	select {
+	case click := <-alice.Clicks():
+		assert.Equal(t, "http://localhost:8080/abc", click.ShortURL)
+		assert.False(t, click.Time.IsZero())
+	default:
+		t.Fatal("the owner gets the click")
+	}
+	assert.Len(t, bob.Clicks(), 0, "other users don't get the click")
+}
+
+func TestPublishDropsForSlowWatchers(t *testing.T) {
+	bus := NewBus()
+	slow := bus.Subscribe("alice")
+	defer slow.Close()
+
+	for i := 0; i < bufferSize+3; i++ {
+		bus.Publish(Click{UserID: "alice"})
+	}
+	assert.Len(t, slow.Clicks(), bufferSize)
+	assert.Equal(t, int64(3), slow.TakeDropped())
+	assert.Equal(t, int64(0), slow.TakeDropped())
+}
+
+func TestClose(t *testing.T) {
+	bus := NewBus()
+	subscription := bus.Subscribe("alice")
+	subscription.Close()
+	subscription.Close()
+	_, ok := <-subscription.Clicks()
+	assert.False(t, ok)
+	bus.Publish(Click{UserID: "alice"})
+
+	watching := bus.Subscribe("alice")
+	bus.Close()
+	_, ok = <-watching.Clicks()
+	assert.False(t, ok, "closing the bus ends the subscriptions")
+	watching.Close()
+
+	_, ok = <-bus.Subscribe("alice").Clicks()
+	assert.False(t, ok, "a closed bus returns closed subscriptions")
+
+	var none *Bus
+	none.Publish(Click{UserID: "alice"})
+}
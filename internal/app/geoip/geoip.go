@@ -0,0 +1,53 @@
+// Package geoip resolves the country of client IP addresses with a local MaxMind-format database,
+// such as GeoLite2-Country or GeoIP2-Country.
+package geoip
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// record is the part of a country or city record the service needs.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Reader looks up countries in a GeoIP database.
+// A nil Reader resolves every address to an unknown country, so geo-targeting is skipped when no database is configured.
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// Open loads the database at path.
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{db: db}, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country of the IP address in upper case,
+// or an empty string if it's unknown.
+func (r *Reader) Country(ip net.IP) string {
+	if r == nil || ip == nil {
+		return ""
+	}
+	var rec record
+	if err := r.db.Lookup(ip, &rec); err != nil {
+		return ""
+	}
+	return strings.ToUpper(rec.Country.ISOCode)
+}
+
+// Close releases the database.
+func (r *Reader) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.db.Close()
+}
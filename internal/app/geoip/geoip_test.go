@@ -0,0 +1,27 @@
+package geoip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountry(t *testing.T) {
+	reader, err := Open("testdata/GeoIP2-Country-Test.mmdb")
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, "GB", reader.Country(net.ParseIP("81.2.69.142")))
+	assert.Equal(t, "SE", reader.Country(net.ParseIP("89.160.20.112")))
+	assert.Equal(t, "", reader.Country(net.ParseIP("10.0.0.1")))
+	assert.Equal(t, "", reader.Country(nil))
+
+	var empty *Reader
+	assert.Equal(t, "", empty.Country(net.ParseIP("81.2.69.142")))
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	_, err := Open("testdata/missing.mmdb")
+	assert.Error(t, err)
+}
@@ -2,16 +2,29 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/clicks"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/cookie"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/domains"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/importer"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/redirect"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/repository"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/screening"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/split"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/targeting"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlnormalizer"
 	"github.com/JustWorking42/shortener-go-yandex/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type ShortenerService struct {
@@ -27,16 +40,42 @@ var _ proto.ShortenerServiceServer = &ShortenerService{}
 
 func (s *ShortenerService) ShortUrl(ctx context.Context, req *proto.ShortenURLRequest) (*proto.ShortenURLResponse, error) {
 	userID := ctx.Value(cookie.UserID("UserID")).(string)
-	savedURL, err := s.app.Repository.SaveURL(ctx, req.Url, userID)
+	savedURL, err := s.app.Repository.SaveURL(ctx, req.Url, userID, models.LinkOptions{
+		Domain:         req.Domain,
+		Tags:           req.Tags,
+		RedirectStatus: int(req.RedirectStatus),
+		PassQuery:      req.PassQuery,
+		PassPath:       req.PassPath,
+		UTM:            utmFromProto(req.Utm),
+		Targets:        targetsFromProto(req.Targets),
+		Variants:       variantsFromProto(req.Variants),
+		MaxClicks:      int(req.MaxClicks),
+		NotBefore:      timeFromProto(req.NotBefore),
+		NotAfter:       timeFromProto(req.NotAfter),
+		PlaceholderURL: req.PlaceholderUrl,
+		Interstitial:   req.Interstitial,
+		OpenGraph:      openGraphFromProto(req.OpenGraph),
+	})
 	if err != nil {
 		s.app.Logger.Sugar().Error(err)
-		if savedURL != (storage.SavedURL{}) {
-			return nil, status.Error(codes.AlreadyExists, fmt.Sprintf("%s/%s", s.app.RedirectHost, savedURL.ShortURL))
+		if errors.Is(err, urlnormalizer.ErrInvalidURL) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, screening.ErrBlocked) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, domains.ErrUnknownDomain) || errors.Is(err, repository.ErrInvalidRedirectStatus) || errors.Is(err, targeting.ErrInvalidTarget) || errors.Is(err, split.ErrInvalidVariants) ||
+			errors.Is(err, repository.ErrInvalidMaxClicks) || errors.Is(err, repository.ErrInvalidWindow) ||
+			errors.Is(err, repository.ErrInvalidOpenGraph) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if savedURL.ShortURL != "" {
+			return nil, status.Error(codes.AlreadyExists, s.app.Repository.ShortURL(savedURL.ShortURL))
 		} else {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 	}
-	return &proto.ShortenURLResponse{ShortUrl: fmt.Sprintf("%s/%s", s.app.RedirectHost, savedURL.ShortURL)}, nil
+	return &proto.ShortenURLResponse{ShortUrl: s.app.Repository.ShortURL(savedURL.ShortURL)}, nil
 }
 
 func (s *ShortenerService) ShortUrlsBatch(ctx context.Context, req *proto.ShortenURLsBatchRequest) (*proto.ShortenURLsBatchResponse, error) {
@@ -47,11 +86,27 @@ func (s *ShortenerService) ShortUrlsBatch(ctx context.Context, req *proto.Shorte
 		originalURLsSlice = append(originalURLsSlice, models.RequestShortenerURLBatch{
 			ID:  url.Id,
 			URL: url.Url,
+			LinkOptions: models.LinkOptions{
+				Domain:         url.Domain,
+				Tags:           url.Tags,
+				RedirectStatus: int(url.RedirectStatus),
+				PassQuery:      url.PassQuery,
+				PassPath:       url.PassPath,
+				UTM:            utmFromProto(url.Utm),
+				Targets:        targetsFromProto(url.Targets),
+				Variants:       variantsFromProto(url.Variants),
+				MaxClicks:      int(url.MaxClicks),
+				NotBefore:      timeFromProto(url.NotBefore),
+				NotAfter:       timeFromProto(url.NotAfter),
+				PlaceholderURL: url.PlaceholderUrl,
+				Interstitial:   url.Interstitial,
+				OpenGraph:      openGraphFromProto(url.OpenGraph),
+			},
 		})
 	}
 
-	savedURLsSlice, err := s.app.Repository.SaveURLArray(ctx, originalURLsSlice, userID)
-	if err != nil {
+	savedURLsSlice, err := s.app.Repository.SaveURLArray(ctx, originalURLsSlice, userID, req.Atomic)
+	if err != nil && !errors.Is(err, repository.ErrBatchRejected) {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -59,42 +114,259 @@ func (s *ShortenerService) ShortUrlsBatch(ctx context.Context, req *proto.Shorte
 	for _, savedURL := range savedURLsSlice {
 		shortURLs = append(shortURLs, &proto.ResponseShortenerURLBatch{
 			Id:       savedURL.ID,
-			ShortUrl: fmt.Sprintf("%s/%s", s.app.RedirectHost, savedURL.URL),
+			ShortUrl: savedURL.URL,
+			Status:   savedURL.Status,
+			Reason:   savedURL.Reason,
 		})
 	}
 
 	return &proto.ShortenURLsBatchResponse{Urls: shortURLs}, nil
 }
 
+// GetURL resolves a redirect like the HTTP handler does for a request on the host in req.Domain.
+// Without a domain the ID is a short ID on the default domain or a full short URL.
 func (s *ShortenerService) GetURL(ctx context.Context, req *proto.GetURLRequest) (*proto.GetURLResponse, error) {
-	savedURL, err := s.app.Repository.GetURL(ctx, req.Id)
+	var savedURL storage.SavedURL
+	var err error
+	if req.Domain != "" {
+		savedURL, err = s.app.Repository.GetURLOnHost(ctx, req.Domain, req.Id)
+	} else {
+		savedURL, err = s.app.Repository.GetURL(ctx, req.Id)
+	}
 	if err != nil {
 		return nil, status.Error(codes.NotFound, "URL not found")
 	}
 	if savedURL.IsDeleted {
 		return nil, status.Error(codes.Unavailable, "URL has been deleted")
 	}
-	return &proto.GetURLResponse{OriginalUrl: savedURL.OriginalURL}, nil
+	switch err := redirect.CheckWindow(savedURL, time.Now()); {
+	case errors.Is(err, redirect.ErrNotStarted):
+		if savedURL.PlaceholderURL == "" {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		return &proto.GetURLResponse{OriginalUrl: savedURL.PlaceholderURL, RedirectStatus: http.StatusTemporaryRedirect}, nil
+	case errors.Is(err, redirect.ErrExpired):
+		return nil, status.Error(codes.Unavailable, "URL has expired")
+	}
+	targeted := false
+	if len(savedURL.Targets) > 0 {
+		client := targeting.Parse(req.UserAgent)
+		client.Country = s.app.GeoIP.Country(net.ParseIP(req.ClientIp))
+		var target string
+		if target, targeted = targeting.Select(savedURL.Targets, client); targeted {
+			savedURL.OriginalURL = target
+		}
+	}
+	// The caller keeps the variant of its visitor and passes it back, like the cookie of HTTP redirects.
+	var variantID string
+	if !targeted && len(savedURL.Variants) > 0 {
+		variant, _ := split.Choose(savedURL.Variants, req.Variant)
+		savedURL.OriginalURL = variant.URL
+		variantID = variant.ID
+	}
+	if verdict := s.app.Repository.ScreenURL(savedURL.OriginalURL); verdict.Blocked {
+		return nil, status.Error(codes.PermissionDenied, "URL has been blocked: "+verdict.Reason)
+	}
+	redirectStatus := savedURL.RedirectStatus
+	if redirectStatus == 0 {
+		redirectStatus = s.app.RedirectStatus
+	}
+	if err := s.app.Repository.ConsumeClick(ctx, savedURL); err != nil {
+		if errors.Is(err, storage.ErrClickLimitReached) {
+			return nil, status.Error(codes.ResourceExhausted, "URL has reached its click limit")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.app.RecordClick(savedURL, variantID, clicks.Visit{
+		IP:        net.ParseIP(req.ClientIp),
+		UserAgent: req.UserAgent,
+		Referrer:  req.Referrer,
+	})
+	// Callers that redirect their visitors show their own interstitial page if the link asks for one.
+	return &proto.GetURLResponse{
+		OriginalUrl:    savedURL.OriginalURL,
+		RedirectStatus: int32(redirectStatus),
+		Variant:        variantID,
+		Interstitial:   savedURL.Interstitial,
+		CreatedAt:      timeToProto(savedURL.CreatedAt),
+	}, nil
 }
 
-func (s *ShortenerService) GetUserURLs(ctx context.Context, req *emptypb.Empty) (*proto.GetUserURLsResponse, error) {
+func (s *ShortenerService) GetUserURLs(ctx context.Context, req *proto.GetUserURLsRequest) (*proto.GetUserURLsResponse, error) {
 	userID := ctx.Value(cookie.UserID("UserID")).(string)
-	urls, err := s.app.Repository.GetUserURLs(ctx, userID)
+	filter, err := urlFilterFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := s.app.Repository.GetUserURLs(ctx, userID, filter)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	var userURLs []*proto.GetUserURLs
 	for _, url := range urls {
-		userURLs = append(userURLs, &proto.GetUserURLs{
-			ShortUrl:    fmt.Sprintf("%s/%s", s.app.RedirectHost, url.ShortURL),
-			OriginalUrl: url.OriginalURL,
-		})
+		userURLs = append(userURLs, s.userURLToProto(url))
 	}
 
 	return &proto.GetUserURLsResponse{Urls: userURLs}, nil
 }
 
+func (s *ShortenerService) ExportUserURLs(req *proto.GetUserURLsRequest, stream proto.ShortenerService_ExportUserURLsServer) error {
+	ctx := stream.Context()
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+
+	filter, err := urlFilterFromProto(req)
+	if err != nil {
+		return err
+	}
+	err = s.app.Repository.ExportUserURLs(ctx, userID, filter, func(url storage.SavedURL) error {
+		return stream.Send(s.userURLToProto(url))
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// userURLToProto converts a link of the user with everything it was created with.
+func (s *ShortenerService) userURLToProto(url storage.SavedURL) *proto.GetUserURLs {
+	return &proto.GetUserURLs{
+		ShortUrl:       s.app.Repository.ShortURL(url.ShortURL),
+		OriginalUrl:    url.OriginalURL,
+		Tags:           url.Tags,
+		Utm:            utmToProto(url.UTM),
+		MaxClicks:      int32(url.MaxClicks),
+		ClicksLeft:     int32(url.ClicksLeft),
+		NotBefore:      timeToProto(url.NotBefore),
+		NotAfter:       timeToProto(url.NotAfter),
+		PlaceholderUrl: url.PlaceholderURL,
+		Interstitial:   url.Interstitial,
+		CreatedAt:      timeToProto(url.CreatedAt),
+		OpenGraph:      openGraphToProto(url.OpenGraph),
+		Health:         healthToProto(url.Health),
+		Variants:       variantsToProto(url.Variants),
+		RedirectStatus: int32(url.RedirectStatus),
+		PassQuery:      url.PassQuery,
+		PassPath:       url.PassPath,
+	}
+}
+
+// WatchClicks streams the clicks on the links of the user as they happen until the client goes away.
+// Clicks a slow client doesn't keep up with are dropped and counted in the next event.
+func (s *ShortenerService) WatchClicks(req *emptypb.Empty, stream proto.ShortenerService_WatchClicksServer) error {
+	ctx := stream.Context()
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+
+	subscription := s.app.Events.Subscribe(userID)
+	defer subscription.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case click, ok := <-subscription.Clicks():
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			err := stream.Send(&proto.ClickEvent{
+				ShortUrl:    click.ShortURL,
+				OriginalUrl: click.OriginalURL,
+				Variant:     click.Variant,
+				Time:        timestamppb.New(click.Time),
+				Dropped:     subscription.TakeDropped(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// GetLinkStats returns the click statistics of the user's link within the time range of the request,
+// grouped by hour, day or week if the request asks for it.
+func (s *ShortenerService) GetLinkStats(ctx context.Context, req *proto.GetLinkStatsRequest) (*proto.LinkStats, error) {
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+	filter, err := clickFilterFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.app.Repository.GetLinkStats(ctx, userID, req.Id, filter)
+	if err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &proto.LinkStats{
+		Clicks:    int32(stats.Clicks),
+		Visitors:  int32(stats.Visitors),
+		Variants:  make(map[string]int32, len(stats.Variants)),
+		Referrers: statsCountsToProto(storage.TopCounts(stats.Referrers, storage.TopReferrers)),
+		Browsers:  statsCountsToProto(storage.TopCounts(stats.Browsers, 0)),
+		Os:        statsCountsToProto(storage.TopCounts(stats.OS, 0)),
+		Countries: statsCountsToProto(storage.TopCounts(stats.Countries, 0)),
+	}
+	for variant, clicks := range stats.Variants {
+		response.Variants[variant] = int32(clicks)
+	}
+	for _, bucket := range stats.Buckets {
+		response.Buckets = append(response.Buckets, &proto.StatsBucket{
+			Start:    timestamppb.New(bucket.Start),
+			Clicks:   int32(bucket.Clicks),
+			Visitors: int32(bucket.Visitors),
+		})
+	}
+	return response, nil
+}
+
+func (s *ShortenerService) GetUserTags(ctx context.Context, req *emptypb.Empty) (*proto.GetUserTagsResponse, error) {
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+	tags, err := s.app.Repository.GetUserTags(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var tagCounts []*proto.TagCount
+	for _, tag := range tags {
+		tagCounts = append(tagCounts, &proto.TagCount{
+			Tag:   tag.Tag,
+			Count: int32(tag.Count),
+		})
+	}
+
+	return &proto.GetUserTagsResponse{Tags: tagCounts}, nil
+}
+
+func (s *ShortenerService) UpdateTags(ctx context.Context, req *proto.UpdateTagsRequest) (*emptypb.Empty, error) {
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+	if err := s.app.Repository.UpdateTags(ctx, userID, req.Id, req.Tags); err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *ShortenerService) UpdateVariantWeights(ctx context.Context, req *proto.UpdateVariantWeightsRequest) (*emptypb.Empty, error) {
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+	weights := make(map[string]int, len(req.Weights))
+	for id, weight := range req.Weights {
+		weights[id] = int(weight)
+	}
+	if err := s.app.Repository.UpdateVariantWeights(ctx, userID, req.Id, weights); err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		if errors.Is(err, split.ErrInvalidVariants) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
 func (s *ShortenerService) DeleteURLs(ctx context.Context, req *proto.DeleteURLsRequest) (*emptypb.Empty, error) {
 	userID := ctx.Value(cookie.UserID("UserID")).(string)
 	if err := s.app.Repository.DeleteURLs(ctx, userID, req.Urls); err != nil {
@@ -121,3 +393,215 @@ func (s *ShortenerService) PingDB(ctx context.Context, req *emptypb.Empty) (*emp
 	}
 	return &emptypb.Empty{}, nil
 }
+
+// importStreamReader reads import records from a client stream.
+type importStreamReader struct {
+	stream proto.ShortenerService_ImportURLsServer
+	line   int
+}
+
+// Next implements the importer.Reader interface.
+func (r *importStreamReader) Next() (importer.Record, error) {
+	record, err := r.stream.Recv()
+	if err != nil {
+		return importer.Record{}, err
+	}
+	r.line++
+	return importer.Record{Line: r.line, URL: record.Url, Alias: record.Alias, Domain: record.Domain, Tags: record.Tags}, nil
+}
+
+func (s *ShortenerService) ImportURLs(stream proto.ShortenerService_ImportURLsServer) error {
+	ctx := stream.Context()
+	userID := ctx.Value(cookie.UserID("UserID")).(string)
+
+	job := s.app.Imports.Start(userID)
+	err := s.app.Repository.ImportURLs(ctx, userID, &importStreamReader{stream: stream}, job)
+	job.Finish(err)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	report := job.Report()
+	response := &proto.ImportJob{
+		Id:        report.ID,
+		Status:    string(report.Status),
+		Processed: int32(report.Processed),
+		Created:   int32(report.Created),
+		Existing:  int32(report.Existing),
+		Failed:    int32(report.Failed),
+		Error:     report.Error,
+	}
+	for _, entry := range report.Errors {
+		response.Errors = append(response.Errors, &proto.ImportError{
+			Line:   int32(entry.Line),
+			Url:    entry.URL,
+			Reason: entry.Reason,
+		})
+	}
+	return stream.SendAndClose(response)
+}
+
+// utmFromProto converts the UTM parameters of a request, nil stays nil.
+func utmFromProto(utm *proto.UTM) *models.UTM {
+	if utm == nil {
+		return nil
+	}
+	return &models.UTM{
+		Source:   utm.Source,
+		Medium:   utm.Medium,
+		Campaign: utm.Campaign,
+		Term:     utm.Term,
+		Content:  utm.Content,
+	}
+}
+
+// utmToProto converts the UTM parameters of a link, nil stays nil.
+func utmToProto(utm *models.UTM) *proto.UTM {
+	if utm == nil {
+		return nil
+	}
+	return &proto.UTM{
+		Source:   utm.Source,
+		Medium:   utm.Medium,
+		Campaign: utm.Campaign,
+		Term:     utm.Term,
+		Content:  utm.Content,
+	}
+}
+
+// targetsFromProto converts the targeting rules of a request.
+func targetsFromProto(rules []*proto.TargetRule) []models.TargetRule {
+	var targets []models.TargetRule
+	for _, rule := range rules {
+		targets = append(targets, models.TargetRule{
+			OS:        rule.Os,
+			Device:    rule.Device,
+			Agent:     rule.Agent,
+			Countries: rule.Countries,
+			URL:       rule.Url,
+		})
+	}
+	return targets
+}
+
+// variantsFromProto converts the A/B variants of a request.
+func variantsFromProto(variants []*proto.Variant) []models.Variant {
+	var converted []models.Variant
+	for _, variant := range variants {
+		converted = append(converted, models.Variant{
+			ID:     variant.Id,
+			URL:    variant.Url,
+			Weight: int(variant.Weight),
+		})
+	}
+	return converted
+}
+
+// variantsToProto converts the A/B variants of a link.
+func variantsToProto(variants []models.Variant) []*proto.Variant {
+	var converted []*proto.Variant
+	for _, variant := range variants {
+		converted = append(converted, &proto.Variant{
+			Id:     variant.ID,
+			Url:    variant.URL,
+			Weight: int32(variant.Weight),
+		})
+	}
+	return converted
+}
+
+// openGraphFromProto converts the preview tags of a request, nil stays nil.
+func openGraphFromProto(openGraph *proto.OpenGraph) *models.OpenGraph {
+	if openGraph == nil {
+		return nil
+	}
+	return &models.OpenGraph{
+		Title:       openGraph.Title,
+		Description: openGraph.Description,
+		Image:       openGraph.Image,
+	}
+}
+
+// openGraphToProto converts the preview tags of a link, nil stays nil.
+func openGraphToProto(openGraph *models.OpenGraph) *proto.OpenGraph {
+	if openGraph == nil {
+		return nil
+	}
+	return &proto.OpenGraph{
+		Title:       openGraph.Title,
+		Description: openGraph.Description,
+		Image:       openGraph.Image,
+	}
+}
+
+// urlFilterFromProto converts the filter of a request for the user's links.
+func urlFilterFromProto(req *proto.GetUserURLsRequest) (storage.URLFilter, error) {
+	filter := storage.URLFilter{Tag: req.Tag, Campaign: req.Campaign}
+	switch req.Health {
+	case "":
+	case "broken":
+		filter.Broken = true
+	default:
+		return storage.URLFilter{}, status.Errorf(codes.InvalidArgument, "unknown health filter %q, expected broken", req.Health)
+	}
+	return filter, nil
+}
+
+// clickFilterFromProto converts the time range and the bucket of a stats request.
+func clickFilterFromProto(req *proto.GetLinkStatsRequest) (storage.ClickFilter, error) {
+	var filter storage.ClickFilter
+	if req.From != nil {
+		filter.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		filter.To = req.To.AsTime()
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return storage.ClickFilter{}, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+	bucket, err := storage.ParseStatsBucket(req.Bucket)
+	if err != nil {
+		return storage.ClickFilter{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	filter.Bucket = bucket
+	return filter, nil
+}
+
+// statsCountsToProto converts a breakdown of clicks.
+func statsCountsToProto(counts []storage.Count) []*proto.StatsCount {
+	converted := make([]*proto.StatsCount, len(counts))
+	for i, count := range counts {
+		converted[i] = &proto.StatsCount{Name: count.Name, Clicks: int32(count.Clicks)}
+	}
+	return converted
+}
+
+// healthToProto converts the result of the last check of a link, nil stays nil.
+func healthToProto(health *models.LinkHealth) *proto.LinkHealth {
+	if health == nil {
+		return nil
+	}
+	return &proto.LinkHealth{
+		Status:    int32(health.Status),
+		Error:     health.Error,
+		Broken:    health.Broken,
+		CheckedAt: timestamppb.New(health.CheckedAt),
+	}
+}
+
+// timeFromProto converts an optional timestamp of a request, nil stays nil.
+func timeFromProto(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := t.AsTime()
+	return &converted
+}
+
+// timeToProto converts an optional time of a link, nil stays nil.
+func timeToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
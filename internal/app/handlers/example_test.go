@@ -55,7 +55,7 @@ func ExampleHandleShortenPostArray() {
 	ctrl := gomock.NewController(nil)
 	defer ctrl.Finish()
 	mockStorage := mocks.NewMockStorage(ctrl)
-	mockStorage.EXPECT().SaveArray(gomock.Any(), gomock.Any()).Return(nil)
+	mockStorage.EXPECT().SaveArray(gomock.Any(), gomock.Any(), false).DoAndReturn(saveAllCreated)
 	server := httptest.NewServer(Webhook(mockApp(nil, mockStorage)))
 	defer server.Close()
 
@@ -75,7 +75,7 @@ func ExampleHandleGetUserURLs() {
 	ctrl := gomock.NewController(nil)
 	defer ctrl.Finish()
 	mockStorage := mocks.NewMockStorage(ctrl)
-	mockStorage.EXPECT().GetByUser(gomock.Any(), gomock.Any()).Return([]storage.SavedURL{
+	mockStorage.EXPECT().GetByUser(gomock.Any(), gomock.Any(), gomock.Any()).Return([]storage.SavedURL{
 		{
 			OriginalURL: "https://example.com",
 			ShortURL:    "shortURL",
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+)
+
+// errUnknownExportFormat is returned for unsupported export formats.
+var errUnknownExportFormat = errors.New("unknown export format")
+
+// exportTagSeparator separates tags inside a single CSV cell, as expected by the import.
+const exportTagSeparator = "|"
+
+// exportWriter writes exported links one by one.
+type exportWriter interface {
+	// Write writes a single link.
+	Write(url models.ResponseUserURL) error
+	// Close writes the end of the output.
+	Close() error
+}
+
+// newExportWriter creates a writer for the format and returns the content type and file name of the output.
+func newExportWriter(format string, w io.Writer) (exportWriter, string, string, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		return &jsonExportWriter{w: w, encoder: json.NewEncoder(w)}, "application/json", "urls.json", nil
+	case "ndjson":
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, "application/x-ndjson", "urls.ndjson", nil
+	case "csv":
+		return &csvExportWriter{writer: csv.NewWriter(w)}, "text/csv", "urls.csv", nil
+	}
+	return nil, "", "", errUnknownExportFormat
+}
+
+// jsonExportWriter writes links as a JSON array.
+type jsonExportWriter struct {
+	w       io.Writer
+	encoder *json.Encoder
+	count   int
+}
+
+// Write implements the exportWriter interface.
+func (j *jsonExportWriter) Write(url models.ResponseUserURL) error {
+	separator := ","
+	if j.count == 0 {
+		separator = "["
+	}
+	j.count++
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	return j.encoder.Encode(url)
+}
+
+// Close implements the exportWriter interface.
+func (j *jsonExportWriter) Close() error {
+	end := "]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+// ndjsonExportWriter writes one JSON object per line.
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+// Write implements the exportWriter interface.
+func (n *ndjsonExportWriter) Write(url models.ResponseUserURL) error {
+	return n.encoder.Encode(url)
+}
+
+// Close implements the exportWriter interface.
+func (n *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// csvExportWriter writes links as CSV with a header row.
+type csvExportWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+// Write implements the exportWriter interface.
+func (c *csvExportWriter) Write(url models.ResponseUserURL) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.writer.Write([]string{url.ShortURL, url.OriginalURL, strings.Join(url.Tags, exportTagSeparator)})
+}
+
+// Close implements the exportWriter interface.
+func (c *csvExportWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// writeHeader writes the header row once.
+func (c *csvExportWriter) writeHeader() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.writer.Write([]string{"short_url", "original_url", "tags"})
+}
@@ -8,14 +8,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app"
 	accesscontrol "github.com/JustWorking42/shortener-go-yandex/internal/app/accessControl"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/clicks"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/compression"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/cookie"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/domains"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/importer"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/logger"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/redirect"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/repository"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/screening"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/split"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/targeting"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/unfurl"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlnormalizer"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -23,6 +37,10 @@ import (
 
 const (
 	incorectData = "Incorrect Data"
+	// eventsKeepAlive is how often an idle click stream sends a comment, so proxies keep the connection open.
+	eventsKeepAlive = 30 * time.Second
+	// eventsWriteTimeout limits writing an event to a watcher, a stuck connection is closed after it.
+	eventsWriteTimeout = 10 * time.Second
 )
 
 // Webhook sets up the HTTP server and defines the routes.
@@ -65,7 +83,60 @@ func Webhook(app *app.App) *chi.Mux {
 		HandleGetStats(app, w, r)
 	}
 
+	handleGetUserTags := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetUserTags(app, w, r)
+	}
+
+	handleUpdateTags := func(w http.ResponseWriter, r *http.Request) {
+		HandleUpdateTags(app, w, r)
+	}
+
+	handleUpdateWeights := func(w http.ResponseWriter, r *http.Request) {
+		HandleUpdateWeights(app, w, r)
+	}
+
+	handleGetLinkStats := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetLinkStats(app, w, r)
+	}
+
+	handleExportUserURLs := func(w http.ResponseWriter, r *http.Request) {
+		HandleExportUserURLs(app, w, r)
+	}
+
+	handleImport := func(w http.ResponseWriter, r *http.Request) {
+		HandleImport(app, w, r)
+	}
+
+	handleGetImports := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetImports(app, w, r)
+	}
+
+	handleGetImport := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetImport(app, w, r)
+	}
+
+	handleCreateWebhook := func(w http.ResponseWriter, r *http.Request) {
+		HandleCreateWebhook(app, w, r)
+	}
+
+	handleGetWebhooks := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetWebhooks(app, w, r)
+	}
+
+	handleDeleteWebhook := func(w http.ResponseWriter, r *http.Request) {
+		HandleDeleteWebhook(app, w, r)
+	}
+
+	handleGetWebhookDeliveries := func(w http.ResponseWriter, r *http.Request) {
+		HandleGetWebhookDeliveries(app, w, r)
+	}
+
+	handleEvents := func(w http.ResponseWriter, r *http.Request) {
+		HandleEvents(app, w, r)
+	}
+
 	router.Get("/{id}", combinedMiddleware(app, handleGetRequest))
+	router.Get("/{id}/*", combinedMiddleware(app, handleGetRequest))
 
 	router.Post("/", combinedMiddleware(app, handlePostRequest))
 
@@ -77,8 +148,34 @@ func Webhook(app *app.App) *chi.Mux {
 
 	router.Get("/api/user/urls", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetUserURLs)))
 
+	router.Get("/api/user/urls/export", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleExportUserURLs)))
+
 	router.Delete("/api/user/urls", combinedMiddleware(app, handleDelete))
 
+	router.Put("/api/user/urls/{id}/tags", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleUpdateTags)))
+
+	router.Put("/api/user/urls/{id}/variants", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleUpdateWeights)))
+
+	router.Get("/api/user/urls/{id}/stats", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetLinkStats)))
+
+	router.Get("/api/user/tags", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetUserTags)))
+
+	router.Post("/api/user/webhooks", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleCreateWebhook)))
+
+	router.Get("/api/user/webhooks", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetWebhooks)))
+
+	router.Delete("/api/user/webhooks/{id}", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleDeleteWebhook)))
+
+	router.Get("/api/user/webhooks/{id}/deliveries", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetWebhookDeliveries)))
+
+	router.Get("/api/user/events", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleEvents)))
+
+	router.Post("/api/import", combinedMiddleware(app, handleImport))
+
+	router.Get("/api/import", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetImports)))
+
+	router.Get("/api/import/{id}", cookie.OnlyAuthorizedMiddleware(app, combinedMiddleware(app, handleGetImport)))
+
 	router.Get("/api/internal/stats", accesscontrol.CidrAccessMiddleware(app, combinedMiddleware(app, handleGetStats)))
 
 	router.MethodNotAllowed(func(w http.ResponseWriter, _ *http.Request) {
@@ -111,10 +208,13 @@ func HandleShortenPost(app *app.App, w http.ResponseWriter, r *http.Request) {
 
 	statusCode := http.StatusCreated
 
-	savedURL, err := app.Repository.SaveURL(r.Context(), originalURL.URL, userID)
+	savedURL, err := app.Repository.SaveURL(r.Context(), originalURL.URL, userID, originalURL.LinkOptions)
 	if err != nil {
 		app.Logger.Sugar().Error(err)
-		if savedURL != (storage.SavedURL{}) {
+		if sendURLError(w, err) {
+			return
+		}
+		if savedURL.ShortURL != "" {
 			statusCode = http.StatusConflict
 		} else {
 			sendError(w, err, incorectData, http.StatusBadRequest)
@@ -122,7 +222,7 @@ func HandleShortenPost(app *app.App, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response := models.NewResponseShortURL(fmt.Sprintf("%s/%s", app.RedirectHost, savedURL.ShortURL))
+	response := models.NewResponseShortURL(app.Repository.ShortURL(savedURL.ShortURL))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -134,9 +234,25 @@ func HandleShortenPost(app *app.App, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleGetRequest handles GET requests to "/{id}" and "/{id}/*" and redirects to the original URL.
+// The trailing path and the query string are forwarded if the link allows it.
+// "/{id}+" and "?preview=1" show a page with the destination instead of redirecting,
+// links with an interstitial always show it. Link preview crawlers get a page with the preview tags of the link.
 func HandleGetRequest(app *app.App, w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	savedURL, err := app.Repository.GetURL(r.Context(), id)
+	rawID := chi.URLParam(r, "id")
+	id, preview := strings.CutSuffix(rawID, "+")
+	query := r.URL.Query()
+	if query.Has("preview") {
+		value, err := strconv.ParseBool(query.Get("preview"))
+		if err != nil {
+			sendError(w, err, incorectData, http.StatusBadRequest)
+			return
+		}
+		preview = preview || value
+		query.Del("preview")
+	}
+
+	savedURL, err := app.Repository.GetURLOnHost(r.Context(), r.Host, id)
 
 	if err != nil {
 		app.Logger.Sugar().Error(err)
@@ -149,8 +265,157 @@ func HandleGetRequest(app *app.App, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Location", savedURL.OriginalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	switch err := redirect.CheckWindow(savedURL, time.Now()); {
+	case errors.Is(err, redirect.ErrNotStarted):
+		if savedURL.PlaceholderURL == "" {
+			sendError(w, err, "Not found", http.StatusNotFound)
+			return
+		}
+		// The placeholder must not be cached past the opening of the window.
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Location", savedURL.PlaceholderURL)
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	case errors.Is(err, redirect.ErrExpired):
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	targeted := false
+	if len(savedURL.Targets) > 0 {
+		client := targeting.Parse(r.UserAgent())
+		if targeting.NeedsCountry(savedURL.Targets) {
+			client.Country = app.GeoIP.Country(app.ClientIP.FromRequest(r))
+		}
+		var target string
+		if target, targeted = targeting.Select(savedURL.Targets, client); targeted {
+			savedURL.OriginalURL = target
+		}
+	}
+
+	// Targeted clients skip the A/B split, the split only divides the rest of the traffic.
+	var variantID string
+	if !targeted && len(savedURL.Variants) > 0 {
+		variant, fresh := split.Choose(savedURL.Variants, split.FromRequest(r))
+		if fresh && !preview {
+			http.SetCookie(w, split.Cookie("/"+id, variant))
+		}
+		savedURL.OriginalURL = variant.URL
+		variantID = variant.ID
+	}
+
+	if verdict := app.Repository.ScreenURL(savedURL.OriginalURL); verdict.Blocked {
+		renderPage(w, http.StatusForbidden, warningPage, verdict)
+		return
+	}
+
+	extraPath := strings.TrimPrefix(r.URL.EscapedPath(), "/"+rawID)
+	destination, err := redirect.Destination(savedURL, extraPath, query)
+	switch {
+	case errors.Is(err, redirect.ErrPathNotAllowed):
+		sendError(w, err, "Not found", http.StatusNotFound)
+		return
+	case err != nil:
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
+	statusCode := savedURL.RedirectStatus
+	if statusCode == 0 {
+		statusCode = app.RedirectStatus
+	}
+	w.Header().Set("Cache-Control", redirectCacheControl(statusCode))
+	if len(savedURL.Targets) > 0 {
+		w.Header().Add("Vary", "User-Agent")
+	}
+	if targeting.NeedsCountry(savedURL.Targets) {
+		// No header tells a shared cache the location of the client, it would send everyone to one country.
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	if len(savedURL.Variants) > 0 {
+		// A cached redirect would pin every visitor to one variant and ignore weight changes.
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Add("Vary", "Cookie")
+	}
+	// Crawlers unfurl the link when it is shared, they don't visit it: no click is taken or recorded.
+	if unfurl.IsCrawler(r.UserAgent()) {
+		page := unfurlData{ShortURL: app.Repository.ShortURL(savedURL.ShortURL), Destination: destination}
+		if savedURL.MaxClicks > 0 {
+			// Fetching the destination would reveal it without using up a click.
+			page.Destination = ""
+			if savedURL.OpenGraph != nil {
+				page.Tags = *savedURL.OpenGraph
+			}
+		} else {
+			page.Tags = app.Unfurler.Tags(r.Context(), destination, savedURL.OpenGraph)
+		}
+		w.Header().Set("Cache-Control", "private, no-cache")
+		renderPage(w, http.StatusOK, unfurlPage, page)
+		return
+	}
+
+	// The click is taken only when the redirect is served, so rejected requests don't use up the limit.
+	// A preview reveals the destination as well, so it takes a click too.
+	if err := app.Repository.ConsumeClick(r.Context(), savedURL); err != nil {
+		if errors.Is(err, storage.ErrClickLimitReached) {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, "Failed to get URL", http.StatusInternalServerError)
+		return
+	}
+	if savedURL.NotAfter != nil {
+		// A permanent redirect cached by the browser would outlive the window.
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	if savedURL.MaxClicks > 0 {
+		// Every visit has to reach the server to be counted against the limit.
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	if preview || savedURL.Interstitial {
+		if savedURL.MaxClicks == 0 {
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+		renderPage(w, http.StatusOK, previewPage, linkPreview{
+			ShortURL:    app.Repository.ShortURL(savedURL.ShortURL),
+			Destination: destination,
+			CreatedAt:   savedURL.CreatedAt,
+		})
+	} else {
+		w.Header().Set("Location", destination)
+		w.WriteHeader(statusCode)
+	}
+
+	// Previews asked for by the visitor are not clicks, an interstitial replaces the redirect and is.
+	if !preview {
+		app.RecordClick(savedURL, variantID, clicks.Visit{
+			IP:        app.ClientIP.FromRequest(r),
+			UserAgent: r.UserAgent(),
+			Referrer:  r.Referer(),
+		})
+	}
+}
+
+// clicksLeft returns the number of clicks the link has left, or nil if the link has no limit.
+func clicksLeft(url storage.SavedURL) *int {
+	if url.MaxClicks <= 0 {
+		return nil
+	}
+	left := url.ClicksLeft
+	return &left
+}
+
+// redirectCacheControl returns the Cache-Control header for a redirect status.
+// Permanent redirects may be cached by browsers and proxies, temporary ones are checked on every visit.
+func redirectCacheControl(statusCode int) string {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		return "public, max-age=86400"
+	default:
+		return "private, no-cache"
+	}
 }
 
 // HandlePostRequest handles POST requests to "/".
@@ -180,12 +445,57 @@ func HandlePostRequest(app *app.App, w http.ResponseWriter, r *http.Request) {
 
 	link := string(body)
 
+	options := models.LinkOptions{Domain: r.URL.Query().Get("domain"), Tags: r.URL.Query()["tag"]}
+	if value := r.URL.Query().Get("redirect_status"); value != "" {
+		options.RedirectStatus, err = strconv.Atoi(value)
+		if err != nil {
+			sendError(w, err, incorectData, http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("max_clicks"); value != "" {
+		options.MaxClicks, err = strconv.Atoi(value)
+		if err != nil {
+			sendError(w, err, incorectData, http.StatusBadRequest)
+			return
+		}
+	}
+	if options.NotBefore, err = parseTimeParam(r, "not_before"); err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+	if options.NotAfter, err = parseTimeParam(r, "not_after"); err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+	options.PlaceholderURL = r.URL.Query().Get("placeholder_url")
+	if options.Interstitial, err = parseBoolParam(r, "interstitial"); err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+	options.OpenGraph = &models.OpenGraph{
+		Title:       r.URL.Query().Get("og_title"),
+		Description: r.URL.Query().Get("og_description"),
+		Image:       r.URL.Query().Get("og_image"),
+	}
+	if options.PassQuery, err = parseBoolParam(r, "pass_query"); err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+	if options.PassPath, err = parseBoolParam(r, "pass_path"); err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
 	statusCode := http.StatusCreated
 
-	savedURL, err := app.Repository.SaveURL(r.Context(), link, userID)
+	savedURL, err := app.Repository.SaveURL(r.Context(), link, userID, options)
 	if err != nil {
 		app.Logger.Sugar().Error(err)
-		if savedURL != (storage.SavedURL{}) {
+		if sendURLError(w, err) {
+			return
+		}
+		if savedURL.ShortURL != "" {
 			statusCode = http.StatusConflict
 		} else {
 			sendError(w, err, incorectData, http.StatusBadRequest)
@@ -193,7 +503,7 @@ func HandlePostRequest(app *app.App, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response := fmt.Sprintf("%s/%s", app.RedirectHost, savedURL.ShortURL)
+	response := app.Repository.ShortURL(savedURL.ShortURL)
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(statusCode)
@@ -201,6 +511,7 @@ func HandlePostRequest(app *app.App, w http.ResponseWriter, r *http.Request) {
 }
 
 // HandleShortenPostArray handles POST requests to "/api/shorten/batch".
+// Every URL gets its own result. With "?atomic=true" nothing is saved unless every URL can be created.
 func HandleShortenPostArray(app *app.App, w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(cookie.UserID("UserID")).(string)
 	var originalURLsSlice []models.RequestShortenerURLBatch
@@ -213,15 +524,21 @@ func HandleShortenPostArray(app *app.App, w http.ResponseWriter, r *http.Request
 
 	defer r.Body.Close()
 
-	savedURLsSlice, err := app.Repository.SaveURLArray(r.Context(), originalURLsSlice, userID)
+	atomic, err := parseBoolParam(r, "atomic")
 	if err != nil {
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
+	savedURLsSlice, err := app.Repository.SaveURLArray(r.Context(), originalURLsSlice, userID, atomic)
+	if err != nil && !errors.Is(err, repository.ErrBatchRejected) {
 		app.Logger.Sugar().Error(err)
 		sendError(w, err, incorectData, http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(batchStatusCode(savedURLsSlice))
 
 	if err := json.NewEncoder(w).Encode(savedURLsSlice); err != nil {
 		app.Logger.Sugar().Error(err)
@@ -229,6 +546,98 @@ func HandleShortenPostArray(app *app.App, w http.ResponseWriter, r *http.Request
 	}
 }
 
+// batchStatusCode returns 201 if any URL of the batch was created or the batch is empty,
+// otherwise 422 if any URL was invalid and 409 if the URLs already exist.
+func batchStatusCode(results []models.ResponseShortenerURLBatch) int {
+	statusCode := http.StatusCreated
+	for _, result := range results {
+		switch storage.SaveStatus(result.Status) {
+		case storage.SaveCreated:
+			return http.StatusCreated
+		case storage.SaveInvalid:
+			statusCode = http.StatusUnprocessableEntity
+		case storage.SaveExisting:
+			if statusCode != http.StatusUnprocessableEntity {
+				statusCode = http.StatusConflict
+			}
+		}
+	}
+	return statusCode
+}
+
+// parseBoolParam parses an optional boolean query parameter.
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseTimeParam parses an optional RFC 3339 time query parameter.
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// HandleImport handles POST requests to "/api/import".
+// The body is a CSV or NDJSON stream selected by "?format=" or the Content-Type header.
+// Records are saved in chunks while the body is read, and the progress can be followed on "/api/import".
+// The response is the final job report, sent with 400 if the body stops being readable
+// and with 500 if the links can't be saved.
+func HandleImport(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	defer r.Body.Close()
+
+	format := importer.DetectFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	reader, err := importer.NewReader(format, r.Body)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		sendJSONError(w, err, "", http.StatusBadRequest)
+		return
+	}
+
+	job := app.Imports.Start(userID)
+	err = app.Repository.ImportURLs(r.Context(), userID, reader, job)
+	if err != nil {
+		app.Logger.Sugar().Errorf("import error: %v", err)
+	}
+	job.Finish(err)
+
+	statusCode := http.StatusOK
+	switch {
+	case errors.Is(err, importer.ErrMalformedImport):
+		statusCode = http.StatusBadRequest
+	case err != nil:
+		statusCode = http.StatusInternalServerError
+	}
+	sendJSON(app, w, job.Report(), statusCode)
+}
+
+// HandleGetImports handles GET requests to "/api/import" and returns the user's recent import jobs.
+func HandleGetImports(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	sendJSON(app, w, app.Imports.List(userID), http.StatusOK)
+}
+
+// HandleGetImport handles GET requests to "/api/import/{id}" and returns the progress of an import job.
+func HandleGetImport(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	report, ok := app.Imports.Get(userID, chi.URLParam(r, "id"))
+	if !ok {
+		sendError(w, errors.New("import not found"), "Not Found", http.StatusNotFound)
+		return
+	}
+	sendJSON(app, w, report, http.StatusOK)
+}
+
 // PingDB checks the connection to the database.
 func PingDB(app *app.App, w http.ResponseWriter, r *http.Request) {
 	err := app.Repository.PingDB(r.Context())
@@ -245,6 +654,56 @@ func sendError(w http.ResponseWriter, err error, message string, statusCode int)
 	http.Error(w, message, statusCode)
 }
 
+// sendURLError sends a structured response if err means that the URL was rejected:
+// 422 for invalid URLs and 403 for blocked ones. It reports whether the response was sent.
+func sendURLError(w http.ResponseWriter, err error) bool {
+	var validationErr *urlnormalizer.ValidationError
+	var blockedErr *screening.BlockedError
+
+	switch {
+	case errors.As(err, &validationErr):
+		sendJSONError(w, urlnormalizer.ErrInvalidURL, validationErr.Reason, http.StatusUnprocessableEntity)
+	case errors.As(err, &blockedErr):
+		sendJSONError(w, screening.ErrBlocked, blockedErr.Verdict.Reason, http.StatusForbidden)
+	case errors.Is(err, domains.ErrUnknownDomain):
+		sendJSONError(w, domains.ErrUnknownDomain, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidRedirectStatus):
+		sendJSONError(w, repository.ErrInvalidRedirectStatus, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, targeting.ErrInvalidTarget):
+		sendJSONError(w, targeting.ErrInvalidTarget, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, split.ErrInvalidVariants):
+		sendJSONError(w, split.ErrInvalidVariants, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidMaxClicks):
+		sendJSONError(w, repository.ErrInvalidMaxClicks, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidWindow):
+		sendJSONError(w, repository.ErrInvalidWindow, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidOpenGraph):
+		sendJSONError(w, repository.ErrInvalidOpenGraph, err.Error(), http.StatusBadRequest)
+	default:
+		return false
+	}
+	return true
+}
+
+// sendJSON sends a JSON response with the given status code.
+func sendJSON(app *app.App, w http.ResponseWriter, response any, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		app.Logger.Sugar().Error(err)
+	}
+}
+
+// sendJSONError sends an error response with a JSON body.
+func sendJSONError(w http.ResponseWriter, err error, reason string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ResponseError{
+		Error:  err.Error(),
+		Reason: reason,
+	})
+}
+
 // combinedMiddleware combines several middleware functions into one.
 func combinedMiddleware(app *app.App, h http.HandlerFunc) http.HandlerFunc {
 	return cookie.CookieCheckMiddleware(app, compression.GzipRequestMiddleware(
@@ -260,9 +719,16 @@ func combinedMiddleware(app *app.App, h http.HandlerFunc) http.HandlerFunc {
 }
 
 // HandleGetUserURLs retrieves all URLs associated with a user.
+// The list can be narrowed down with the "tag", "campaign" and "health" query parameters,
+// "?health=broken" lists the links whose destination was broken when last checked.
 func HandleGetUserURLs(app *app.App, w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(cookie.UserID("UserID")).(string)
-	urls, err := app.Repository.GetUserURLs(r.Context(), userID)
+	filter, err := parseURLFilter(r)
+	if err != nil {
+		sendJSONError(w, err, "", http.StatusBadRequest)
+		return
+	}
+	urls, err := app.Repository.GetUserURLs(r.Context(), userID, filter)
 
 	if err != nil {
 		app.Logger.Sugar().Error(err)
@@ -275,11 +741,23 @@ func HandleGetUserURLs(app *app.App, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := make([]map[string]string, len(urls))
+	response := make([]models.ResponseUserURL, len(urls))
 	for i, url := range urls {
-		response[i] = map[string]string{
-			"short_url":    fmt.Sprintf("%s/%s", app.RedirectHost, url.ShortURL),
-			"original_url": url.OriginalURL,
+		response[i] = models.ResponseUserURL{
+			ShortURL:       app.Repository.ShortURL(url.ShortURL),
+			OriginalURL:    url.OriginalURL,
+			Tags:           url.Tags,
+			UTM:            url.UTM,
+			Variants:       url.Variants,
+			MaxClicks:      url.MaxClicks,
+			ClicksLeft:     clicksLeft(url),
+			NotBefore:      url.NotBefore,
+			NotAfter:       url.NotAfter,
+			PlaceholderURL: url.PlaceholderURL,
+			Interstitial:   url.Interstitial,
+			CreatedAt:      url.CreatedAt,
+			OpenGraph:      url.OpenGraph,
+			Health:         url.Health,
 		}
 	}
 
@@ -290,6 +768,73 @@ func HandleGetUserURLs(app *app.App, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseURLFilter reads the filter of the user's links from the query string.
+func parseURLFilter(r *http.Request) (storage.URLFilter, error) {
+	filter := storage.URLFilter{Tag: r.URL.Query().Get("tag"), Campaign: r.URL.Query().Get("campaign")}
+	switch health := r.URL.Query().Get("health"); health {
+	case "":
+	case "broken":
+		filter.Broken = true
+	default:
+		return storage.URLFilter{}, fmt.Errorf("unknown health filter %q, expected broken", health)
+	}
+	return filter, nil
+}
+
+// HandleExportUserURLs handles GET requests to "/api/user/urls/export".
+// The user's links are written in the format given by "?format=" (json, ndjson or csv)
+// while they are read from the storage. "?tag=" limits the export to one tag.
+func HandleExportUserURLs(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	filter, err := parseURLFilter(r)
+	if err != nil {
+		sendJSONError(w, err, "", http.StatusBadRequest)
+		return
+	}
+
+	writer, contentType, fileName, err := newExportWriter(r.URL.Query().Get("format"), w)
+	if err != nil {
+		sendJSONError(w, err, "", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	written := false
+	err = app.Repository.ExportUserURLs(r.Context(), userID, filter, func(url storage.SavedURL) error {
+		written = true
+		return writer.Write(models.ResponseUserURL{
+			ShortURL:       app.Repository.ShortURL(url.ShortURL),
+			OriginalURL:    url.OriginalURL,
+			Tags:           url.Tags,
+			UTM:            url.UTM,
+			Variants:       url.Variants,
+			MaxClicks:      url.MaxClicks,
+			ClicksLeft:     clicksLeft(url),
+			NotBefore:      url.NotBefore,
+			NotAfter:       url.NotAfter,
+			PlaceholderURL: url.PlaceholderURL,
+			Interstitial:   url.Interstitial,
+			CreatedAt:      url.CreatedAt,
+			OpenGraph:      url.OpenGraph,
+			Health:         url.Health,
+		})
+	})
+	if err != nil && !written {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, "Failed to export URLs", http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// The response is already being sent, so the error can only be logged.
+		app.Logger.Sugar().Errorf("export error: %v", err)
+	}
+}
+
 // HandleDeleteURLs deletes specified URLs.
 func HandleDeleteURLs(app *app.App, w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(cookie.UserID("UserID")).(string)
@@ -324,3 +869,337 @@ func HandleGetStats(app *app.App, w http.ResponseWriter, r *http.Request) {
 		sendError(w, err, "Failed to encode response", http.StatusBadRequest)
 	}
 }
+
+// HandleGetUserTags returns the tags of the user's links with the number of links per tag.
+func HandleGetUserTags(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	tags, err := app.Repository.GetUserTags(r.Context(), userID)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, "Failed to get tags", http.StatusBadRequest)
+		return
+	}
+
+	if len(tags) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, "Failed to encode response", http.StatusBadRequest)
+	}
+}
+
+// HandleUpdateTags replaces the tags of the user's link.
+func HandleUpdateTags(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	id := chi.URLParam(r, "id")
+
+	var request models.RequestUpdateTags
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	err := app.Repository.UpdateTags(r.Context(), userID, id, request.Tags)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			sendError(w, err, "URL not found", http.StatusNotFound)
+			return
+		}
+		sendError(w, err, "Failed to update tags", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUpdateWeights changes the weights of the A/B variants of the user's link.
+func HandleUpdateWeights(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	id := chi.URLParam(r, "id")
+
+	var request models.RequestUpdateWeights
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	err := app.Repository.UpdateVariantWeights(r.Context(), userID, id, request.Weights)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		switch {
+		case errors.Is(err, storage.ErrURLNotFound):
+			sendError(w, err, "URL not found", http.StatusNotFound)
+		case errors.Is(err, split.ErrInvalidVariants):
+			sendJSONError(w, split.ErrInvalidVariants, err.Error(), http.StatusBadRequest)
+		default:
+			sendError(w, err, "Failed to update weights", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetLinkStats returns the click statistics of the user's link.
+// "?from=" and "?to=" limit the clicks to a time range given in RFC 3339,
+// "?bucket=" groups them by hour, day or week.
+func HandleGetLinkStats(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	id := chi.URLParam(r, "id")
+
+	filter, err := parseClickFilter(r)
+	if err != nil {
+		sendJSONError(w, err, "", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := app.Repository.GetLinkStats(r.Context(), userID, id, filter)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			sendError(w, err, "URL not found", http.StatusNotFound)
+			return
+		}
+		sendError(w, err, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.ResponseLinkStats{
+		Clicks:    stats.Clicks,
+		Visitors:  stats.Visitors,
+		Variants:  stats.Variants,
+		Referrers: statsCounts(storage.TopCounts(stats.Referrers, storage.TopReferrers)),
+		Browsers:  statsCounts(storage.TopCounts(stats.Browsers, 0)),
+		OS:        statsCounts(storage.TopCounts(stats.OS, 0)),
+		Countries: statsCounts(storage.TopCounts(stats.Countries, 0)),
+	}
+	for _, bucket := range stats.Buckets {
+		response.Buckets = append(response.Buckets, models.ResponseStatsBucket{
+			Start:    bucket.Start,
+			Clicks:   bucket.Clicks,
+			Visitors: bucket.Visitors,
+		})
+	}
+	sendJSON(app, w, response, http.StatusOK)
+}
+
+// parseClickFilter reads the time range and the bucket of link statistics from the query.
+func parseClickFilter(r *http.Request) (storage.ClickFilter, error) {
+	var filter storage.ClickFilter
+	var err error
+	query := r.URL.Query()
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return storage.ClickFilter{}, fmt.Errorf("invalid from time: %w", err)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return storage.ClickFilter{}, fmt.Errorf("invalid to time: %w", err)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return storage.ClickFilter{}, errors.New("from must be before to")
+	}
+	if filter.Bucket, err = storage.ParseStatsBucket(query.Get("bucket")); err != nil {
+		return storage.ClickFilter{}, err
+	}
+	return filter, nil
+}
+
+// statsCounts converts a breakdown of clicks to its response.
+func statsCounts(counts []storage.Count) []models.ResponseStatsCount {
+	response := make([]models.ResponseStatsCount, len(counts))
+	for i, count := range counts {
+		response[i] = models.ResponseStatsCount{Name: count.Name, Clicks: count.Clicks}
+	}
+	return response
+}
+
+// HandleCreateWebhook registers a webhook that receives the events of the user's links.
+// The response holds the secret the deliveries are signed with, it is not returned again.
+func HandleCreateWebhook(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+
+	var request models.RequestWebhook
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, incorectData, http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	webhook, err := app.Webhooks.Register(r.Context(), userID, request.URL, request.Events)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		switch {
+		case errors.Is(err, webhooks.ErrInvalidWebhook):
+			sendJSONError(w, webhooks.ErrInvalidWebhook, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, webhooks.ErrTooManyWebhooks):
+			sendJSONError(w, webhooks.ErrTooManyWebhooks, err.Error(), http.StatusConflict)
+		default:
+			sendError(w, err, "Failed to register webhook", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := webhookResponse(webhook)
+	response.Secret = webhook.Secret
+	sendJSON(app, w, response, http.StatusCreated)
+}
+
+// HandleGetWebhooks returns the webhooks of the user without their secrets.
+func HandleGetWebhooks(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	list, err := app.Webhooks.List(r.Context(), userID)
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		sendError(w, err, "Failed to get webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	if len(list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := make([]models.ResponseWebhook, len(list))
+	for i, webhook := range list {
+		response[i] = webhookResponse(webhook)
+	}
+	sendJSON(app, w, response, http.StatusOK)
+}
+
+// HandleDeleteWebhook removes a webhook of the user, its pending deliveries are not sent.
+func HandleDeleteWebhook(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	err := app.Webhooks.Delete(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			sendError(w, err, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		sendError(w, err, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetWebhookDeliveries returns the latest deliveries of a webhook of the user, newest first.
+func HandleGetWebhookDeliveries(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	deliveries, err := app.Webhooks.Deliveries(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		app.Logger.Sugar().Error(err)
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			sendError(w, err, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		sendError(w, err, "Failed to get deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]models.ResponseWebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		response[i] = models.ResponseWebhookDelivery{
+			ID:             delivery.ID,
+			Event:          delivery.Event,
+			Status:         string(delivery.Status),
+			Attempts:       delivery.Attempts,
+			ResponseStatus: delivery.ResponseStatus,
+			Error:          delivery.Error,
+			CreatedAt:      delivery.CreatedAt,
+			DeliveredAt:    delivery.DeliveredAt,
+			Payload:        delivery.Payload,
+		}
+		if delivery.Status == storage.DeliveryPending {
+			next := delivery.NextAttempt
+			response[i].NextAttempt = &next
+		}
+	}
+	sendJSON(app, w, response, http.StatusOK)
+}
+
+// HandleEvents handles GET requests to "/api/user/events".
+// The clicks on the links of the user are streamed as Server-Sent Events named "click" until the client
+// disconnects or the server shuts down. Clicks the client doesn't keep up with are dropped and counted
+// in the "dropped" field of the next event, redirects never wait for a watcher.
+func HandleEvents(app *app.App, w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(cookie.UserID("UserID")).(string)
+	subscription := app.Events.Subscribe(userID)
+	defer subscription.Close()
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string) bool {
+		controller.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+		if _, err := io.WriteString(w, event); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	// Clients reconnect after a shutdown a bit later than the browser default.
+	if !send("retry: 5000\n\n") {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if !send(": keep-alive\n\n") {
+				return
+			}
+		case click, ok := <-subscription.Clicks():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(models.ResponseClickEvent{
+				ShortURL:    click.ShortURL,
+				OriginalURL: click.OriginalURL,
+				Variant:     click.Variant,
+				Time:        click.Time,
+				Dropped:     subscription.TakeDropped(),
+			})
+			if err != nil {
+				app.Logger.Sugar().Error(err)
+				return
+			}
+			if !send("event: click\ndata: " + string(data) + "\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// webhookResponse converts a webhook to its response without the secret.
+func webhookResponse(webhook storage.Webhook) models.ResponseWebhook {
+	return models.ResponseWebhook{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
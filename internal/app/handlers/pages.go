@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+)
+
+// warningPage is shown instead of redirecting to a blocked URL.
+var warningPage = template.Must(template.New("warning").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Warning: blocked link</title>
+</head>
+<body>
+	<h1>This link has been blocked</h1>
+	<p>The destination of this short link was identified as unsafe and is no longer available.</p>
+	{{if .Reason}}<p>Reason: {{.Reason}}</p>{{end}}
+</body>
+</html>
+`))
+
+// previewPage is shown instead of redirecting for previews and links with an interstitial.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="robots" content="noindex">
+	<title>Link preview</title>
+</head>
+<body>
+	<h1>You are leaving {{.ShortURL}}</h1>
+	<p>This short link leads to:</p>
+	<p><code>{{.Destination}}</code></p>
+	{{with .CreatedAt}}<p>Created on {{.Format "January 2, 2006"}}</p>{{end}}
+	<p><a href="{{.Destination}}" rel="noopener noreferrer">Continue</a></p>
+</body>
+</html>
+`))
+
+// linkPreview holds the data of the preview page.
+type linkPreview struct {
+	ShortURL    string
+	Destination string
+	CreatedAt   *time.Time
+}
+
+// unfurlPage is served to link preview crawlers instead of redirecting, see package unfurl.
+var unfurlPage = template.Must(template.New("unfurl").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>{{with .Tags.Title}}{{.}}{{else}}{{.ShortURL}}{{end}}</title>
+	<meta property="og:type" content="website">
+	<meta property="og:url" content="{{.ShortURL}}">
+	{{with .Tags.Title}}<meta property="og:title" content="{{.}}">
+	<meta name="twitter:title" content="{{.}}">{{end}}
+	{{with .Tags.Description}}<meta property="og:description" content="{{.}}">
+	<meta name="twitter:description" content="{{.}}">{{end}}
+	{{with .Tags.Image}}<meta property="og:image" content="{{.}}">
+	<meta name="twitter:image" content="{{.}}">
+	<meta name="twitter:card" content="summary_large_image">{{else}}<meta name="twitter:card" content="summary">{{end}}
+</head>
+<body>
+	{{with .Destination}}<p><a href="{{.}}">Continue to {{.}}</a></p>{{end}}
+</body>
+</html>
+`))
+
+// unfurlData holds the data of the unfurl page.
+// Destination is empty for links whose destination must not be shown without a click.
+type unfurlData struct {
+	ShortURL    string
+	Destination string
+	Tags        models.OpenGraph
+}
+
+// renderPage writes the HTML page rendered from the template with the given status code.
+func renderPage(w http.ResponseWriter, statusCode int, page *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	page.Execute(w, data)
+}
@@ -0,0 +1,228 @@
+// Package health periodically checks the destinations of links and records the links that broke.
+//
+// Destinations are requested with HEAD, and with GET if HEAD fails, since many servers don't
+// implement HEAD properly. Links on the same host are checked one after another with a delay
+// between the requests, and only a limited number of hosts are checked at once.
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/outbound"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// requestTimeout limits a single check of a destination.
+	requestTimeout = 10 * time.Second
+	// maxBodyRead is how much of the body of a GET response is read before the connection is dropped.
+	maxBodyRead = 64 << 10
+	// saveBatchSize is the number of results saved at once.
+	saveBatchSize = 100
+	// userAgent identifies the checker to the destinations.
+	userAgent = "Mozilla/5.0 (compatible; ShortenerLinkChecker/1.0)"
+)
+
+// Event reports a link whose destination broke: it was reachable or not checked before.
+type Event struct {
+	ShortURL    string
+	UserID      string
+	OriginalURL string
+	Health      models.LinkHealth
+}
+
+// Options configures a Checker.
+type Options struct {
+	// Interval is how often the destinations are checked.
+	// Links checked less than Interval ago are skipped, so restarts don't check them again.
+	Interval time.Duration
+	// Concurrency is the number of hosts checked at once.
+	Concurrency int
+	// HostDelay is the pause between two requests to the same host.
+	HostDelay time.Duration
+	// Client sends the requests, a client that only reaches public addresses if nil.
+	Client *http.Client
+	// OnBreak is called for every link that broke.
+	OnBreak func(Event)
+	// Logger reports failures to save the results.
+	Logger *zap.Logger
+}
+
+// Checker checks the destinations of the links in the storage.
+type Checker struct {
+	storage storage.Storage
+	options Options
+}
+
+// NewChecker creates a checker of the links in the storage.
+func NewChecker(store storage.Storage, options Options) *Checker {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.Client == nil {
+		options.Client = outbound.NewClient(requestTimeout)
+	}
+	return &Checker{storage: store, options: options}
+}
+
+// Run checks the links now and then every Interval until the context is done.
+// The returned WaitGroup is done when the last check has stopped. A nil checker does nothing.
+func (c *Checker) Run(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if c == nil {
+		return &wg
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.options.Interval)
+		defer ticker.Stop()
+		for {
+			if err := c.CheckAll(ctx); err != nil && ctx.Err() == nil && c.options.Logger != nil {
+				c.options.Logger.Sugar().Errorf("failed to check links: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return &wg
+}
+
+// CheckAll checks every link that is due and saves the results.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	hosts, err := c.dueLinks(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	results := make(chan storage.HealthCheck)
+	sem := make(chan struct{}, c.options.Concurrency)
+	var wg sync.WaitGroup
+	for _, links := range hosts {
+		wg.Add(1)
+		go func(links []storage.SavedURL) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			c.checkHost(ctx, links, results)
+		}(links)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var saveErr error
+	batch := make([]storage.HealthCheck, 0, saveBatchSize)
+	for result := range results {
+		batch = append(batch, result)
+		if len(batch) == saveBatchSize {
+			saveErr = errors.Join(saveErr, c.storage.UpdateHealth(ctx, batch))
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		saveErr = errors.Join(saveErr, c.storage.UpdateHealth(ctx, batch))
+	}
+	return errors.Join(saveErr, ctx.Err())
+}
+
+// dueLinks returns the links that are not deleted and were not checked for Interval, grouped by host.
+func (c *Checker) dueLinks(ctx context.Context, now time.Time) (map[string][]storage.SavedURL, error) {
+	hosts := make(map[string][]storage.SavedURL)
+	err := c.storage.ExportAll(ctx, func(savedURL storage.SavedURL) error {
+		if savedURL.IsDeleted {
+			return nil
+		}
+		if savedURL.Health != nil && now.Sub(savedURL.Health.CheckedAt) < c.options.Interval {
+			return nil
+		}
+		destination, err := url.Parse(savedURL.OriginalURL)
+		if err != nil {
+			return nil
+		}
+		host := strings.ToLower(destination.Hostname())
+		hosts[host] = append(hosts[host], savedURL)
+		return nil
+	})
+	return hosts, err
+}
+
+// checkHost checks the links of one host one after another and sends the results.
+// A destination shared by several links is requested once.
+func (c *Checker) checkHost(ctx context.Context, links []storage.SavedURL, results chan<- storage.HealthCheck) {
+	checked := make(map[string]models.LinkHealth)
+	for _, link := range links {
+		health, ok := checked[link.OriginalURL]
+		if !ok {
+			if len(checked) > 0 {
+				select {
+				case <-time.After(c.options.HostDelay):
+				case <-ctx.Done():
+					return
+				}
+			}
+			health = c.Check(ctx, link.OriginalURL)
+			checked[link.OriginalURL] = health
+		}
+
+		if health.Broken && (link.Health == nil || !link.Health.Broken) && c.options.OnBreak != nil {
+			c.options.OnBreak(Event{ShortURL: link.ShortURL, UserID: link.UserID, OriginalURL: link.OriginalURL, Health: health})
+		}
+		select {
+		case results <- storage.HealthCheck{ShortURL: link.ShortURL, Health: health}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check requests the destination and returns its health.
+// A destination is broken if it can't be reached or responds with an error status.
+// Too Many Requests only means the destination is busy and is not counted as broken.
+func (c *Checker) Check(ctx context.Context, rawURL string) models.LinkHealth {
+	status, err := c.request(ctx, http.MethodHead, rawURL)
+	if err != nil || status >= http.StatusBadRequest {
+		status, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+
+	health := models.LinkHealth{Status: status, CheckedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	health.Broken = err != nil || (status >= http.StatusBadRequest && status != http.StatusTooManyRequests)
+	return health
+}
+
+// request sends a request to the destination and returns the status of the response.
+func (c *Checker) request(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.options.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodyRead))
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/gone", http.StatusMovedPermanently)
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewChecker(nil, Options{Client: server.Client()})
+	ctx := context.Background()
+
+	assert.False(t, checker.Check(ctx, server.URL+"/ok").Broken)
+	assert.Equal(t, http.StatusOK, checker.Check(ctx, server.URL+"/no-head").Status, "GET is tried when HEAD fails")
+	assert.Equal(t, models.LinkHealth{Status: http.StatusNotFound, Broken: true}, withoutTime(checker.Check(ctx, server.URL+"/moved")), "redirects are followed")
+	assert.False(t, checker.Check(ctx, server.URL+"/busy").Broken)
+
+	unreachable := checker.Check(ctx, "http://127.0.0.1:1/")
+	assert.True(t, unreachable.Broken)
+	assert.Zero(t, unreachable.Status)
+	assert.NotEmpty(t, unreachable.Error)
+}
+
+func TestCheckAll(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		if r.URL.Path == "/dead" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	store := &memory.MemoryStorage{DedupScope: storage.DedupNone}
+	assert.NoError(t, store.Init(ctx))
+	for _, savedURL := range []*storage.SavedURL{
+		storage.NewSavedURL("live", server.URL+"/live", "alice"),
+		storage.NewSavedURL("dead", server.URL+"/dead", "alice"),
+		storage.NewSavedURL("copy", server.URL+"/dead", "bob"),
+	} {
+		_, err := store.Save(ctx, *savedURL)
+		assert.NoError(t, err)
+	}
+
+	var events []Event
+	checker := NewChecker(store, Options{
+		Interval:    time.Hour,
+		Concurrency: 2,
+		HostDelay:   50 * time.Millisecond,
+		Client:      server.Client(),
+		OnBreak:     func(event Event) { events = append(events, event) },
+	})
+	assert.NoError(t, checker.CheckAll(ctx))
+
+	// HEAD and GET for the dead destination, which is shared by two links, and HEAD for the live one.
+	if assert.Len(t, requests, 3) {
+		assert.GreaterOrEqual(t, requests[2].Sub(requests[0]), 50*time.Millisecond, "requests to one host are spaced")
+	}
+	assert.ElementsMatch(t, []string{"dead", "copy"}, []string{events[0].ShortURL, events[1].ShortURL})
+
+	broken, err := store.GetByUser(ctx, "alice", storage.URLFilter{Broken: true})
+	assert.NoError(t, err)
+	if assert.Len(t, broken, 1) {
+		assert.Equal(t, "dead", broken[0].ShortURL)
+		assert.Equal(t, http.StatusNotFound, broken[0].Health.Status)
+	}
+
+	assert.NoError(t, checker.CheckAll(ctx))
+	assert.Len(t, requests, 3, "links checked within the interval are skipped")
+	assert.Len(t, events, 2)
+}
+
+func withoutTime(health models.LinkHealth) models.LinkHealth {
+	health.CheckedAt = time.Time{}
+	return health
+}
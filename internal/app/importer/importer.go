@@ -0,0 +1,101 @@
+// Package importer provides readers for bulk import files and tracks the progress of import jobs.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+const (
+	// FormatCSV is a CSV file with a header row naming the url, alias and tags columns.
+	FormatCSV = "csv"
+	// FormatNDJSON is a file with one JSON object per line.
+	FormatNDJSON = "ndjson"
+)
+
+// tagSeparator separates tags inside a single CSV cell.
+const tagSeparator = "|"
+
+// ErrUnknownFormat is returned when the import format is not supported.
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// ErrMalformedImport is returned when reading an import stops because the input can't be read any further,
+// for example a line that is too long. Malformed single records are reported with RecordError instead.
+var ErrMalformedImport = errors.New("malformed import")
+
+// aliasPattern is the set of custom aliases accepted as short URLs.
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// reservedAliases are paths served by the application itself.
+var reservedAliases = map[string]bool{
+	"api":  true,
+	"ping": true,
+}
+
+// Record is a single link read from an import file.
+type Record struct {
+	Line   int      `json:"-"`
+	URL    string   `json:"url"`
+	Alias  string   `json:"alias,omitempty"`
+	Domain string   `json:"domain,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// RecordError describes a record that can't be read. Reading continues with the next record.
+type RecordError struct {
+	Line   int
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Reader reads import records one by one.
+type Reader interface {
+	// Next returns the next record, a *RecordError for a malformed record or io.EOF after the last record.
+	Next() (Record, error)
+}
+
+// DetectFormat returns the import format from the explicit format parameter or the content type.
+func DetectFormat(format, contentType string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// NewReader creates a reader of the given format.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ValidateAlias checks that the alias can be used as a short URL.
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errors.New("alias must be 1 to 64 letters, digits, '-' or '_'")
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+	return nil
+}
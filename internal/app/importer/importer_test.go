@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads every record and record error from the reader.
+func readAll(t *testing.T, reader Reader) ([]Record, []error) {
+	var records []Record
+	var errs []error
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		records = append(records, record)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	input := "alias,url,tags\n" +
+		"docs,https://example.com/docs,work|Docs\n" +
+		",https://example.org\n" +
+		"bad,\"https://example.net\n"
+
+	reader, err := NewReader(FormatCSV, strings.NewReader(input))
+	assert.NoError(t, err)
+
+	records, errs := readAll(t, reader)
+	assert.Equal(t, []Record{
+		{Line: 2, URL: "https://example.com/docs", Alias: "docs", Tags: []string{"work", "Docs"}},
+		{Line: 3, URL: "https://example.org"},
+	}, records)
+	assert.Len(t, errs, 1)
+}
+
+func TestCSVReaderWithoutURLColumn(t *testing.T) {
+	_, err := NewReader(FormatCSV, strings.NewReader("alias,tags\n"))
+	assert.Error(t, err)
+}
+
+func TestNDJSONReader(t *testing.T) {
+	input := `{"url": "https://example.com", "alias": "ex", "tags": ["a"]}` + "\n" +
+		"\n" +
+		"not json\n" +
+		`{"url": "https://example.org"}` + "\n"
+
+	reader, err := NewReader(FormatNDJSON, strings.NewReader(input))
+	assert.NoError(t, err)
+
+	records, errs := readAll(t, reader)
+	assert.Equal(t, []Record{
+		{Line: 1, URL: "https://example.com", Alias: "ex", Tags: []string{"a"}},
+		{Line: 4, URL: "https://example.org"},
+	}, records)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 3, errs[0].(*RecordError).Line)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, FormatCSV, DetectFormat("", "text/csv; charset=utf-8"))
+	assert.Equal(t, FormatNDJSON, DetectFormat("", "application/x-ndjson"))
+	assert.Equal(t, FormatNDJSON, DetectFormat("NDJSON", "text/csv"))
+	assert.Equal(t, "", DetectFormat("", "application/json"))
+}
+
+func TestValidateAlias(t *testing.T) {
+	assert.NoError(t, ValidateAlias("my-link_1"))
+	assert.Error(t, ValidateAlias("with space"))
+	assert.Error(t, ValidateAlias("api"))
+	assert.Error(t, ValidateAlias(""))
+}
+
+func TestManager(t *testing.T) {
+	manager := NewManager()
+	job := manager.Start("user")
+	job.AddCreated()
+	job.AddExisting()
+	job.AddFailed(3, "bad", "invalid")
+
+	report, ok := manager.Get("user", job.Report().ID)
+	assert.True(t, ok)
+	assert.Equal(t, StatusRunning, report.Status)
+	assert.Equal(t, 3, report.Processed)
+	assert.Equal(t, []ErrorEntry{{Line: 3, URL: "bad", Reason: "invalid"}}, report.Errors)
+
+	job.Finish(nil)
+	assert.Equal(t, StatusDone, manager.List("user")[0].Status)
+
+	_, ok = manager.Get("other", job.Report().ID)
+	assert.False(t, ok)
+}
@@ -0,0 +1,161 @@
+package importer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlgenerator"
+)
+
+const (
+	// maxReportErrors limits the number of errors kept in a job report.
+	maxReportErrors = 1000
+	// maxJobsPerUser is the number of recent jobs kept for every user.
+	maxJobsPerUser = 20
+)
+
+// Status is the state of an import job.
+type Status string
+
+const (
+	// StatusRunning means the job is still reading records.
+	StatusRunning Status = "running"
+	// StatusDone means every record was processed.
+	StatusDone Status = "done"
+	// StatusFailed means the job stopped before the end of the input.
+	StatusFailed Status = "failed"
+)
+
+// ErrorEntry describes a record that was not imported.
+type ErrorEntry struct {
+	Line   int    `json:"line"`
+	URL    string `json:"url,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Report is a snapshot of the job progress and its error report.
+// Errors holds at most the first 1000 failed records, Failed counts all of them.
+type Report struct {
+	ID         string       `json:"id"`
+	Status     Status       `json:"status"`
+	Processed  int          `json:"processed"`
+	Created    int          `json:"created"`
+	Existing   int          `json:"existing"`
+	Failed     int          `json:"failed"`
+	Error      string       `json:"error,omitempty"`
+	Errors     []ErrorEntry `json:"errors,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// Job tracks the progress of a single import.
+type Job struct {
+	mu     sync.Mutex
+	report Report
+}
+
+// AddCreated counts a created link.
+func (j *Job) AddCreated() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.report.Processed++
+	j.report.Created++
+}
+
+// AddExisting counts a link that was already shortened.
+func (j *Job) AddExisting() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.report.Processed++
+	j.report.Existing++
+}
+
+// AddFailed counts a record that was not imported and adds it to the error report.
+func (j *Job) AddFailed(line int, url, reason string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.report.Processed++
+	j.report.Failed++
+	if len(j.report.Errors) < maxReportErrors {
+		j.report.Errors = append(j.report.Errors, ErrorEntry{Line: line, URL: url, Reason: reason})
+	}
+}
+
+// Finish marks the job as done, or as failed if err is not nil.
+func (j *Job) Finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.report.FinishedAt = &now
+	j.report.Status = StatusDone
+	if err != nil {
+		j.report.Status = StatusFailed
+		j.report.Error = err.Error()
+	}
+}
+
+// Report returns a snapshot of the job.
+func (j *Job) Report() Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	report := j.report
+	report.Errors = append([]ErrorEntry(nil), j.report.Errors...)
+	return report
+}
+
+// Manager keeps the recent import jobs of every user.
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string][]*Job
+}
+
+// NewManager creates a new Manager.
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string][]*Job)}
+}
+
+// Start creates a running job for the user.
+// The oldest job of the user is forgotten when there are too many of them.
+func (m *Manager) Start(userID string) *Job {
+	job := &Job{report: Report{
+		ID:        urlgenerator.CreateShortLink(),
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := append(m.jobs[userID], job)
+	if len(jobs) > maxJobsPerUser {
+		jobs = jobs[len(jobs)-maxJobsPerUser:]
+	}
+	m.jobs[userID] = jobs
+	return job
+}
+
+// Get returns the report of the user's job.
+func (m *Manager) Get(userID, id string) (Report, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs[userID] {
+		if report := job.Report(); report.ID == id {
+			return report, true
+		}
+	}
+	return Report{}, false
+}
+
+// List returns the reports of the user's jobs, newest first.
+func (m *Manager) List(userID string) []Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reports := make([]Report, 0, len(m.jobs[userID]))
+	for _, job := range m.jobs[userID] {
+		reports = append(reports, job.Report())
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].StartedAt.After(reports[j].StartedAt)
+	})
+	return reports
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// maxLineSize limits a single NDJSON line.
+const maxLineSize = 1024 * 1024
+
+// csvReader reads records from a CSV file.
+// The first row must name the columns: url (or original_url), and optionally alias, domain and tags.
+// Tags inside a cell are separated by "|".
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// newCSVReader reads the header row and creates a csvReader.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv header is missing")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "original_url" {
+			name = "url"
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New("csv header must contain a url column")
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+// Next implements the Reader interface.
+func (c *csvReader) Next() (Record, error) {
+	fields, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Record{}, &RecordError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()}
+		}
+		return Record{}, err
+	}
+	line, _ := c.reader.FieldPos(0)
+
+	record := Record{
+		Line:   line,
+		URL:    c.field(fields, "url"),
+		Alias:  c.field(fields, "alias"),
+		Domain: c.field(fields, "domain"),
+	}
+	if tags := c.field(fields, "tags"); tags != "" {
+		record.Tags = strings.Split(tags, tagSeparator)
+	}
+	return record, nil
+}
+
+// field returns the value of the named column or an empty string if the row is too short.
+func (c *csvReader) field(fields []string, name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+// ndjsonReader reads records from a file with one JSON object per line.
+// Empty lines are skipped.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// newNDJSONReader creates a new ndjsonReader.
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ndjsonReader{scanner: scanner}
+}
+
+// Next implements the Reader interface.
+func (n *ndjsonReader) Next() (Record, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return Record{}, &RecordError{Line: n.line, Reason: err.Error()}
+		}
+		record.Line = n.line
+		return record, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
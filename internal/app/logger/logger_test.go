@@ -88,3 +88,14 @@ func TestLoggingResponseWriter_WriteHeader(t *testing.T) {
 	lrw.WriteHeader(http.StatusOK)
 	assert.Equal(t, http.StatusOK, lrw.responseData.status)
 }
+
+func TestLoggingResponseWriter_Flush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	lrw := &loggingResponseWriter{
+		ResponseWriter: rr,
+		responseData:   &responseData{},
+	}
+
+	assert.NoError(t, http.NewResponseController(lrw).Flush())
+	assert.True(t, rr.Flushed)
+}
@@ -1,9 +1,90 @@
 // Package models provides data structures and methods related to the application's data model.
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// LinkOptions holds the settings of a new link.
+// Domain is the short domain of the link, the default domain if empty.
+// RedirectStatus is the HTTP status of the redirect, the server default if zero.
+// PassQuery and PassPath forward the query string and the trailing path of the short URL to the destination.
+// UTM parameters are added to the destination and kept as metadata of the link.
+// Targets send clients on matching devices and platforms to other URLs.
+// Variants split the visitors of the link between several destinations by weight.
+// MaxClicks limits the number of redirects, one makes a one-time link. Zero means no limit.
+// NotBefore and NotAfter limit the time the link redirects. Before the window opens the link
+// redirects to PlaceholderURL, or is not found if there is none; after it closes the link is gone.
+// Interstitial shows a page with the destination and a continue button instead of redirecting.
+// OpenGraph sets the preview tags shown to the crawlers of social networks and chat apps,
+// the missing ones are fetched from the destination.
+type LinkOptions struct {
+	Domain         string       `json:"domain,omitempty"`
+	Tags           []string     `json:"tags,omitempty"`
+	RedirectStatus int          `json:"redirect_status,omitempty"`
+	PassQuery      bool         `json:"pass_query,omitempty"`
+	PassPath       bool         `json:"pass_path,omitempty"`
+	UTM            *UTM         `json:"utm,omitempty"`
+	Targets        []TargetRule `json:"targets,omitempty"`
+	Variants       []Variant    `json:"variants,omitempty"`
+	MaxClicks      int          `json:"max_clicks,omitempty"`
+	NotBefore      *time.Time   `json:"not_before,omitempty"`
+	NotAfter       *time.Time   `json:"not_after,omitempty"`
+	PlaceholderURL string       `json:"placeholder_url,omitempty"`
+	Interstitial   bool         `json:"interstitial,omitempty"`
+	OpenGraph      *OpenGraph   `json:"open_graph,omitempty"`
+}
+
+// Variant is a destination of an A/B split. Visitors are sent to a variant with a probability
+// proportional to its weight. ID names the variant in cookies and click analytics.
+type Variant struct {
+	ID     string `json:"id,omitempty"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// TargetRule redirects clients that match all of its conditions to URL.
+// OS is one of ios, android, windows, macos, linux and chromeos, Device one of mobile, tablet and desktop,
+// and Agent is bot or human. Countries are ISO 3166-1 alpha-2 codes of the client location.
+// Empty conditions match any client.
+type TargetRule struct {
+	OS        string   `json:"os,omitempty"`
+	Device    string   `json:"device,omitempty"`
+	Agent     string   `json:"agent,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// UTM holds the campaign parameters of a link.
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// OpenGraph holds the preview tags of a link.
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// LinkHealth is the result of the last check of the destination of a link.
+// Status is the HTTP status of the destination, zero if it could not be reached and Error says why.
+type LinkHealth struct {
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Broken    bool      `json:"broken"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
 // RequestShotenerURL represents a request to shorten a URL.
 type RequestShotenerURL struct {
 	URL string `json:"url"`
+	LinkOptions
 }
 
 // ResponseShortURL represents a shortened URL.
@@ -22,22 +103,129 @@ func NewResponseShortURL(url string) *ResponseShortURL {
 type RequestShortenerURLBatch struct {
 	ID  string `json:"correlation_id"`
 	URL string `json:"original_url"`
+	LinkOptions
 }
 
-// ResponseShortenerURLBatch represents a batch of shortened URLs.
+// ResponseShortenerURLBatch represents the result of shortening a URL of a batch.
+// Status is one of "created", "existing", "invalid" or "skipped", Reason explains invalid URLs.
 type ResponseShortenerURLBatch struct {
-	ID  string `json:"correlation_id"`
-	URL string `json:"short_url"`
+	ID     string `json:"correlation_id"`
+	URL    string `json:"short_url,omitempty"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // NewResponseShortenerURLBatch creates a new ResponseShortenerURLBatch instance.
-func NewResponseShortenerURLBatch(id string, url string) *ResponseShortenerURLBatch {
+func NewResponseShortenerURLBatch(id string, url string, status string) *ResponseShortenerURLBatch {
 	return &ResponseShortenerURLBatch{
-		URL: url,
-		ID:  id,
+		URL:    url,
+		ID:     id,
+		Status: status,
 	}
 }
 
+// ResponseUserURL represents a URL in the list of a user's URLs.
+type ResponseUserURL struct {
+	OriginalURL    string      `json:"original_url"`
+	ShortURL       string      `json:"short_url"`
+	Tags           []string    `json:"tags,omitempty"`
+	UTM            *UTM        `json:"utm,omitempty"`
+	Variants       []Variant   `json:"variants,omitempty"`
+	MaxClicks      int         `json:"max_clicks,omitempty"`
+	ClicksLeft     *int        `json:"clicks_left,omitempty"`
+	NotBefore      *time.Time  `json:"not_before,omitempty"`
+	NotAfter       *time.Time  `json:"not_after,omitempty"`
+	PlaceholderURL string      `json:"placeholder_url,omitempty"`
+	Interstitial   bool        `json:"interstitial,omitempty"`
+	CreatedAt      *time.Time  `json:"created_at,omitempty"`
+	OpenGraph      *OpenGraph  `json:"open_graph,omitempty"`
+	Health         *LinkHealth `json:"health,omitempty"`
+}
+
+// RequestUpdateWeights represents a request to change the weights of the variants of a URL.
+// Weights maps variant IDs to their new weight, variants that are not mentioned keep their weight.
+type RequestUpdateWeights struct {
+	Weights map[string]int `json:"weights"`
+}
+
+// ResponseLinkStats represents the click statistics of a URL.
+// Referrers are the top referrer domains, the breakdowns list the values with the most clicks first.
+// Buckets are the clicks per period, oldest first, if the request asks for them.
+type ResponseLinkStats struct {
+	Clicks    int                   `json:"clicks"`
+	Visitors  int                   `json:"visitors"`
+	Variants  map[string]int        `json:"variants,omitempty"`
+	Referrers []ResponseStatsCount  `json:"referrers,omitempty"`
+	Browsers  []ResponseStatsCount  `json:"browsers,omitempty"`
+	OS        []ResponseStatsCount  `json:"os,omitempty"`
+	Countries []ResponseStatsCount  `json:"countries,omitempty"`
+	Buckets   []ResponseStatsBucket `json:"buckets,omitempty"`
+}
+
+// ResponseStatsCount represents a value of a breakdown of clicks.
+type ResponseStatsCount struct {
+	Name   string `json:"name"`
+	Clicks int    `json:"clicks"`
+}
+
+// ResponseStatsBucket represents the clicks of a period.
+type ResponseStatsBucket struct {
+	Start    time.Time `json:"start"`
+	Clicks   int       `json:"clicks"`
+	Visitors int       `json:"visitors"`
+}
+
+// RequestUpdateTags represents a request to replace the tags of a URL.
+type RequestUpdateTags struct {
+	Tags []string `json:"tags"`
+}
+
+// RequestWebhook represents a request to register a webhook. No events means every event type.
+type RequestWebhook struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// ResponseWebhook represents a webhook of the user.
+// The secret the deliveries are signed with is only returned when the webhook is registered.
+type ResponseWebhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponseWebhookDelivery represents a delivery of an event to a webhook and the outcome of its last attempt.
+type ResponseWebhookDelivery struct {
+	ID             string          `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttempt    *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// ResponseClickEvent represents a click streamed to the watchers of the link.
+// Dropped is the number of clicks skipped before this one because the watcher didn't keep up.
+type ResponseClickEvent struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Variant     string    `json:"variant,omitempty"`
+	Time        time.Time `json:"time"`
+	Dropped     int64     `json:"dropped,omitempty"`
+}
+
+// ResponseError represents a structured error returned to the client.
+type ResponseError struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"`
+}
+
 // DeleteTask represents a task to delete a URL.
 type DeleteTask struct {
 	URL    string
@@ -0,0 +1,112 @@
+// Package redirect builds the destination of a redirect from a saved link and the incoming request.
+package redirect
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+)
+
+// ErrInvalidPath is returned when the trailing path can't be forwarded to the destination.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrPathNotAllowed is returned when a request has a trailing path but the link does not forward it.
+var ErrPathNotAllowed = errors.New("link does not forward paths")
+
+// ErrNotStarted is returned for a link whose activation window has not opened yet.
+var ErrNotStarted = errors.New("link is not active yet")
+
+// ErrExpired is returned for a link whose activation window has closed.
+var ErrExpired = errors.New("link has expired")
+
+// CheckWindow checks that the link redirects at the time now.
+// NotBefore is inclusive and NotAfter is exclusive, a missing bound leaves the window open on that side.
+func CheckWindow(savedURL storage.SavedURL, now time.Time) error {
+	if savedURL.NotBefore != nil && now.Before(*savedURL.NotBefore) {
+		return ErrNotStarted
+	}
+	if savedURL.NotAfter != nil && !now.Before(*savedURL.NotAfter) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Destination returns the URL a request for the link is redirected to.
+// extraPath is the escaped path after the short ID and query is the incoming query string.
+// Both are dropped unless the link forwards them. Forwarded query parameters never replace
+// the parameters of the destination, they are only added.
+func Destination(savedURL storage.SavedURL, extraPath string, query url.Values) (string, error) {
+	if extraPath == "" && (!savedURL.PassQuery || len(query) == 0) {
+		return savedURL.OriginalURL, nil
+	}
+	if extraPath != "" && !savedURL.PassPath {
+		return "", ErrPathNotAllowed
+	}
+
+	destination, err := url.Parse(savedURL.OriginalURL)
+	if err != nil {
+		return "", err
+	}
+
+	if extraPath != "" {
+		if err := appendPath(destination, extraPath); err != nil {
+			return "", err
+		}
+	}
+
+	if savedURL.PassQuery {
+		mergeQuery(destination, query)
+	}
+	return destination.String(), nil
+}
+
+// appendPath appends the escaped path segments to the destination.
+// Dot segments are rejected, so the result never leaves the path of the destination.
+func appendPath(destination *url.URL, extraPath string) error {
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(extraPath, "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped == "." || unescaped == ".." || strings.Contains(unescaped, "/") {
+			return ErrInvalidPath
+		}
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	base := strings.TrimSuffix(destination.EscapedPath(), "/")
+	joined, err := url.PathUnescape(base + "/" + strings.Join(segments, "/"))
+	if err != nil {
+		return ErrInvalidPath
+	}
+	destination.Path = joined
+	destination.RawPath = base + "/" + strings.Join(segments, "/")
+	return nil
+}
+
+// mergeQuery adds the parameters of query that the destination does not set.
+// The query string of the destination is kept as it is.
+func mergeQuery(destination *url.URL, query url.Values) {
+	existing := destination.Query()
+	extra := url.Values{}
+	for key, values := range query {
+		if _, ok := existing[key]; !ok {
+			extra[key] = values
+		}
+	}
+	if len(extra) == 0 {
+		return
+	}
+
+	if destination.RawQuery == "" {
+		destination.RawQuery = extra.Encode()
+	} else {
+		destination.RawQuery += "&" + extra.Encode()
+	}
+}
@@ -0,0 +1,57 @@
+package redirect
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestination(t *testing.T) {
+	plain := storage.SavedURL{OriginalURL: "https://example.com/docs?lang=en"}
+	forwarding := plain
+	forwarding.PassQuery = true
+	forwarding.PassPath = true
+
+	tests := []struct {
+		name      string
+		savedURL  storage.SavedURL
+		extraPath string
+		query     url.Values
+		want      string
+		wantErr   error
+	}{
+		{name: "no forwarding", savedURL: plain, query: url.Values{"utm_source": {"x"}}, want: "https://example.com/docs?lang=en"},
+		{name: "path not forwarded", savedURL: plain, extraPath: "/guide", wantErr: ErrPathNotAllowed},
+		{name: "query merged", savedURL: forwarding, query: url.Values{"utm_source": {"x"}, "lang": {"de"}}, want: "https://example.com/docs?lang=en&utm_source=x"},
+		{name: "path appended", savedURL: forwarding, extraPath: "/guide/intro%20page", want: "https://example.com/docs/guide/intro%20page?lang=en"},
+		{name: "trailing slash", savedURL: forwarding, extraPath: "/", want: "https://example.com/docs?lang=en"},
+		{name: "dot segment", savedURL: forwarding, extraPath: "/../admin", wantErr: ErrInvalidPath},
+		{name: "escaped slash", savedURL: forwarding, extraPath: "/a%2F..", wantErr: ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Destination(tt.savedURL, tt.extraPath, tt.query)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckWindow(t *testing.T) {
+	opens := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	closes := opens.Add(24 * time.Hour)
+	window := storage.SavedURL{NotBefore: &opens, NotAfter: &closes}
+
+	assert.ErrorIs(t, CheckWindow(window, opens.Add(-time.Second)), ErrNotStarted)
+	assert.NoError(t, CheckWindow(window, opens))
+	assert.NoError(t, CheckWindow(window, closes.Add(-time.Second)))
+	assert.ErrorIs(t, CheckWindow(window, closes), ErrExpired)
+	assert.NoError(t, CheckWindow(storage.SavedURL{}, opens), "a link without a window is always active")
+}
@@ -5,35 +5,94 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/deletemanager"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/domains"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/importer"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/screening"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/split"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/targeting"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlgenerator"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/urlnormalizer"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/webhooks"
 )
 
+const (
+	// importChunkSize is the number of records saved at once during an import.
+	importChunkSize = 1000
+	// maxImportAttempts is the number of short IDs generated for a record before it is reported as failed.
+	maxImportAttempts = 3
+	// maxOpenGraphTitle and maxOpenGraphDescription limit the length of the preview tags of a link.
+	maxOpenGraphTitle       = 200
+	maxOpenGraphDescription = 500
+)
+
+// ErrBatchRejected is returned when an atomic batch is not saved because some of its URLs are invalid or already exist.
+var ErrBatchRejected = errors.New("batch rejected")
+
+// ErrInvalidRedirectStatus is returned for redirect statuses other than 301, 302, 307 and 308.
+var ErrInvalidRedirectStatus = errors.New("invalid redirect status")
+
+// ErrInvalidMaxClicks is returned for a negative click limit.
+var ErrInvalidMaxClicks = errors.New("invalid max clicks")
+
+// ErrInvalidWindow is returned for an activation window that never opens or a placeholder URL without a window start.
+var ErrInvalidWindow = errors.New("invalid activation window")
+
+// ErrInvalidOpenGraph is returned for preview tags that are too long or have an invalid image URL.
+var ErrInvalidOpenGraph = errors.New("invalid open graph tags")
+
+// ValidateRedirectStatus checks that the status can be used for redirects. Zero means the server default.
+func ValidateRedirectStatus(status int) error {
+	switch status {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("%w: %d, expected 301, 302, 307 or 308", ErrInvalidRedirectStatus, status)
+}
+
 // Repository represents the data access layer of the application.
 type Repository struct {
 	storage       storage.Storage
 	DeleteManager *deletemanager.DeleteManager
-	redirectHost  string
+	domains       *domains.Registry
+	normalizer    *urlnormalizer.Normalizer
+	screener      *screening.Screener
+	// Webhooks receives the events of created and updated links, nil if nobody listens.
+	Webhooks *webhooks.Dispatcher
 }
 
 // NewRepository creates a new instance of the Repository with the given storage.
-func NewRepository(storage storage.Storage, deletemanager *deletemanager.DeleteManager, redirectHost string) *Repository {
+// Links are created on the domains of the registry.
+// URLs are validated and canonicalized with the normalizer and checked by the screener before they are saved.
+func NewRepository(storage storage.Storage, deletemanager *deletemanager.DeleteManager, domains *domains.Registry, normalizer *urlnormalizer.Normalizer, screener *screening.Screener) *Repository {
 	return &Repository{
 		storage:       storage,
 		DeleteManager: deletemanager,
-		redirectHost:  redirectHost,
+		domains:       domains,
+		normalizer:    normalizer,
+		screener:      screener,
 	}
 }
 
-// SaveURL saves a URL to the storage and returns the saved URL.
-func (r *Repository) SaveURL(ctx context.Context, originalURL, userID string) (storage.SavedURL, error) {
-	shortID := urlgenerator.CreateShortLink()
-	savedURL := storage.NewSavedURL(shortID, originalURL, userID)
+// SaveURL saves a URL with the options and returns the saved URL.
+// The URL is stored in its canonical form, so every variant of the same URL shares one short link per domain.
+// The short URL of the result is the storage key, see ShortURL.
+func (r *Repository) SaveURL(ctx context.Context, originalURL, userID string, options models.LinkOptions) (storage.SavedURL, error) {
+	savedURL, err := r.newLink(ctx, originalURL, userID, options)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
 
-	conflictURL, err := r.storage.Save(ctx, *savedURL)
+	conflictURL, err := r.storage.Save(ctx, savedURL)
 	if err != nil {
 		if errors.Is(err, storage.ErrURLConflict) {
 			return storage.SavedURL{ShortURL: conflictURL}, err
@@ -41,38 +100,339 @@ func (r *Repository) SaveURL(ctx context.Context, originalURL, userID string) (s
 		return storage.SavedURL{}, err
 	}
 
-	return storage.SavedURL{ShortURL: shortID}, nil
+	r.notify(ctx, userID, r.linkEvent(webhooks.EventCreated, savedURL, nil))
+	return storage.SavedURL{ShortURL: savedURL.ShortURL}, nil
+}
+
+// notify writes the events to the webhooks outbox of the user.
+// The links are already saved, so a failed write doesn't fail the call, the dispatcher logs it.
+func (r *Repository) notify(ctx context.Context, userID string, events ...webhooks.Event) {
+	r.Webhooks.Emit(ctx, userID, events...)
+}
+
+// linkEvent builds an event of the link.
+func (r *Repository) linkEvent(eventType string, savedURL storage.SavedURL, changes []string) webhooks.Event {
+	return webhooks.Event{
+		Type: eventType,
+		Link: webhooks.Link{
+			ShortURL:    r.domains.ShortURL(savedURL.ShortURL),
+			OriginalURL: savedURL.OriginalURL,
+			Tags:        savedURL.Tags,
+		},
+		Changes: changes,
+	}
 }
 
-// DeleteURLs deletes the specified URLs.
+// newLink validates the options and the URL and builds a link with a new short ID.
+func (r *Repository) newLink(ctx context.Context, rawURL, userID string, options models.LinkOptions) (storage.SavedURL, error) {
+	shortDomain, err := r.domains.Lookup(options.Domain)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+	if err := ValidateRedirectStatus(options.RedirectStatus); err != nil {
+		return storage.SavedURL{}, err
+	}
+	if options.MaxClicks < 0 {
+		return storage.SavedURL{}, fmt.Errorf("%w: %d, expected zero for no limit or a positive number", ErrInvalidMaxClicks, options.MaxClicks)
+	}
+
+	originalURL, err := r.prepareURL(ctx, rawURL)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+
+	targets, err := r.prepareTargets(ctx, options.Targets)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+
+	variants, err := r.prepareVariants(ctx, options.Variants)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+
+	placeholderURL, err := r.prepareWindow(ctx, options)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+
+	openGraph, err := r.prepareOpenGraph(ctx, options.OpenGraph)
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+
+	utm := normalizeUTM(options.UTM)
+	if utm != nil {
+		if originalURL, err = applyUTM(originalURL, utm); err != nil {
+			return storage.SavedURL{}, err
+		}
+	}
+
+	savedURL := storage.NewSavedURL(r.domains.Key(shortDomain, urlgenerator.CreateShortLink()), originalURL, userID)
+	savedURL.Tags = normalizeTags(options.Tags)
+	savedURL.RedirectStatus = options.RedirectStatus
+	savedURL.PassQuery = options.PassQuery
+	savedURL.PassPath = options.PassPath
+	savedURL.UTM = utm
+	savedURL.Targets = targets
+	savedURL.Variants = variants
+	savedURL.MaxClicks = options.MaxClicks
+	savedURL.ClicksLeft = options.MaxClicks
+	savedURL.NotBefore = options.NotBefore
+	savedURL.NotAfter = options.NotAfter
+	savedURL.PlaceholderURL = placeholderURL
+	savedURL.Interstitial = options.Interstitial
+	savedURL.CreatedAt = createdAt()
+	savedURL.OpenGraph = openGraph
+	return *savedURL, nil
+}
+
+// prepareOpenGraph trims the preview tags, checks their length and prepares the image URL
+// like the original URL of a link. It returns nil if no tag is set.
+func (r *Repository) prepareOpenGraph(ctx context.Context, openGraph *models.OpenGraph) (*models.OpenGraph, error) {
+	if openGraph == nil {
+		return nil, nil
+	}
+	prepared := models.OpenGraph{
+		Title:       strings.TrimSpace(openGraph.Title),
+		Description: strings.TrimSpace(openGraph.Description),
+		Image:       strings.TrimSpace(openGraph.Image),
+	}
+	if prepared == (models.OpenGraph{}) {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(prepared.Title) > maxOpenGraphTitle {
+		return nil, fmt.Errorf("%w: the title is longer than %d characters", ErrInvalidOpenGraph, maxOpenGraphTitle)
+	}
+	if utf8.RuneCountInString(prepared.Description) > maxOpenGraphDescription {
+		return nil, fmt.Errorf("%w: the description is longer than %d characters", ErrInvalidOpenGraph, maxOpenGraphDescription)
+	}
+	if prepared.Image != "" {
+		image, err := r.prepareURL(ctx, prepared.Image)
+		if err != nil {
+			return nil, fmt.Errorf("%w: image: %w", ErrInvalidOpenGraph, err)
+		}
+		prepared.Image = image
+	}
+	return &prepared, nil
+}
+
+// createdAt returns the creation time of a new link.
+// It is kept in UTC with microsecond precision, like every storage returns it.
+func createdAt() *time.Time {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return &now
+}
+
+// prepareWindow validates the activation window and prepares the placeholder URL like the original URL of a link.
+func (r *Repository) prepareWindow(ctx context.Context, options models.LinkOptions) (string, error) {
+	if options.NotBefore != nil && options.NotAfter != nil && !options.NotAfter.After(*options.NotBefore) {
+		return "", fmt.Errorf("%w: not_after must be later than not_before", ErrInvalidWindow)
+	}
+	if options.PlaceholderURL == "" {
+		return "", nil
+	}
+	if options.NotBefore == nil {
+		return "", fmt.Errorf("%w: a placeholder url needs not_before", ErrInvalidWindow)
+	}
+	return r.prepareURL(ctx, options.PlaceholderURL)
+}
+
+// prepareVariants validates the A/B variants and prepares their URLs like the original URL of a link.
+func (r *Repository) prepareVariants(ctx context.Context, variants []models.Variant) ([]models.Variant, error) {
+	variants, err := split.Normalize(variants)
+	if err != nil {
+		return nil, err
+	}
+	for i := range variants {
+		if variants[i].URL, err = r.prepareURL(ctx, variants[i].URL); err != nil {
+			return nil, fmt.Errorf("variant %q: %w", variants[i].ID, err)
+		}
+	}
+	return variants, nil
+}
+
+// prepareTargets validates the targeting rules and prepares their URLs like the original URL of a link.
+func (r *Repository) prepareTargets(ctx context.Context, rules []models.TargetRule) ([]models.TargetRule, error) {
+	targets, err := targeting.Normalize(rules)
+	if err != nil {
+		return nil, err
+	}
+	for i := range targets {
+		if targets[i].URL, err = r.prepareURL(ctx, targets[i].URL); err != nil {
+			return nil, fmt.Errorf("target %d: %w", i+1, err)
+		}
+	}
+	return targets, nil
+}
+
+// normalizeUTM trims the UTM parameters and returns nil if none of them is set.
+func normalizeUTM(utm *models.UTM) *models.UTM {
+	if utm == nil {
+		return nil
+	}
+	normalized := models.UTM{
+		Source:   strings.TrimSpace(utm.Source),
+		Medium:   strings.TrimSpace(utm.Medium),
+		Campaign: strings.TrimSpace(utm.Campaign),
+		Term:     strings.TrimSpace(utm.Term),
+		Content:  strings.TrimSpace(utm.Content),
+	}
+	if normalized == (models.UTM{}) {
+		return nil
+	}
+	return &normalized
+}
+
+// applyUTM sets the UTM parameters on the URL, replacing the values it already has.
+// Parameters that are not set are left as they are.
+func applyUTM(originalURL string, utm *models.UTM) (string, error) {
+	parsed, err := url.Parse(originalURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := parsed.Query()
+	params := []struct{ key, value string }{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{"utm_campaign", utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	}
+	for _, param := range params {
+		if param.value != "" {
+			query.Set(param.key, param.value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// DeleteURLs deletes the specified URLs, given by short ID or full short URL.
 func (r *Repository) DeleteURLs(ctx context.Context, userID string, urls []string) error {
 	for _, url := range urls {
 		r.DeleteManager.TaskChan <- models.DeleteTask{
 			UserID: userID,
-			URL:    url,
+			URL:    r.domains.KeyOf(url),
 		}
 	}
 	return nil
 }
 
-// GetUserURLs retrieves all URLs associated with a user.
-func (r *Repository) GetUserURLs(ctx context.Context, userID string) ([]storage.SavedURL, error) {
-	urls, err := r.storage.GetByUser(ctx, userID)
+// GetUserURLs retrieves all URLs associated with a user that match the filter.
+func (r *Repository) GetUserURLs(ctx context.Context, userID string, filter storage.URLFilter) ([]storage.SavedURL, error) {
+	filter.Tag = normalizeTag(filter.Tag)
+	urls, err := r.storage.GetByUser(ctx, userID, filter)
 	if err != nil {
 		return nil, err
 	}
 	return urls, nil
 }
 
-// GetURL retrieves a URL by its short ID.
+// ExportUserURLs calls fn for every link of the user that matches the filter and is not deleted.
+// The links are passed on while they are read from the storage.
+func (r *Repository) ExportUserURLs(ctx context.Context, userID string, filter storage.URLFilter, fn func(storage.SavedURL) error) error {
+	filter.Tag = normalizeTag(filter.Tag)
+	return r.storage.ExportByUser(ctx, userID, filter, func(savedURL storage.SavedURL) error {
+		if savedURL.IsDeleted {
+			return nil
+		}
+		return fn(savedURL)
+	})
+}
+
+// GetUserTags retrieves the tags of a user's links with the number of links per tag.
+func (r *Repository) GetUserTags(ctx context.Context, userID string) ([]storage.TagCount, error) {
+	return r.storage.GetTags(ctx, userID)
+}
+
+// UpdateTags replaces the tags of a link owned by the user, given by short ID or full short URL.
+func (r *Repository) UpdateTags(ctx context.Context, userID, shortURL string, tags []string) error {
+	key := r.domains.KeyOf(shortURL)
+	if err := r.storage.UpdateTags(ctx, userID, key, normalizeTags(tags)); err != nil {
+		return err
+	}
+
+	// The event carries the whole link, which is only read if somebody receives it.
+	if r.Webhooks.Subscribed(ctx, userID, webhooks.EventUpdated) {
+		if savedURL, err := r.storage.Get(ctx, key); err == nil {
+			r.notify(ctx, userID, r.linkEvent(webhooks.EventUpdated, savedURL, []string{"tags"}))
+		}
+	}
+	return nil
+}
+
+// UpdateVariantWeights changes the weights of the A/B variants of a link owned by the user,
+// given by short ID or full short URL. Variants that are not in weights keep their weight.
+func (r *Repository) UpdateVariantWeights(ctx context.Context, userID, shortURL string, weights map[string]int) error {
+	savedURL, err := r.ownedURL(ctx, userID, shortURL)
+	if err != nil {
+		return err
+	}
+
+	variants, err := split.SetWeights(savedURL.Variants, weights)
+	if err != nil {
+		return err
+	}
+	if err := r.storage.UpdateVariants(ctx, userID, savedURL.ShortURL, variants); err != nil {
+		return err
+	}
+	r.notify(ctx, userID, r.linkEvent(webhooks.EventUpdated, savedURL, []string{"variants"}))
+	return nil
+}
+
+// GetLinkStats returns the statistics of the clicks of a link owned by the user that match the filter.
+// The link is given by short ID or full short URL.
+func (r *Repository) GetLinkStats(ctx context.Context, userID, shortURL string, filter storage.ClickFilter) (storage.ClickStats, error) {
+	savedURL, err := r.ownedURL(ctx, userID, shortURL)
+	if err != nil {
+		return storage.ClickStats{}, err
+	}
+	return r.storage.GetClickStats(ctx, savedURL.ShortURL, filter)
+}
+
+// ownedURL returns the link if it belongs to the user, and ErrURLNotFound otherwise.
+func (r *Repository) ownedURL(ctx context.Context, userID, shortURL string) (storage.SavedURL, error) {
+	savedURL, err := r.storage.Get(ctx, r.domains.KeyOf(shortURL))
+	if err != nil {
+		return storage.SavedURL{}, err
+	}
+	if savedURL.UserID != userID {
+		return storage.SavedURL{}, storage.ErrURLNotFound
+	}
+	return savedURL, nil
+}
+
+// GetURL retrieves a URL by its short ID or full short URL.
 func (r *Repository) GetURL(ctx context.Context, id string) (storage.SavedURL, error) {
-	savedURL, err := r.storage.Get(ctx, id)
+	savedURL, err := r.storage.Get(ctx, r.domains.KeyOf(id))
 	if err != nil {
 		return storage.SavedURL{}, err
 	}
 	return savedURL, nil
 }
 
+// GetURLOnHost retrieves the URL a request for the short ID on the host redirects to.
+// Unknown hosts are treated as the default domain.
+func (r *Repository) GetURLOnHost(ctx context.Context, host, id string) (storage.SavedURL, error) {
+	return r.storage.Get(ctx, r.domains.Key(r.domains.ForHost(host), id))
+}
+
+// ConsumeClick takes one click from the limit of the link before it is served.
+// It returns storage.ErrClickLimitReached once the link has served all of its clicks.
+func (r *Repository) ConsumeClick(ctx context.Context, savedURL storage.SavedURL) error {
+	if savedURL.MaxClicks <= 0 {
+		return nil
+	}
+	return r.storage.ConsumeClick(ctx, savedURL.ShortURL)
+}
+
+// ShortURL returns the full short URL of a saved link.
+func (r *Repository) ShortURL(key string) string {
+	return r.domains.ShortURL(key)
+}
+
 // GetStats retrieves statistics for URLs and users.
 func (r *Repository) GetStats(ctx context.Context) (storage.Stats, error) {
 	stats, err := r.storage.GetStats(ctx)
@@ -82,27 +442,223 @@ func (r *Repository) GetStats(ctx context.Context) (storage.Stats, error) {
 	return stats, nil
 }
 
-// SaveURLArray saves an array of URLs to the storage and returns the saved URLs.
-func (r *Repository) SaveURLArray(ctx context.Context, urls []models.RequestShortenerURLBatch, userID string) ([]models.ResponseShortenerURLBatch, error) {
-	var savedURLs []models.ResponseShortenerURLBatch
+// SaveURLArray saves an array of URLs to the storage and returns a result for every URL.
+// Invalid and blocked URLs are reported with a reason instead of failing the whole batch.
+// If atomic is true, nothing is saved unless every URL can be created, and ErrBatchRejected is returned
+// together with the results that explain which URLs caused it.
+func (r *Repository) SaveURLArray(ctx context.Context, urls []models.RequestShortenerURLBatch, userID string, atomic bool) ([]models.ResponseShortenerURLBatch, error) {
+	results := make([]models.ResponseShortenerURLBatch, len(urls))
 	var savedURLsData []storage.SavedURL
+	var indexes []int
+	rejected := false
+
+	for i, item := range urls {
+		savedURL, err := r.newLink(ctx, item.URL, userID, item.LinkOptions)
+		if err != nil {
+			reason, ok := rejectionReason(err)
+			if !ok {
+				return nil, err
+			}
+			results[i] = models.ResponseShortenerURLBatch{ID: item.ID, Status: string(storage.SaveInvalid), Reason: reason}
+			rejected = true
+			continue
+		}
+		savedURLsData = append(savedURLsData, savedURL)
+		indexes = append(indexes, i)
+	}
+
+	var saveResults []storage.SaveResult
+	if atomic && rejected {
+		saveResults = make([]storage.SaveResult, len(savedURLsData))
+		for i := range saveResults {
+			saveResults[i].Status = storage.SaveSkipped
+		}
+	} else if len(savedURLsData) > 0 {
+		var err error
+		saveResults, err = r.storage.SaveArray(ctx, savedURLsData, atomic)
+		if errors.Is(err, storage.ErrURLConflict) && atomic {
+			rejected = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var created []webhooks.Event
+	for j, result := range saveResults {
+		item := urls[indexes[j]]
+		if result.Status == storage.SaveCreated {
+			created = append(created, r.linkEvent(webhooks.EventCreated, savedURLsData[j], nil))
+		}
+		switch result.Status {
+		case storage.SaveCreated, storage.SaveExisting:
+			results[indexes[j]] = *models.NewResponseShortenerURLBatch(item.ID, r.domains.ShortURL(result.ShortURL), string(result.Status))
+		case storage.SaveTaken:
+			results[indexes[j]] = models.ResponseShortenerURLBatch{ID: item.ID, Status: string(storage.SaveInvalid), Reason: "short url is already taken"}
+		default:
+			results[indexes[j]] = *models.NewResponseShortenerURLBatch(item.ID, "", string(result.Status))
+		}
+	}
+	r.notify(ctx, userID, created...)
+
+	if atomic && rejected {
+		return results, ErrBatchRejected
+	}
+	return results, nil
+}
+
+// rejectionReason returns the reason why the URL was rejected by validation, screening or its options.
+// It reports false for other errors.
+func rejectionReason(err error) (string, bool) {
+	var validationErr *urlnormalizer.ValidationError
+	var blockedErr *screening.BlockedError
+
+	switch {
+	case errors.As(err, &validationErr):
+		return validationErr.Reason, true
+	case errors.As(err, &blockedErr):
+		return blockedErr.Verdict.Reason, true
+	case errors.Is(err, domains.ErrUnknownDomain), errors.Is(err, ErrInvalidRedirectStatus), errors.Is(err, targeting.ErrInvalidTarget), errors.Is(err, split.ErrInvalidVariants),
+		errors.Is(err, ErrInvalidMaxClicks), errors.Is(err, ErrInvalidWindow), errors.Is(err, ErrInvalidOpenGraph):
+		return err.Error(), true
+	default:
+		return "", false
+	}
+}
+
+// ImportURLs reads links from the reader and saves them for the user in chunks, recording the progress in the job.
+// Malformed, invalid and blocked records are added to the job's error report and the import goes on.
+// Records without an alias get a new short ID if the generated one is taken.
+// An error is returned only if the import has to stop, it wraps importer.ErrMalformedImport
+// if the reader failed and the storage error otherwise.
+func (r *Repository) ImportURLs(ctx context.Context, userID string, reader importer.Reader, job *importer.Job) error {
+	chunk := make([]pendingImport, 0, importChunkSize)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recordErr *importer.RecordError
+		if errors.As(err, &recordErr) {
+			job.AddFailed(recordErr.Line, "", recordErr.Reason)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", importer.ErrMalformedImport, err)
+		}
 
-	for _, item := range urls {
-		if item.URL == "" {
-			return nil, errors.New("original url is empty check request body")
+		pending, err := r.prepareImport(ctx, userID, record)
+		if err != nil {
+			reason, ok := rejectionReason(err)
+			if !ok {
+				reason = err.Error()
+			}
+			job.AddFailed(record.Line, record.URL, reason)
+			continue
 		}
-		shortID := urlgenerator.CreateShortLink()
-		savedURL := storage.NewSavedURL(shortID, item.URL, userID)
-		savedURLsData = append(savedURLsData, *savedURL)
-		savedURLs = append(savedURLs, *models.NewResponseShortenerURLBatch(item.ID, fmt.Sprintf("%s/%s", r.redirectHost, shortID)))
+
+		chunk = append(chunk, pending)
+		if len(chunk) == importChunkSize {
+			if err := r.importChunk(ctx, chunk, job); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+
+	return r.importChunk(ctx, chunk, job)
+}
+
+// pendingImport is a record that is ready to be saved.
+type pendingImport struct {
+	record   importer.Record
+	domain   domains.Domain
+	savedURL storage.SavedURL
+	attempts int
+}
+
+// prepareImport validates the record and builds the link to save.
+func (r *Repository) prepareImport(ctx context.Context, userID string, record importer.Record) (pendingImport, error) {
+	shortDomain, err := r.domains.Lookup(record.Domain)
+	if err != nil {
+		return pendingImport{}, err
 	}
 
-	err := r.storage.SaveArray(ctx, savedURLsData)
+	originalURL, err := r.prepareURL(ctx, record.URL)
 	if err != nil {
-		return nil, err
+		return pendingImport{}, err
+	}
+
+	shortID := record.Alias
+	if shortID == "" {
+		shortID = urlgenerator.CreateShortLink()
+	} else if err := importer.ValidateAlias(shortID); err != nil {
+		return pendingImport{}, err
 	}
 
-	return savedURLs, nil
+	savedURL := storage.NewSavedURL(r.domains.Key(shortDomain, shortID), originalURL, userID)
+	savedURL.Tags = normalizeTags(record.Tags)
+	savedURL.CreatedAt = createdAt()
+	return pendingImport{record: record, domain: shortDomain, savedURL: *savedURL, attempts: 1}, nil
+}
+
+// importChunk saves the records and counts the results in the job.
+// Records whose generated short ID is taken are saved again with a new one.
+func (r *Repository) importChunk(ctx context.Context, chunk []pendingImport, job *importer.Job) error {
+	for len(chunk) > 0 {
+		savedURLs := make([]storage.SavedURL, len(chunk))
+		for i, pending := range chunk {
+			savedURLs[i] = pending.savedURL
+		}
+
+		results, err := r.storage.Import(ctx, savedURLs)
+		if err != nil {
+			return err
+		}
+
+		var retry []pendingImport
+		for i, result := range results {
+			pending := chunk[i]
+			switch result.Status {
+			case storage.SaveCreated:
+				job.AddCreated()
+			case storage.SaveExisting:
+				job.AddExisting()
+			default:
+				if pending.record.Alias != "" {
+					job.AddFailed(pending.record.Line, pending.record.URL, fmt.Sprintf("alias %q is already taken", pending.record.Alias))
+					continue
+				}
+				if pending.attempts >= maxImportAttempts {
+					job.AddFailed(pending.record.Line, pending.record.URL, "short url is already taken")
+					continue
+				}
+				pending.savedURL.ShortURL = r.domains.Key(pending.domain, urlgenerator.CreateShortLink())
+				pending.attempts++
+				retry = append(retry, pending)
+			}
+		}
+		chunk = retry
+	}
+	return nil
+}
+
+// ScreenURL checks a saved URL against the local block and allow lists.
+// It is used on redirect, so links blocked after creation stop working.
+func (r *Repository) ScreenURL(originalURL string) screening.Verdict {
+	return r.screener.ScreenLocal(originalURL)
+}
+
+// prepareURL canonicalizes the URL and rejects it if screening blocks it.
+func (r *Repository) prepareURL(ctx context.Context, rawURL string) (string, error) {
+	originalURL, err := r.normalizer.Normalize(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if verdict := r.screener.Screen(ctx, originalURL); verdict.Blocked {
+		return "", &screening.BlockedError{URL: originalURL, Verdict: verdict}
+	}
+	return originalURL, nil
 }
 
 // PingDB checks the connectivity to the database by pinging it.
@@ -114,3 +670,21 @@ func (r *Repository) PingDB(ctx context.Context) error {
 func (r *Repository) CloseDB() error {
 	return r.storage.Close()
 }
+
+// normalizeTags trims and lowercases tags, dropping empty and duplicate ones.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || storage.HasTag(normalized, tag) {
+			continue
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// normalizeTag trims and lowercases a single tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
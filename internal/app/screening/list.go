@@ -0,0 +1,161 @@
+package screening
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+// regexpPrefix marks a list entry as a regular expression matched against the whole URL.
+const regexpPrefix = "re:"
+
+// List is a set of domains and regular expressions loaded from a file.
+// Every line of the file is either a domain, which also matches its subdomains,
+// a regular expression prefixed with "re:", or a comment starting with "#".
+// A nil List matches nothing.
+type List struct {
+	path    string
+	logger  *zap.Logger
+	mu      sync.RWMutex
+	domains map[string]bool
+	regexps []*regexp.Regexp
+	modTime time.Time
+	size    int64
+}
+
+// LoadList reads the list from the file at path.
+func LoadList(path string, logger *zap.Logger) (*List, error) {
+	list := &List{path: path, logger: logger}
+	if err := list.Reload(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Reload reads the file again and replaces the list entries.
+// The previous entries are kept if the file can't be parsed.
+func (l *List) Reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	domains := make(map[string]bool)
+	var regexps []*regexp.Regexp
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if pattern, ok := strings.CutPrefix(line, regexpPrefix); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", l.path, lineNumber, err)
+			}
+			regexps = append(regexps, re)
+			continue
+		}
+
+		domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(line, "."))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", l.path, lineNumber, err)
+		}
+		domains[strings.ToLower(domain)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.domains = domains
+	l.regexps = regexps
+	l.modTime = info.ModTime()
+	l.size = info.Size()
+	return nil
+}
+
+// Watch reloads the list whenever the file changes until the context is done.
+// The file is polled at the given interval.
+func (l *List) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !l.changed() {
+				continue
+			}
+			if err := l.Reload(); err != nil {
+				l.logger.Sugar().Errorf("screening list reload error: %v", err)
+				continue
+			}
+			l.logger.Sugar().Infof("screening list %s reloaded", l.path)
+		}
+	}
+}
+
+// changed reports whether the file was modified since the last load.
+func (l *List) changed() bool {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return !info.ModTime().Equal(l.modTime) || info.Size() != l.size
+}
+
+// Match returns the entry that matches the URL.
+func (l *List) Match(u *url.URL) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	host := strings.ToLower(u.Hostname())
+	for domain := host; domain != ""; {
+		if l.domains[domain] {
+			return domain, true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	rawURL := u.String()
+	for _, re := range l.regexps {
+		if re.MatchString(rawURL) {
+			return regexpPrefix + re.String(), true
+		}
+	}
+	return "", false
+}
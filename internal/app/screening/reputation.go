@@ -0,0 +1,76 @@
+package screening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ReputationChecker asks an external reputation service whether a URL is malicious.
+// The service receives {"url": "..."} as a POST request and answers with
+// {"malicious": true, "reason": "..."}.
+type ReputationChecker struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewReputationChecker creates a new ReputationChecker for the service at endpoint.
+func NewReputationChecker(endpoint string, timeout time.Duration) *ReputationChecker {
+	return &ReputationChecker{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// reputationRequest is the body sent to the reputation service.
+type reputationRequest struct {
+	URL string `json:"url"`
+}
+
+// reputationResponse is the body returned by the reputation service.
+type reputationResponse struct {
+	Malicious bool   `json:"malicious"`
+	Reason    string `json:"reason"`
+}
+
+// Check implements the Checker interface.
+func (c *ReputationChecker) Check(ctx context.Context, rawURL string) (Verdict, error) {
+	body, err := json.Marshal(reputationRequest{URL: rawURL})
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("reputation service responded with status %d", resp.StatusCode)
+	}
+
+	var response reputationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Verdict{}, err
+	}
+
+	if !response.Malicious {
+		return Verdict{}, nil
+	}
+
+	reason := response.Reason
+	if reason == "" {
+		reason = "reported as malicious"
+	}
+	return Verdict{Blocked: true, Reason: reason, Source: "reputation"}, nil
+}
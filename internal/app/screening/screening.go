@@ -0,0 +1,120 @@
+// Package screening provides checks that keep malicious URLs from being shortened or followed.
+package screening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// ErrBlocked is returned when a URL is rejected by screening.
+var ErrBlocked = errors.New("url is blocked")
+
+// BlockedError describes why a URL was blocked.
+type BlockedError struct {
+	URL     string
+	Verdict Verdict
+}
+
+// Error implements the error interface.
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrBlocked, e.Verdict.Reason)
+}
+
+// Unwrap allows errors.Is to match ErrBlocked.
+func (e *BlockedError) Unwrap() error {
+	return ErrBlocked
+}
+
+// Verdict is the result of screening a URL.
+type Verdict struct {
+	Blocked bool   `json:"blocked"`
+	Reason  string `json:"reason,omitempty"`
+	Source  string `json:"source,omitempty"`
+}
+
+// Checker is a source of screening verdicts, such as an external reputation service.
+type Checker interface {
+	// Check returns the verdict for the URL.
+	Check(ctx context.Context, rawURL string) (Verdict, error)
+}
+
+// Screener combines an allowlist, a blocklist and external checkers.
+// A nil Screener allows every URL.
+type Screener struct {
+	allowlist *List
+	blocklist *List
+	checkers  []Checker
+	logger    *zap.Logger
+}
+
+// NewScreener creates a new Screener.
+// The allowlist and blocklist may be nil.
+func NewScreener(allowlist, blocklist *List, logger *zap.Logger, checkers ...Checker) *Screener {
+	return &Screener{
+		allowlist: allowlist,
+		blocklist: blocklist,
+		checkers:  checkers,
+		logger:    logger,
+	}
+}
+
+// Screen checks the URL against every source.
+// URLs on the allowlist are never blocked. Errors of external checkers are logged
+// and ignored, so an unavailable reputation service does not stop link creation.
+func (s *Screener) Screen(ctx context.Context, rawURL string) Verdict {
+	if s == nil {
+		return Verdict{}
+	}
+
+	verdict, allowed := s.checkLists(rawURL)
+	if allowed || verdict.Blocked {
+		return verdict
+	}
+
+	for _, checker := range s.checkers {
+		verdict, err := checker.Check(ctx, rawURL)
+		if err != nil {
+			s.logger.Sugar().Errorf("screening check failed: %v", err)
+			continue
+		}
+		if verdict.Blocked {
+			return verdict
+		}
+	}
+	return Verdict{}
+}
+
+// ScreenLocal checks the URL against the allowlist and blocklist only.
+// It is cheap enough to be used on every redirect.
+func (s *Screener) ScreenLocal(rawURL string) Verdict {
+	if s == nil {
+		return Verdict{}
+	}
+	verdict, _ := s.checkLists(rawURL)
+	return verdict
+}
+
+// checkLists returns the blocklist verdict and whether the URL is on the allowlist.
+func (s *Screener) checkLists(rawURL string) (Verdict, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return Verdict{Blocked: true, Reason: "url can't be parsed", Source: "screening"}, false
+	}
+
+	if _, ok := s.allowlist.Match(parsed); ok {
+		return Verdict{}, true
+	}
+
+	if entry, ok := s.blocklist.Match(parsed); ok {
+		return Verdict{
+			Blocked: true,
+			Reason:  fmt.Sprintf("matches blocklist entry %q", entry),
+			Source:  "blocklist",
+		}, false
+	}
+	return Verdict{}, false
+}
@@ -0,0 +1,159 @@
+package screening
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeChecker struct {
+	verdict Verdict
+	err     error
+	calls   int
+}
+
+func (c *fakeChecker) Check(ctx context.Context, rawURL string) (Verdict, error) {
+	c.calls++
+	return c.verdict, c.err
+}
+
+func writeList(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestListMatch(t *testing.T) {
+	list, err := LoadList(writeList(t, `
+# phishing domains
+evil.com
+Пример.рф
+re:/wp-login\.php$
+`), zap.NewNop())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		rawURL string
+		match  bool
+	}{
+		{rawURL: "https://evil.com/", match: true},
+		{rawURL: "https://login.evil.com/a", match: true},
+		{rawURL: "https://notevil.com/", match: false},
+		{rawURL: "https://xn--e1afmkfd.xn--p1ai/", match: true},
+		{rawURL: "https://example.com/wp-login.php", match: true},
+		{rawURL: "https://example.com/", match: false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		_, ok := list.Match(u)
+		assert.Equal(t, tt.match, ok, tt.rawURL)
+	}
+}
+
+func TestListInvalidRegexp(t *testing.T) {
+	_, err := LoadList(writeList(t, "re:(unclosed"), zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestListWatchReloadsOnChange(t *testing.T) {
+	path := writeList(t, "evil.com\n")
+	list, err := LoadList(path, zap.NewNop())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go list.Watch(ctx, 10*time.Millisecond)
+
+	u, _ := url.Parse("https://other-evil.com/")
+	_, ok := list.Match(u)
+	assert.False(t, ok)
+
+	assert.NoError(t, os.WriteFile(path, []byte("evil.com\nother-evil.com\n"), 0644))
+
+	assert.Eventually(t, func() bool {
+		_, ok := list.Match(u)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScreenerAllowlistWins(t *testing.T) {
+	allowlist, err := LoadList(writeList(t, "good.evil.com\n"), zap.NewNop())
+	assert.NoError(t, err)
+	blocklist, err := LoadList(writeList(t, "evil.com\n"), zap.NewNop())
+	assert.NoError(t, err)
+	checker := &fakeChecker{verdict: Verdict{Blocked: true, Reason: "malware"}}
+
+	screener := NewScreener(allowlist, blocklist, zap.NewNop(), checker)
+
+	assert.False(t, screener.Screen(context.Background(), "https://good.evil.com/").Blocked)
+	assert.Equal(t, 0, checker.calls)
+
+	verdict := screener.Screen(context.Background(), "https://bad.evil.com/")
+	assert.True(t, verdict.Blocked)
+	assert.Equal(t, "blocklist", verdict.Source)
+	assert.Equal(t, 0, checker.calls)
+
+	verdict = screener.Screen(context.Background(), "https://example.com/")
+	assert.True(t, verdict.Blocked)
+	assert.Equal(t, "malware", verdict.Reason)
+	assert.Equal(t, 1, checker.calls)
+
+	assert.False(t, screener.ScreenLocal("https://example.com/").Blocked)
+}
+
+func TestScreenerIgnoresCheckerErrors(t *testing.T) {
+	checker := &fakeChecker{err: errors.New("service unavailable")}
+	screener := NewScreener(nil, nil, zap.NewNop(), checker)
+
+	assert.False(t, screener.Screen(context.Background(), "https://example.com/").Blocked)
+	assert.Equal(t, 1, checker.calls)
+}
+
+func TestNilScreener(t *testing.T) {
+	var screener *Screener
+	assert.False(t, screener.Screen(context.Background(), "https://example.com/").Blocked)
+	assert.False(t, screener.ScreenLocal("https://example.com/").Blocked)
+}
+
+func TestReputationChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request reputationRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		json.NewEncoder(w).Encode(reputationResponse{
+			Malicious: request.URL == "https://phishing.example/",
+			Reason:    "phishing",
+		})
+	}))
+	defer server.Close()
+
+	checker := NewReputationChecker(server.URL, time.Second)
+
+	verdict, err := checker.Check(context.Background(), "https://phishing.example/")
+	assert.NoError(t, err)
+	assert.Equal(t, Verdict{Blocked: true, Reason: "phishing", Source: "reputation"}, verdict)
+
+	verdict, err = checker.Check(context.Background(), "https://example.com/")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Blocked)
+}
+
+func TestReputationCheckerServiceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewReputationChecker(server.URL, time.Second).Check(context.Background(), "https://example.com/")
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+// Package split sends the visitors of a link to one of several weighted destinations.
+//
+// A visitor keeps the variant they were sent to first: the ID of the variant is stored in a
+// cookie scoped to the link and reused as long as the variant still receives traffic.
+package split
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+)
+
+const (
+	// MaxVariants is the number of variants a link may have.
+	MaxVariants = 10
+	// maxIDLength limits the length of variant IDs.
+	maxIDLength = 32
+	// cookieName is the name of the cookie that keeps the variant of a visitor.
+	cookieName = "variant"
+	// cookieMaxAge is how long a visitor keeps their variant.
+	cookieMaxAge = 30 * 24 * time.Hour
+)
+
+// ErrInvalidVariants is returned for variants that can't be used to split traffic.
+var ErrInvalidVariants = errors.New("invalid variants")
+
+// Normalize checks the variants and names the unnamed ones a, b, c and so on.
+// The URLs of the variants are left to the caller.
+func Normalize(variants []models.Variant) ([]models.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 || len(variants) > MaxVariants {
+		return nil, fmt.Errorf("%w: a link needs between 2 and %d variants", ErrInvalidVariants, MaxVariants)
+	}
+
+	normalized := make([]models.Variant, len(variants))
+	seen := make(map[string]bool, len(variants))
+	for i, variant := range variants {
+		variant.ID = strings.ToLower(strings.TrimSpace(variant.ID))
+		if variant.ID == "" {
+			variant.ID = string(rune('a' + i))
+		}
+		if !validID(variant.ID) {
+			return nil, fmt.Errorf("%w: invalid id %q, use up to %d letters, digits, - and _", ErrInvalidVariants, variant.ID, maxIDLength)
+		}
+		if seen[variant.ID] {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrInvalidVariants, variant.ID)
+		}
+		seen[variant.ID] = true
+
+		if strings.TrimSpace(variant.URL) == "" {
+			return nil, fmt.Errorf("%w: variant %q has no url", ErrInvalidVariants, variant.ID)
+		}
+		normalized[i] = variant
+	}
+
+	if err := checkWeights(normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// SetWeights returns a copy of the variants with the weights changed.
+// Variants that are not in weights keep their weight.
+func SetWeights(variants []models.Variant, weights map[string]int) ([]models.Variant, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%w: the link has no variants", ErrInvalidVariants)
+	}
+
+	updated := make([]models.Variant, len(variants))
+	copy(updated, variants)
+	for id, weight := range weights {
+		i := indexOf(updated, strings.ToLower(id))
+		if i < 0 {
+			return nil, fmt.Errorf("%w: unknown variant %q", ErrInvalidVariants, id)
+		}
+		updated[i].Weight = weight
+	}
+
+	if err := checkWeights(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Choose returns the variant for a visitor who was sent to the variant with stickyID before.
+// A new variant is picked if the visitor has none, or their variant no longer receives traffic.
+// It reports whether the variant was picked now and has to be stored for the visitor.
+func Choose(variants []models.Variant, stickyID string) (models.Variant, bool) {
+	if i := indexOf(variants, stickyID); i >= 0 && variants[i].Weight > 0 {
+		return variants[i], false
+	}
+	return pick(variants, rand.Intn), true
+}
+
+// FromRequest returns the variant ID stored for the visitor of the link.
+func FromRequest(r *http.Request) string {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Cookie returns the cookie that keeps the variant for the visitor of the link at path.
+func Cookie(path string, variant models.Variant) *http.Cookie {
+	return &http.Cookie{
+		Name:     cookieName,
+		Value:    variant.ID,
+		Path:     path,
+		MaxAge:   int(cookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// pick returns a variant with a probability proportional to its weight.
+// intn returns a random number in [0, n).
+func pick(variants []models.Variant, intn func(n int) int) models.Variant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+
+	n := intn(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+// checkWeights checks that no weight is negative and at least one variant receives traffic.
+func checkWeights(variants []models.Variant) error {
+	total := 0
+	for _, variant := range variants {
+		if variant.Weight < 0 {
+			return fmt.Errorf("%w: variant %q has a negative weight", ErrInvalidVariants, variant.ID)
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: at least one variant needs a positive weight", ErrInvalidVariants)
+	}
+	return nil
+}
+
+// indexOf returns the index of the variant with the ID, or -1.
+func indexOf(variants []models.Variant, id string) int {
+	if id == "" {
+		return -1
+	}
+	for i, variant := range variants {
+		if variant.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// validID reports whether the ID can be used in a cookie and in analytics.
+func validID(id string) bool {
+	if len(id) > maxIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	variants, err := Normalize([]models.Variant{
+		{URL: "https://example.com/a", Weight: 70},
+		{ID: " Green ", URL: "https://example.com/b", Weight: 30},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", variants[0].ID)
+	assert.Equal(t, "green", variants[1].ID)
+
+	invalid := [][]models.Variant{
+		{{URL: "https://example.com/a", Weight: 1}},
+		{{ID: "x", URL: "https://example.com/a", Weight: 1}, {ID: "x", URL: "https://example.com/b", Weight: 1}},
+		{{URL: "https://example.com/a", Weight: 0}, {URL: "https://example.com/b", Weight: 0}},
+		{{URL: "https://example.com/a", Weight: -1}, {URL: "https://example.com/b", Weight: 2}},
+		{{ID: "a b", URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: 1}},
+		{{URL: "https://example.com/a", Weight: 1}, {Weight: 1}},
+	}
+	for _, variants := range invalid {
+		_, err := Normalize(variants)
+		assert.ErrorIs(t, err, ErrInvalidVariants)
+	}
+}
+
+func TestSetWeights(t *testing.T) {
+	variants := []models.Variant{{ID: "a", Weight: 50}, {ID: "b", Weight: 50}}
+
+	updated, err := SetWeights(variants, map[string]int{"B": 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Variant{{ID: "a", Weight: 50}, {ID: "b", Weight: 0}}, updated)
+	assert.Equal(t, 50, variants[1].Weight, "variants are not changed in place")
+
+	_, err = SetWeights(variants, map[string]int{"c": 10})
+	assert.ErrorIs(t, err, ErrInvalidVariants)
+	_, err = SetWeights(variants, map[string]int{"a": 0, "b": 0})
+	assert.ErrorIs(t, err, ErrInvalidVariants)
+}
+
+func TestPick(t *testing.T) {
+	variants := []models.Variant{{ID: "a", Weight: 1}, {ID: "b", Weight: 0}, {ID: "c", Weight: 3}}
+
+	counts := make(map[string]int)
+	for n := 0; n < 4; n++ {
+		counts[pick(variants, func(int) int { return n }).ID]++
+	}
+	assert.Equal(t, map[string]int{"a": 1, "c": 3}, counts)
+}
+
+func TestChooseIsSticky(t *testing.T) {
+	variants := []models.Variant{{ID: "a", Weight: 1}, {ID: "b", Weight: 1}}
+
+	variant, fresh := Choose(variants, "b")
+	assert.Equal(t, "b", variant.ID)
+	assert.False(t, fresh)
+
+	variants[1].Weight = 0
+	variant, fresh = Choose(variants, "b")
+	assert.Equal(t, "a", variant.ID, "a variant without traffic is not kept")
+	assert.True(t, fresh)
+}
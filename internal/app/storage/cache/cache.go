@@ -0,0 +1,264 @@
+// Package cache provides a read-through cache of short URL lookups in front of a storage.
+//
+// Lookups are cached in an in-process LRU and, optionally, in a Backend shared by
+// every instance of the service. Unknown short URLs are cached too, with a shorter
+// lifetime. Every write that may change the result of a lookup invalidates the
+// affected entries locally and through the Backend, which notifies other instances.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// Entry is a cached lookup. Found is false for short URLs that do not exist.
+type Entry struct {
+	URL   storage.SavedURL `json:"url"`
+	Found bool             `json:"found"`
+}
+
+// result converts the entry to the values returned by Storage.Get.
+func (e Entry) result() (storage.SavedURL, error) {
+	if !e.Found {
+		return storage.SavedURL{}, storage.ErrURLNotFound
+	}
+	return e.URL, nil
+}
+
+// Backend is a cache shared between instances, such as Redis.
+// A lookup that races with a write on another instance may leave a stale entry
+// in the Backend, which lives until it expires.
+type Backend interface {
+	// Get returns the cached entry of the key and false if there is none.
+	Get(ctx context.Context, key string) (Entry, bool, error)
+
+	// Set caches the entry for ttl.
+	Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error
+
+	// Invalidate removes the keys and notifies every subscriber.
+	// Invalidate with no keys removes every entry.
+	Invalidate(ctx context.Context, keys []string) error
+
+	// Subscribe calls fn with the keys invalidated by any instance until ctx is done.
+	// The keys are empty when every entry was removed.
+	Subscribe(ctx context.Context, fn func(keys []string)) error
+}
+
+// Options configures a CachedStorage.
+type Options struct {
+	// Size is the number of entries kept in memory.
+	Size int
+	// TTL is the lifetime of cached URLs.
+	TTL time.Duration
+	// MissTTL is the lifetime of cached lookups of unknown short URLs.
+	MissTTL time.Duration
+	// Backend is the optional shared cache.
+	Backend Backend
+	// Logger reports Backend failures, which never fail a request.
+	Logger *zap.Logger
+}
+
+// CachedStorage is a storage.Storage that caches the result of Get.
+// Methods that are not overridden go straight to the wrapped storage.
+type CachedStorage struct {
+	storage.Storage
+	local   *lru
+	backend Backend
+	ttl     time.Duration
+	missTTL time.Duration
+	logger  *zap.Logger
+	cancel  context.CancelFunc
+}
+
+// NewCachedStorage wraps the storage with a cache.
+// It subscribes to the invalidations of the Backend until the storage is closed.
+func NewCachedStorage(ctx context.Context, store storage.Storage, options Options) (*CachedStorage, error) {
+	logger := options.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	c := &CachedStorage{
+		Storage: store,
+		local:   newLRU(options.Size),
+		backend: options.Backend,
+		ttl:     options.TTL,
+		missTTL: options.MissTTL,
+		logger:  logger,
+		cancel:  func() {},
+	}
+
+	if c.backend != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		if err := c.backend.Subscribe(ctx, c.dropLocal); err != nil {
+			cancel()
+			return nil, err
+		}
+		c.cancel = cancel
+	}
+	return c, nil
+}
+
+// Get returns the URL from the local cache, the shared cache or the storage, in this order.
+func (c *CachedStorage) Get(ctx context.Context, key string) (storage.SavedURL, error) {
+	if entry, ok := c.local.get(key); ok {
+		return entry.result()
+	}
+	generation := c.local.currentGeneration()
+
+	if c.backend != nil {
+		entry, ok, err := c.backend.Get(ctx, key)
+		if err != nil {
+			c.logger.Sugar().Warnf("shared cache get error: %v", err)
+		} else if ok {
+			c.local.set(key, entry, c.lifetime(entry), generation)
+			return entry.result()
+		}
+	}
+
+	savedURL, err := c.Storage.Get(ctx, key)
+	entry := Entry{URL: savedURL, Found: true}
+	if errors.Is(err, storage.ErrURLNotFound) {
+		entry = Entry{}
+	} else if err != nil {
+		return savedURL, err
+	}
+
+	c.local.set(key, entry, c.lifetime(entry), generation)
+	if c.backend != nil {
+		if err := c.backend.Set(ctx, key, entry, c.lifetime(entry)); err != nil {
+			c.logger.Sugar().Warnf("shared cache set error: %v", err)
+		}
+	}
+	return entry.result()
+}
+
+// Save saves the URL and drops a cached miss of its short URL.
+func (c *CachedStorage) Save(ctx context.Context, savedURL storage.SavedURL) (string, error) {
+	shortURL, err := c.Storage.Save(ctx, savedURL)
+	if err == nil {
+		c.invalidate(ctx, []string{savedURL.ShortURL})
+	}
+	return shortURL, err
+}
+
+// SaveArray saves the URLs and drops cached misses of the created short URLs.
+func (c *CachedStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL, atomic bool) ([]storage.SaveResult, error) {
+	results, err := c.Storage.SaveArray(ctx, savedUrls, atomic)
+	c.invalidate(ctx, createdKeys(results))
+	return results, err
+}
+
+// Import imports the URLs and drops cached misses of the created short URLs.
+func (c *CachedStorage) Import(ctx context.Context, savedUrls []storage.SavedURL) ([]storage.SaveResult, error) {
+	results, err := c.Storage.Import(ctx, savedUrls)
+	c.invalidate(ctx, createdKeys(results))
+	return results, err
+}
+
+// UpdateTags updates the tags and drops the cached URL.
+func (c *CachedStorage) UpdateTags(ctx context.Context, userID string, shortURL string, tags []string) error {
+	err := c.Storage.UpdateTags(ctx, userID, shortURL, tags)
+	c.invalidate(ctx, []string{shortURL})
+	return err
+}
+
+// UpdateVariants updates the variants and drops the cached URL.
+func (c *CachedStorage) UpdateVariants(ctx context.Context, userID string, shortURL string, variants []models.Variant) error {
+	err := c.Storage.UpdateVariants(ctx, userID, shortURL, variants)
+	c.invalidate(ctx, []string{shortURL})
+	return err
+}
+
+// UpdateHealth records the results of destination checks and drops the checked URLs from the cache.
+func (c *CachedStorage) UpdateHealth(ctx context.Context, checks []storage.HealthCheck) error {
+	err := c.Storage.UpdateHealth(ctx, checks)
+	keys := make([]string, 0, len(checks))
+	for _, check := range checks {
+		keys = append(keys, check.ShortURL)
+	}
+	c.invalidate(ctx, keys)
+	return err
+}
+
+// Delete deletes the URLs and drops them from the cache.
+func (c *CachedStorage) Delete(ctx context.Context, deleteTaskSlice []models.DeleteTask) ([]storage.SavedURL, error) {
+	deleted, err := c.Storage.Delete(ctx, deleteTaskSlice)
+	keys := make([]string, 0, len(deleteTaskSlice))
+	for _, task := range deleteTaskSlice {
+		keys = append(keys, task.URL)
+	}
+	c.invalidate(ctx, keys)
+	return deleted, err
+}
+
+// Restore restores the URL and drops it from the cache.
+func (c *CachedStorage) Restore(ctx context.Context, shortURL string) error {
+	err := c.Storage.Restore(ctx, shortURL)
+	c.invalidate(ctx, []string{shortURL})
+	return err
+}
+
+// Clean cleans the storage and drops every cached entry.
+func (c *CachedStorage) Clean(ctx context.Context) error {
+	err := c.Storage.Clean(ctx)
+	c.local.clear()
+	if c.backend != nil {
+		if err := c.backend.Invalidate(ctx, nil); err != nil {
+			c.logger.Sugar().Warnf("shared cache invalidate error: %v", err)
+		}
+	}
+	return err
+}
+
+// Close stops listening to invalidations and closes the storage.
+func (c *CachedStorage) Close() error {
+	c.cancel()
+	return c.Storage.Close()
+}
+
+// invalidate drops the keys locally and in the shared cache.
+func (c *CachedStorage) invalidate(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	c.local.remove(keys)
+	if c.backend != nil {
+		if err := c.backend.Invalidate(ctx, keys); err != nil {
+			c.logger.Sugar().Warnf("shared cache invalidate error: %v", err)
+		}
+	}
+}
+
+// dropLocal handles an invalidation published through the Backend.
+func (c *CachedStorage) dropLocal(keys []string) {
+	if len(keys) == 0 {
+		c.local.clear()
+		return
+	}
+	c.local.remove(keys)
+}
+
+// lifetime returns how long the entry is cached.
+func (c *CachedStorage) lifetime(entry Entry) time.Duration {
+	if entry.Found {
+		return c.ttl
+	}
+	return c.missTTL
+}
+
+// createdKeys returns the short URLs of the created results.
+func createdKeys(results []storage.SaveResult) []string {
+	var keys []string
+	for _, result := range results {
+		if result.Status == storage.SaveCreated {
+			keys = append(keys, result.ShortURL)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend is a shared cache kept in memory that delivers invalidations synchronously.
+type fakeBackend struct {
+	mu          sync.Mutex
+	entries     map[string]Entry
+	subscribers []func(keys []string)
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{entries: make(map[string]Entry)}
+}
+
+func (f *fakeBackend) Get(ctx context.Context, key string) (Entry, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, ok := f.entries[key]
+	return entry, ok, nil
+}
+
+func (f *fakeBackend) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[key] = entry
+	return nil
+}
+
+func (f *fakeBackend) Invalidate(ctx context.Context, keys []string) error {
+	f.mu.Lock()
+	if len(keys) == 0 {
+		f.entries = make(map[string]Entry)
+	}
+	for _, key := range keys {
+		delete(f.entries, key)
+	}
+	subscribers := f.subscribers
+	f.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(keys)
+	}
+	return nil
+}
+
+func (f *fakeBackend) Subscribe(ctx context.Context, fn func(keys []string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, fn)
+	return nil
+}
+
+// countingStorage counts the lookups that reach the storage.
+type countingStorage struct {
+	storage.Storage
+	mu   sync.Mutex
+	gets int
+}
+
+func (s *countingStorage) Get(ctx context.Context, key string) (storage.SavedURL, error) {
+	s.mu.Lock()
+	s.gets++
+	s.mu.Unlock()
+	return s.Storage.Get(ctx, key)
+}
+
+func (s *countingStorage) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func newStorage(t *testing.T) *countingStorage {
+	m := &memory.MemoryStorage{}
+	assert.NoError(t, m.Init(context.Background()))
+	return &countingStorage{Storage: m}
+}
+
+func newCached(t *testing.T, store storage.Storage, backend Backend) *CachedStorage {
+	cached, err := NewCachedStorage(context.Background(), store, Options{
+		Size:    10,
+		TTL:     time.Minute,
+		MissTTL: time.Minute,
+		Backend: backend,
+	})
+	assert.NoError(t, err)
+	return cached
+}
+
+func TestGetCachesHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	store := newStorage(t)
+	cached := newCached(t, store, nil)
+
+	_, err := cached.Get(ctx, "abc")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = cached.Get(ctx, "abc")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	assert.Equal(t, 1, store.count())
+
+	_, err = cached.Save(ctx, *storage.NewSavedURL("abc", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		savedURL, err := cached.Get(ctx, "abc")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/", savedURL.OriginalURL)
+	}
+	assert.Equal(t, 2, store.count())
+}
+
+func TestDeleteInvalidatesOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	store := newStorage(t)
+	backend := newFakeBackend()
+	first := newCached(t, store, backend)
+	second := newCached(t, store, backend)
+
+	_, err := first.Save(ctx, *storage.NewSavedURL("abc", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	savedURL, err := first.Get(ctx, "abc")
+	assert.NoError(t, err)
+	assert.False(t, savedURL.IsDeleted)
+
+	savedURL, err = second.Get(ctx, "abc")
+	assert.NoError(t, err)
+	assert.False(t, savedURL.IsDeleted)
+	assert.Equal(t, 1, store.count(), "second instance reads the shared cache")
+
+	_, err = first.Delete(ctx, []models.DeleteTask{{URL: "abc", UserID: "alice"}})
+	assert.NoError(t, err)
+
+	savedURL, err = second.Get(ctx, "abc")
+	assert.NoError(t, err)
+	assert.True(t, savedURL.IsDeleted)
+
+	assert.NoError(t, second.UpdateTags(ctx, "alice", "abc", []string{"docs"}))
+	savedURL, err = first.Get(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docs"}, savedURL.Tags)
+}
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	cache := newLRU(2)
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", Entry{Found: true}, time.Minute, 0)
+	cache.set("b", Entry{Found: true}, time.Second, 0)
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+
+	cache.set("c", Entry{Found: true}, time.Minute, 0)
+	_, ok = cache.get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.get("a")
+	assert.False(t, ok, "expired entry is dropped")
+
+	generation := cache.currentGeneration()
+	cache.remove([]string{"c"})
+	cache.set("c", Entry{Found: true}, time.Minute, generation)
+	_, ok = cache.get("c")
+	assert.False(t, ok, "lookup started before an invalidation is not cached")
+}
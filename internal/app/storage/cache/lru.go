@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is an in-process cache of lookups with a size limit and per-entry expiry.
+type lru struct {
+	mu         sync.Mutex
+	size       int
+	items      map[string]*list.Element
+	order      *list.List
+	generation uint64
+	now        func() time.Time
+}
+
+// lruItem is an element of the lru list.
+type lruItem struct {
+	key     string
+	entry   Entry
+	expires time.Time
+}
+
+// newLRU creates an lru holding at most size entries.
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+// get returns the entry of the key if it is cached and not expired.
+func (c *lru) get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := element.Value.(*lruItem)
+	if !c.now().Before(item.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(element)
+	return item.entry, true
+}
+
+// currentGeneration returns the number of invalidations so far.
+// A lookup remembers it before reading the storage and passes it to set.
+func (c *lru) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// set caches the entry unless the cache was invalidated after the lookup started,
+// so a value read before a write never outlives the write.
+func (c *lru) set(key string, entry Entry, ttl time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	item := &lruItem{key: key, entry: entry, expires: c.now().Add(ttl)}
+	if element, ok := c.items[key]; ok {
+		element.Value = item
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(item)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+// remove drops the keys from the cache.
+func (c *lru) remove(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		if element, ok := c.items[key]; ok {
+			c.order.Remove(element)
+			delete(c.items, key)
+		}
+	}
+}
+
+// clear drops every entry.
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
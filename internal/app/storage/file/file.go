@@ -6,20 +6,32 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
 )
 
 // FileStorage represents a file storage for URLs.
+// DedupScope defines which URLs conflict with each other on Save.
 type FileStorage struct {
-	FilePath string
-	File     *os.File
+	FilePath   string
+	DedupScope storage.DedupScope
+	File       *os.File
+	// index finds conflicts of new URLs without reading the file, it is built when needed
+	// and dropped when the file is rewritten.
+	index *storage.URLIndex
+	// consumed counts the clicks taken from limited links since the file was last rewritten,
+	// it is loaded from the counters file when needed.
+	consumed map[string]int
 	mu       sync.Mutex
+	// webhooksMu guards the webhooks file and the outbox journal, so webhooks never wait for URLs.
+	webhooksMu sync.Mutex
 }
 
 // Init initializes the file storage.
@@ -81,70 +93,96 @@ func (fs *FileStorage) Save(ctx context.Context, savedURL storage.SavedURL) (str
 		return "", errors.New("file does not open")
 	}
 
-	scanner := bufio.NewScanner(fs.File)
-	for scanner.Scan() {
-		var url storage.SavedURL
-		if strings.Contains(scanner.Text(), savedURL.OriginalURL) {
-			err := json.Unmarshal(scanner.Bytes(), &url)
-			if err != nil {
-				return "", err
-			}
-			return url.ShortURL, storage.ErrURLConflict
-		}
-	}
-
-	data, err := json.Marshal(savedURL)
+	index, err := fs.urlIndex()
 	if err != nil {
 		return "", err
 	}
-
-	writer := bufio.NewWriter(fs.File)
-	if _, err := writer.Write(data); err != nil {
-		return "", err
+	if shortURL, ok := index.Conflict(savedURL); ok {
+		return shortURL, storage.ErrURLConflict
 	}
 
-	if err := writer.WriteByte('\n'); err != nil {
+	if err := fs.appendAll([]storage.SavedURL{savedURL}); err != nil {
 		return "", err
 	}
-
-	return "", writer.Flush()
+	index.Add(savedURL)
+	return "", nil
 }
 
 // SaveArray saves an array of URLs to the file.
-func (fs *FileStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL) error {
+// URLs that conflict with saved ones are reported as existing and are not written.
+func (fs *FileStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL, atomic bool) ([]storage.SaveResult, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.File == nil {
-		return errors.New("file does not open")
+	index, err := fs.urlIndex()
+	if err != nil {
+		return nil, err
 	}
 
-	writer := bufio.NewWriter(fs.File)
+	results, created := index.Resolve(savedUrls)
+	if atomic && storage.HasConflicts(results) {
+		storage.SkipCreated(results)
+		return results, storage.ErrURLConflict
+	}
 
-	for _, url := range savedUrls {
-		data, err := json.Marshal(url)
-		if err != nil {
-			return err
-		}
+	if err := fs.appendAll(created); err != nil {
+		// Part of the records may have been written, the index is built again from the file.
+		fs.index = nil
+		return nil, err
+	}
+	for _, savedURL := range created {
+		index.Add(savedURL)
+	}
+	return results, nil
+}
 
-		if _, err := writer.Write(data); err != nil {
-			return err
-		}
+// urlIndex returns the index of the URLs in the file and builds it with one pass over the file if there is none.
+// The caller must hold the lock.
+func (fs *FileStorage) urlIndex() (*storage.URLIndex, error) {
+	if fs.index != nil {
+		return fs.index, nil
+	}
+	if fs.File == nil {
+		return nil, errors.New("file does not open")
+	}
+	if _, err := fs.File.Seek(0, 0); err != nil {
+		return nil, err
+	}
 
-		if err := writer.WriteByte('\n'); err != nil {
-			return err
+	index := storage.NewURLIndex(fs.DedupScope)
+	scanner := bufio.NewScanner(fs.File)
+	for scanner.Scan() {
+		var savedURL storage.SavedURL
+		if err := json.Unmarshal(scanner.Bytes(), &savedURL); err != nil {
+			continue
 		}
+		index.Add(savedURL)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	return writer.Flush()
+	fs.index = index
+	return index, nil
+}
+
+// Import saves a chunk of imported URLs to the file in one append.
+// The index of the file is kept between chunks, so the file is read once per import, not once per chunk.
+func (fs *FileStorage) Import(ctx context.Context, savedUrls []storage.SavedURL) ([]storage.SaveResult, error) {
+	return fs.SaveArray(ctx, savedUrls, false)
 }
 
 // Get gets a URL from the file by its short URL.
 func (fs *FileStorage) Get(ctx context.Context, key string) (storage.SavedURL, error) {
-	var savedURL storage.SavedURL
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	return fs.get(key)
+}
+
+// get finds the record of the short URL in the file. The caller must hold the lock.
+func (fs *FileStorage) get(key string) (storage.SavedURL, error) {
+	var savedURL storage.SavedURL
 	if fs.File == nil {
 		return savedURL, errors.New("file does not open")
 	}
@@ -158,8 +196,10 @@ func (fs *FileStorage) Get(ctx context.Context, key string) (storage.SavedURL, e
 
 	for scanner.Scan() {
 		if strings.Contains(scanner.Text(), key) {
-			json.Unmarshal(scanner.Bytes(), &savedURL)
-			return savedURL, nil
+			var item storage.SavedURL
+			if err := json.Unmarshal(scanner.Bytes(), &item); err == nil && item.ShortURL == key {
+				return item, fs.applyConsumed(&item)
+			}
 		}
 	}
 
@@ -167,42 +207,109 @@ func (fs *FileStorage) Get(ctx context.Context, key string) (storage.SavedURL, e
 		return savedURL, err
 	}
 
-	return savedURL, errors.New("Nothing")
+	return savedURL, storage.ErrURLNotFound
 }
 
 // Delete deletes a URL from the file.
-func (fs *FileStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) error {
+func (fs *FileStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) ([]storage.SavedURL, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.File == nil {
-		return errors.New("file does not open")
+	urls, err := fs.readAll()
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := fs.File.Seek(0, 0)
+	var deleted []storage.SavedURL
+	for i, url := range urls {
+		for _, task := range taskSlice {
+			if url.ShortURL == task.URL && url.UserID == task.UserID && !urls[i].IsDeleted {
+				urls[i].IsDeleted = true
+				deleted = append(deleted, urls[i])
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	if err := fs.writeAll(urls); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Restore marks a deleted URL in the file as not deleted and rewrites the file.
+func (fs *FileStorage) Restore(ctx context.Context, shortURL string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
 	if err != nil {
 		return err
 	}
 
-	scanner := bufio.NewScanner(fs.File)
+	for i, url := range urls {
+		if url.ShortURL != shortURL {
+			continue
+		}
+		if _, ok := storage.RestoreConflict(fs.DedupScope, urls, i); ok {
+			return storage.ErrURLConflict
+		}
+		urls[i].IsDeleted = false
+		return fs.writeAll(urls)
+	}
 
-	var buffer []storage.SavedURL
+	return storage.ErrURLNotFound
+}
 
-	for scanner.Scan() {
-		for _, task := range taskSlice {
-			var savedURL storage.SavedURL
-			json.Unmarshal(scanner.Bytes(), &savedURL)
-			if savedURL.ShortURL == task.URL && savedURL.UserID == task.UserID {
-				savedURL.IsDeleted = true
-			}
-			buffer = append(buffer, savedURL)
+// Compact rewrites the file without deleted URLs, unreadable lines and repeated records of the same short URL.
+// The last record of a short URL wins. It returns the number of dropped records.
+func (fs *FileStorage) Compact(ctx context.Context) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
+	if err != nil {
+		return 0, err
+	}
 
+	last := make(map[string]int, len(urls))
+	for i, url := range urls {
+		last[url.ShortURL] = i
+	}
+
+	var compacted []storage.SavedURL
+	for i, url := range urls {
+		if last[url.ShortURL] == i && !url.IsDeleted {
+			compacted = append(compacted, url)
 		}
 	}
-	if err := fs.Clean(ctx); err != nil {
-		return err
+
+	lines, err := fs.countLines()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := fs.writeAll(compacted); err != nil {
+		return 0, err
+	}
+	return lines - len(compacted), nil
+}
+
+// countLines returns the number of lines in the file.
+// The caller must hold the lock.
+func (fs *FileStorage) countLines() (int, error) {
+	if _, err := fs.File.Seek(0, 0); err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(fs.File)
+	lines := 0
+	for scanner.Scan() {
+		lines++
 	}
-	return fs.SaveArray(ctx, buffer)
+	return lines, scanner.Err()
 }
 
 // Clean cleans the file.
@@ -217,10 +324,26 @@ func (fs *FileStorage) Clean(ctx context.Context) error {
 	if err := fs.File.Truncate(0); err != nil {
 		return err
 	}
+	fs.index = nil
 
 	if _, err := fs.File.Seek(0, 0); err != nil {
 		return err
 	}
+
+	for _, path := range []string{fs.clicksPath(), fs.countersPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	fs.consumed = nil
+
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+	for _, path := range []string{fs.webhooksPath(), fs.deliveriesPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -252,8 +375,8 @@ func (fs *FileStorage) IsUserIDExists(ctx context.Context, userID string) (bool,
 	return false, nil
 }
 
-// GetByUser gets all URLs associated with a user ID from the file.
-func (fs *FileStorage) GetByUser(ctx context.Context, userID string) ([]storage.SavedURL, error) {
+// GetByUser gets all URLs associated with a user ID from the file that match the filter.
+func (fs *FileStorage) GetByUser(ctx context.Context, userID string, filter storage.URLFilter) ([]storage.SavedURL, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -268,28 +391,689 @@ func (fs *FileStorage) GetByUser(ctx context.Context, userID string) ([]storage.
 
 	scanner := bufio.NewScanner(fs.File)
 	var urls []storage.SavedURL
-	var savedURL storage.SavedURL
 	for scanner.Scan() {
 		if strings.Contains(scanner.Text(), userID) {
+			var savedURL storage.SavedURL
 			err := json.Unmarshal(scanner.Bytes(), &savedURL)
 			if err != nil {
 				return nil, err
 			}
-			urls = append(urls, savedURL)
+			if savedURL.UserID != userID {
+				continue
+			}
+			if err := fs.applyConsumed(&savedURL); err != nil {
+				return nil, err
+			}
+			if filter.Match(savedURL) {
+				urls = append(urls, savedURL)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// ExportByUser calls fn for every URL of the user that matches the filter.
+// The file is read through its own handle line by line, so writers are not blocked while fn runs.
+// The taken clicks are copied before, so the remaining clicks may be behind a click taken during the export.
+func (fs *FileStorage) ExportByUser(ctx context.Context, userID string, filter storage.URLFilter, fn func(storage.SavedURL) error) error {
+	fs.mu.Lock()
+	err := fs.loadConsumed()
+	consumed := make(map[string]int, len(fs.consumed))
+	for shortURL, clicks := range fs.consumed {
+		consumed[shortURL] = clicks
+	}
+	fs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(fs.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var savedURL storage.SavedURL
+		if err := json.Unmarshal(scanner.Bytes(), &savedURL); err != nil {
+			continue
+		}
+		if savedURL.UserID != userID {
+			continue
+		}
+		subtractClicks(&savedURL, consumed[savedURL.ShortURL])
+		if !filter.Match(savedURL) {
+			continue
+		}
+		if err := fn(savedURL); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ExportAll calls fn for every URL in the file.
+// The file is read at once under the lock, so fn sees a consistent snapshot and runs without holding the lock.
+func (fs *FileStorage) ExportAll(ctx context.Context, fn func(storage.SavedURL) error) error {
+	fs.mu.Lock()
+	urls, err := fs.readAll()
+	fs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTags returns the tags of the user's links with the number of links per tag.
+func (fs *FileStorage) GetTags(ctx context.Context, userID string) ([]storage.TagCount, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.CountTags(urls, userID), nil
+}
+
+// UpdateTags replaces the tags of a link owned by the user and rewrites the file.
+func (fs *FileStorage) UpdateTags(ctx context.Context, userID string, shortURL string, tags []string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, url := range urls {
+		if url.ShortURL == shortURL && url.UserID == userID {
+			urls[i].Tags = tags
+			found = true
+		}
+	}
+	if !found {
+		return storage.ErrURLNotFound
+	}
+
+	return fs.writeAll(urls)
+}
+
+// UpdateVariants replaces the variants of a link owned by the user and rewrites the file.
+func (fs *FileStorage) UpdateVariants(ctx context.Context, userID string, shortURL string, variants []models.Variant) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, url := range urls {
+		if url.ShortURL == shortURL && url.UserID == userID {
+			urls[i].Variants = variants
+			found = true
+		}
+	}
+	if !found {
+		return storage.ErrURLNotFound
+	}
+
+	return fs.writeAll(urls)
+}
+
+// ConsumeClick takes one of the remaining clicks of the link under the lock.
+// The click is appended to the counters file, the storage file is not rewritten.
+func (fs *FileStorage) ConsumeClick(ctx context.Context, shortURL string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	savedURL, err := fs.get(shortURL)
+	if err != nil {
+		return err
+	}
+	if err := storage.ConsumeClick(&savedURL); err != nil {
+		return err
+	}
+	if savedURL.MaxClicks <= 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(fs.countersPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(consumedClick{ShortURL: shortURL}); err != nil {
+		return err
+	}
+	fs.consumed[shortURL]++
+	return nil
+}
+
+// consumedClick is a line of the counters file, a click taken from a limited link.
+type consumedClick struct {
+	ShortURL string `json:"shortURL"`
+}
+
+// countersPath returns the path of the file the clicks taken from limited links are appended to
+// until the storage file is rewritten.
+func (fs *FileStorage) countersPath() string {
+	return fs.FilePath + ".counters"
+}
+
+// loadConsumed reads the counters file if it wasn't read yet. The caller must hold the lock.
+func (fs *FileStorage) loadConsumed() error {
+	if fs.consumed != nil {
+		return nil
+	}
+
+	consumed := make(map[string]int)
+	file, err := os.Open(fs.countersPath())
+	if os.IsNotExist(err) {
+		fs.consumed = consumed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var click consumedClick
+		if err := json.Unmarshal(scanner.Bytes(), &click); err != nil {
+			continue
+		}
+		consumed[click.ShortURL]++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fs.consumed = consumed
+	return nil
+}
+
+// applyConsumed subtracts the clicks taken since the last rewrite from the remaining clicks of the record.
+// The caller must hold the lock.
+func (fs *FileStorage) applyConsumed(savedURL *storage.SavedURL) error {
+	if savedURL.MaxClicks <= 0 {
+		return nil
+	}
+	if err := fs.loadConsumed(); err != nil {
+		return err
+	}
+	subtractClicks(savedURL, fs.consumed[savedURL.ShortURL])
+	return nil
+}
+
+// subtractClicks takes the clicks from the remaining clicks of a limited link.
+func subtractClicks(savedURL *storage.SavedURL, clicks int) {
+	if savedURL.MaxClicks <= 0 || clicks == 0 {
+		return
+	}
+	savedURL.ClicksLeft = max(savedURL.ClicksLeft-clicks, 0)
+}
+
+// UpdateHealth records the results of destination checks and rewrites the file once for all of them.
+func (fs *FileStorage) UpdateHealth(ctx context.Context, checks []storage.HealthCheck) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	urls, err := fs.readAll()
+	if err != nil {
+		return err
+	}
+
+	storage.ApplyHealth(urls, checks)
+	return fs.writeAll(urls)
+}
+
+// SaveClicks appends the clicks to the clicks file next to the storage file.
+func (fs *FileStorage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	file, err := os.OpenFile(fs.clicksPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, click := range clicks {
+		if err := encoder.Encode(click); err != nil {
+			return err
 		}
 	}
+	return writer.Flush()
+}
+
+// GetClickStats counts the clicks of the link that match the filter in the clicks file.
+func (fs *FileStorage) GetClickStats(ctx context.Context, shortURL string, filter storage.ClickFilter) (storage.ClickStats, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	file, err := os.Open(fs.clicksPath())
+	if os.IsNotExist(err) {
+		return storage.CountClicks(nil, shortURL, filter), nil
+	}
+	if err != nil {
+		return storage.ClickStats{}, err
+	}
+	defer file.Close()
 
+	var clicks []storage.Click
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if !strings.Contains(scanner.Text(), shortURL) {
+			continue
+		}
+		var click storage.Click
+		if err := json.Unmarshal(scanner.Bytes(), &click); err == nil {
+			clicks = append(clicks, click)
+		}
+	}
 	if err := scanner.Err(); err != nil {
+		return storage.ClickStats{}, err
+	}
+
+	return storage.CountClicks(clicks, shortURL, filter), nil
+}
+
+// clicksPath returns the path of the file the clicks are stored in.
+func (fs *FileStorage) clicksPath() string {
+	return fs.FilePath + ".clicks"
+}
+
+// webhookState is the content of the webhooks file.
+// Deliveries are kept in the outbox journal, the field only holds the outbox of files written
+// before the journal existed until the journal is compacted.
+type webhookState struct {
+	Webhooks   []storage.Webhook  `json:"webhooks"`
+	Deliveries []storage.Delivery `json:"deliveries,omitempty"`
+}
+
+// Operations of the lines of the outbox journal.
+const (
+	// deliveryEnqueued adds a delivery.
+	deliveryEnqueued = "enqueue"
+	// deliveryUpdated replaces a delivery that is still in the outbox.
+	deliveryUpdated = "update"
+)
+
+// deliveryEntry is a line of the outbox journal.
+type deliveryEntry struct {
+	Op       string           `json:"op"`
+	Delivery storage.Delivery `json:"delivery"`
+}
+
+// SaveWebhook registers the webhook in the webhooks file.
+func (fs *FileStorage) SaveWebhook(ctx context.Context, webhook storage.Webhook) error {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	state, err := fs.readWebhooks()
+	if err != nil {
+		return err
+	}
+	state.Webhooks = append(state.Webhooks, webhook)
+	return fs.writeWebhooks(state)
+}
+
+// GetWebhooks returns the webhooks of the user from the webhooks file.
+func (fs *FileStorage) GetWebhooks(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	state, err := fs.readWebhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	var webhooks []storage.Webhook
+	for _, webhook := range state.Webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook of the user from the webhooks file and compacts the outbox
+// journal without its deliveries.
+func (fs *FileStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	state, deliveries, err := fs.readOutbox()
+	if err != nil {
+		return err
+	}
+	state.Webhooks, deliveries, err = storage.RemoveWebhook(state.Webhooks, deliveries, userID, id)
+	if err != nil {
+		return err
+	}
+	return fs.compactOutbox(state, deliveries)
+}
+
+// EnqueueDeliveries appends the deliveries to the outbox journal.
+func (fs *FileStorage) EnqueueDeliveries(ctx context.Context, deliveries []storage.Delivery) error {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+	return fs.appendDeliveries(deliveryEnqueued, deliveries)
+}
+
+// ClaimDeliveries takes the due deliveries from the outbox and appends their postponed versions to the journal.
+func (fs *FileStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storage.Delivery, error) {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	_, deliveries, err := fs.readOutbox()
+	if err != nil {
+		return nil, err
+	}
+	claimed := storage.ClaimDeliveries(deliveries, now, lease, limit)
+	if err := fs.appendDeliveries(deliveryUpdated, claimed); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// UpdateDelivery appends the outcome of an attempt to the outbox journal.
+func (fs *FileStorage) UpdateDelivery(ctx context.Context, delivery storage.Delivery) error {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+	return fs.appendDeliveries(deliveryUpdated, []storage.Delivery{delivery})
+}
+
+// GetDeliveries returns the latest deliveries of the webhook from the outbox.
+func (fs *FileStorage) GetDeliveries(ctx context.Context, userID string, webhookID string, limit int) ([]storage.Delivery, error) {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	_, deliveries, err := fs.readOutbox()
+	if err != nil {
+		return nil, err
+	}
+	return storage.ListDeliveries(deliveries, userID, webhookID, limit), nil
+}
+
+// PruneDeliveries removes the old finished deliveries and compacts the outbox journal,
+// which drops the old versions of the remaining deliveries too.
+func (fs *FileStorage) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	fs.webhooksMu.Lock()
+	defer fs.webhooksMu.Unlock()
+
+	state, deliveries, err := fs.readOutbox()
+	if err != nil {
+		return 0, err
+	}
+	deliveries, removed := storage.PruneDeliveries(deliveries, before)
+	return removed, fs.compactOutbox(state, deliveries)
+}
+
+// webhooksPath returns the path of the file the webhooks are stored in.
+func (fs *FileStorage) webhooksPath() string {
+	return fs.FilePath + ".webhooks"
+}
+
+// deliveriesPath returns the path of the outbox journal.
+func (fs *FileStorage) deliveriesPath() string {
+	return fs.FilePath + ".deliveries"
+}
+
+// readWebhooks reads the webhooks file, which is empty if it doesn't exist yet.
+// The caller must hold webhooksMu.
+func (fs *FileStorage) readWebhooks() (webhookState, error) {
+	var state webhookState
+	data, err := os.ReadFile(fs.webhooksPath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// writeWebhooks replaces the webhooks file with the state.
+// The file is replaced by a rename, so a crash never leaves it half written.
+// The caller must hold webhooksMu.
+func (fs *FileStorage) writeWebhooks(state webhookState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := fs.webhooksPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.webhooksPath())
+}
+
+// readOutbox reads the webhooks file and replays the outbox journal on top of the deliveries it holds.
+// The caller must hold webhooksMu.
+func (fs *FileStorage) readOutbox() (webhookState, []storage.Delivery, error) {
+	state, err := fs.readWebhooks()
+	if err != nil {
+		return state, nil, err
+	}
+	deliveries := append([]storage.Delivery(nil), state.Deliveries...)
+
+	file, err := os.Open(fs.deliveriesPath())
+	if os.IsNotExist(err) {
+		return state, deliveries, nil
+	}
+	if err != nil {
+		return state, nil, err
+	}
+	defer file.Close()
+
+	positions := make(map[string]int, len(deliveries))
+	for i, delivery := range deliveries {
+		positions[delivery.ID] = i
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry deliveryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line cut off by a crash.
+			continue
+		}
+		position, ok := positions[entry.Delivery.ID]
+		switch {
+		case ok:
+			deliveries[position] = entry.Delivery
+		case entry.Op == deliveryEnqueued:
+			positions[entry.Delivery.ID] = len(deliveries)
+			deliveries = append(deliveries, entry.Delivery)
+		}
+	}
+	return state, deliveries, scanner.Err()
+}
+
+// appendDeliveries appends a line with the operation for every delivery to the outbox journal.
+// The caller must hold webhooksMu.
+func (fs *FileStorage) appendDeliveries(op string, deliveries []storage.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(fs.deliveriesPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, delivery := range deliveries {
+		if err := encoder.Encode(deliveryEntry{Op: op, Delivery: delivery}); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// compactOutbox replaces the outbox journal with a line for every delivery, then writes the
+// webhooks of the state without the deliveries that moved to the journal.
+// Replaying a delivery that is in both is harmless, so a crash in between loses nothing.
+// The caller must hold webhooksMu.
+func (fs *FileStorage) compactOutbox(state webhookState, deliveries []storage.Delivery) error {
+	tmp := fs.deliveriesPath() + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, delivery := range deliveries {
+		if err = encoder.Encode(deliveryEntry{Op: deliveryEnqueued, Delivery: delivery}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fs.deliveriesPath()); err != nil {
+		return err
+	}
+
+	state.Deliveries = nil
+	return fs.writeWebhooks(state)
+}
+
+// readAll reads every record from the file with the clicks taken since the last rewrite.
+// The caller must hold the lock.
+func (fs *FileStorage) readAll() ([]storage.SavedURL, error) {
+	if fs.File == nil {
+		return nil, errors.New("file does not open")
+	}
+
+	if _, err := fs.File.Seek(0, 0); err != nil {
 		return nil, err
 	}
 
-	if len(urls) == 0 {
-		return nil, errors.New("no URLs found for this user")
+	scanner := bufio.NewScanner(fs.File)
+	var urls []storage.SavedURL
+	for scanner.Scan() {
+		var savedURL storage.SavedURL
+		if err := json.Unmarshal(scanner.Bytes(), &savedURL); err != nil {
+			continue
+		}
+		if err := fs.applyConsumed(&savedURL); err != nil {
+			return nil, err
+		}
+		urls = append(urls, savedURL)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
 	return urls, nil
 }
 
+// writeAll replaces the content of the file with the given records, which were read by readAll.
+// The records are written to a temporary file that is renamed over the storage file,
+// so a crash while rewriting never loses the links. The remaining clicks of the records include the
+// counters file, so it is removed after the rename; a crash in between takes those clicks twice,
+// which never lets a link serve more than its limit. The caller must hold the lock.
+func (fs *FileStorage) writeAll(urls []storage.SavedURL) error {
+	info, err := fs.File.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fs.FilePath), filepath.Base(fs.FilePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(info.Mode().Perm())
+	if err == nil {
+		err = writeRecords(tmp, urls)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fs.FilePath)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// The temporary file is the storage file now, it stays open for the next reads and appends.
+	fs.File.Close()
+	fs.File = tmp
+	fs.index = nil
+
+	if err := os.Remove(fs.countersPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fs.consumed = make(map[string]int)
+	return nil
+}
+
+// appendAll writes the given records to the end of the file.
+// The caller must hold the lock.
+func (fs *FileStorage) appendAll(urls []storage.SavedURL) error {
+	if _, err := fs.File.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return writeRecords(fs.File, urls)
+}
+
+// writeRecords writes the records to w, one JSON object per line.
+func writeRecords(w io.Writer, urls []storage.SavedURL) error {
+	writer := bufio.NewWriter(w)
+	for _, url := range urls {
+		data, err := json.Marshal(url)
+		if err != nil {
+			return err
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+
+		if err := writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
 // Close closes the file.
 func (fs *FileStorage) Close() error {
 	if fs.File != nil {
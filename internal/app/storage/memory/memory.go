@@ -5,14 +5,22 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
 )
 
 // MemoryStorage represents a memory storage for URLs.
+// DedupScope defines which URLs conflict with each other on Save.
 type MemoryStorage struct {
-	store []storage.SavedURL
+	DedupScope storage.DedupScope
+	store      []storage.SavedURL
+	clicks     []storage.Click
+	webhooks   []storage.Webhook
+	deliveries []storage.Delivery
+	// index finds conflicts of new URLs, it is built when needed and dropped when URLs are deleted or restored.
+	index *storage.URLIndex
 	mu    sync.Mutex
 }
 
@@ -34,16 +42,47 @@ func (m *MemoryStorage) Ping(ctx context.Context) error {
 }
 
 // Delete deletes a URL from the memory storage.
-func (m *MemoryStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) error {
+func (m *MemoryStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) ([]storage.SavedURL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	var deleted []storage.SavedURL
 	for i, item := range m.store {
 		for _, task := range taskSlice {
-			if item.ShortURL == task.URL && item.UserID == task.UserID {
+			if item.ShortURL == task.URL && item.UserID == task.UserID && !m.store[i].IsDeleted {
 				m.store[i].IsDeleted = true
+				deleted = append(deleted, m.store[i])
 			}
 		}
 	}
-	return nil
+	if len(deleted) > 0 {
+		m.index = nil
+	}
+	return deleted, nil
+}
+
+// Restore marks a deleted URL in the memory storage as not deleted.
+func (m *MemoryStorage) Restore(ctx context.Context, shortURL string) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.store {
+		if item.ShortURL != shortURL {
+			continue
+		}
+		if _, ok := storage.RestoreConflict(m.DedupScope, m.store, i); ok {
+			return storage.ErrURLConflict
+		}
+		m.store[i].IsDeleted = false
+		m.index = nil
+		return nil
+	}
+
+	return storage.ErrURLNotFound
 }
 
 // Save saves a URL to the memory storage.
@@ -56,25 +95,53 @@ func (m *MemoryStorage) Save(ctx context.Context, savedURL storage.SavedURL) (st
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, item := range m.store {
-		if item.OriginalURL == savedURL.OriginalURL {
-			return item.ShortURL, storage.ErrURLConflict
-		}
+	index := m.urlIndex()
+	if shortURL, ok := index.Conflict(savedURL); ok {
+		return shortURL, storage.ErrURLConflict
 	}
 	m.store = append(m.store, savedURL)
+	index.Add(savedURL)
 	return "", nil
 }
 
 // SaveArray saves an array of URLs to the memory storage.
-func (m *MemoryStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL) error {
+// URLs that conflict with saved ones are reported as existing and are not stored.
+func (m *MemoryStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL, atomic bool) ([]storage.SaveResult, error) {
 	if m.store == nil {
-		return errors.New("MemoryStorage not initialized")
+		return nil, errors.New("MemoryStorage not initialized")
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.store = append(m.store, savedUrls...)
 
-	return nil
+	index := m.urlIndex()
+	results, created := index.Resolve(savedUrls)
+	if atomic && storage.HasConflicts(results) {
+		storage.SkipCreated(results)
+		return results, storage.ErrURLConflict
+	}
+	m.store = append(m.store, created...)
+	for _, savedURL := range created {
+		index.Add(savedURL)
+	}
+
+	return results, nil
+}
+
+// urlIndex returns the index of the stored URLs and builds it if there is none.
+// The caller must hold the lock.
+func (m *MemoryStorage) urlIndex() *storage.URLIndex {
+	if m.index == nil {
+		m.index = storage.NewURLIndex(m.DedupScope)
+		for _, savedURL := range m.store {
+			m.index.Add(savedURL)
+		}
+	}
+	return m.index
+}
+
+// Import saves a chunk of imported URLs to the memory storage in one append.
+func (m *MemoryStorage) Import(ctx context.Context, savedUrls []storage.SavedURL) ([]storage.SaveResult, error) {
+	return m.SaveArray(ctx, savedUrls, false)
 }
 
 // Get gets a URL from the memory storage by its short URL.
@@ -92,7 +159,7 @@ func (m *MemoryStorage) Get(ctx context.Context, key string) (storage.SavedURL,
 		}
 	}
 
-	return storage.SavedURL{}, errors.New("URL not found")
+	return storage.SavedURL{}, storage.ErrURLNotFound
 }
 
 // Clean cleans the memory storage.
@@ -100,6 +167,10 @@ func (m *MemoryStorage) Clean(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.store = []storage.SavedURL{}
+	m.index = nil
+	m.clicks = nil
+	m.webhooks = nil
+	m.deliveries = nil
 	return nil
 }
 
@@ -124,8 +195,8 @@ func (m *MemoryStorage) Close() error {
 	return nil
 }
 
-// GetByUser gets all URLs associated with a user ID from the memory storage.
-func (m *MemoryStorage) GetByUser(ctx context.Context, userID string) ([]storage.SavedURL, error) {
+// GetByUser gets all URLs associated with a user ID from the memory storage that match the filter.
+func (m *MemoryStorage) GetByUser(ctx context.Context, userID string, filter storage.URLFilter) ([]storage.SavedURL, error) {
 	if m.store == nil {
 		return nil, errors.New("MemoryStorage not initialized")
 	}
@@ -135,16 +206,224 @@ func (m *MemoryStorage) GetByUser(ctx context.Context, userID string) ([]storage
 
 	var userURLs []storage.SavedURL
 	for _, item := range m.store {
-		if item.UserID == userID {
+		if item.UserID == userID && filter.Match(item) {
 			userURLs = append(userURLs, item)
 		}
 	}
 
-	if len(userURLs) == 0 {
-		return nil, errors.New("no URLs found for this user")
+	return userURLs, nil
+}
+
+// ExportByUser calls fn for every URL of the user that matches the filter.
+// The matching URLs are copied first, so fn runs without holding the lock.
+func (m *MemoryStorage) ExportByUser(ctx context.Context, userID string, filter storage.URLFilter, fn func(storage.SavedURL) error) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
 	}
 
-	return userURLs, nil
+	m.mu.Lock()
+	var userURLs []storage.SavedURL
+	for _, item := range m.store {
+		if item.UserID == userID && filter.Match(item) {
+			userURLs = append(userURLs, item)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, item := range userURLs {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportAll calls fn for every URL in the memory storage.
+// The URLs are copied first, so fn runs without holding the lock.
+func (m *MemoryStorage) ExportAll(ctx context.Context, fn func(storage.SavedURL) error) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	urls := make([]storage.SavedURL, len(m.store))
+	copy(urls, m.store)
+	m.mu.Unlock()
+
+	for _, item := range urls {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTags returns the tags of the user's links with the number of links per tag.
+func (m *MemoryStorage) GetTags(ctx context.Context, userID string) ([]storage.TagCount, error) {
+	if m.store == nil {
+		return nil, errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return storage.CountTags(m.store, userID), nil
+}
+
+// UpdateTags replaces the tags of a link owned by the user.
+func (m *MemoryStorage) UpdateTags(ctx context.Context, userID string, shortURL string, tags []string) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.store {
+		if item.ShortURL == shortURL && item.UserID == userID {
+			m.store[i].Tags = tags
+			return nil
+		}
+	}
+
+	return storage.ErrURLNotFound
+}
+
+// UpdateVariants replaces the variants of a link owned by the user.
+func (m *MemoryStorage) UpdateVariants(ctx context.Context, userID string, shortURL string, variants []models.Variant) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.store {
+		if item.ShortURL == shortURL && item.UserID == userID {
+			m.store[i].Variants = variants
+			return nil
+		}
+	}
+
+	return storage.ErrURLNotFound
+}
+
+// ConsumeClick takes one of the remaining clicks of the link under the lock.
+func (m *MemoryStorage) ConsumeClick(ctx context.Context, shortURL string) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, item := range m.store {
+		if item.ShortURL == shortURL {
+			return storage.ConsumeClick(&m.store[i])
+		}
+	}
+
+	return storage.ErrURLNotFound
+}
+
+// UpdateHealth records the results of destination checks in the memory storage.
+func (m *MemoryStorage) UpdateHealth(ctx context.Context, checks []storage.HealthCheck) error {
+	if m.store == nil {
+		return errors.New("MemoryStorage not initialized")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	storage.ApplyHealth(m.store, checks)
+	return nil
+}
+
+// SaveClicks records the clicks in the memory storage.
+func (m *MemoryStorage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clicks = append(m.clicks, clicks...)
+	return nil
+}
+
+// GetClickStats counts the clicks of the link that match the filter in the memory storage.
+func (m *MemoryStorage) GetClickStats(ctx context.Context, shortURL string, filter storage.ClickFilter) (storage.ClickStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return storage.CountClicks(m.clicks, shortURL, filter), nil
+}
+
+// SaveWebhook registers the webhook in the memory storage.
+func (m *MemoryStorage) SaveWebhook(ctx context.Context, webhook storage.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks = append(m.webhooks, webhook)
+	return nil
+}
+
+// GetWebhooks returns the webhooks of the user from the memory storage.
+func (m *MemoryStorage) GetWebhooks(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var webhooks []storage.Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook of the user and its deliveries from the memory storage.
+func (m *MemoryStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	m.webhooks, m.deliveries, err = storage.RemoveWebhook(m.webhooks, m.deliveries, userID, id)
+	return err
+}
+
+// EnqueueDeliveries adds the deliveries to the outbox in the memory storage.
+func (m *MemoryStorage) EnqueueDeliveries(ctx context.Context, deliveries []storage.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, deliveries...)
+	return nil
+}
+
+// ClaimDeliveries takes the due deliveries from the outbox in the memory storage.
+func (m *MemoryStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storage.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return storage.ClaimDeliveries(m.deliveries, now, lease, limit), nil
+}
+
+// UpdateDelivery records the outcome of an attempt in the memory storage.
+func (m *MemoryStorage) UpdateDelivery(ctx context.Context, delivery storage.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	storage.ReplaceDelivery(m.deliveries, delivery)
+	return nil
+}
+
+// GetDeliveries returns the latest deliveries of the webhook from the memory storage.
+func (m *MemoryStorage) GetDeliveries(ctx context.Context, userID string, webhookID string, limit int) ([]storage.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return storage.ListDeliveries(m.deliveries, userID, webhookID, limit), nil
+}
+
+// PruneDeliveries removes the old finished deliveries from the memory storage.
+func (m *MemoryStorage) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int
+	m.deliveries, removed = storage.PruneDeliveries(m.deliveries, before)
+	return removed, nil
 }
 
 // GetStats returns returns the number of users and urls in the memory storage.
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveDedupUser(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{DedupScope: storage.DedupUser}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("alice1", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	_, err = m.Save(ctx, *storage.NewSavedURL("bob1", "https://example.com/", "bob"))
+	assert.NoError(t, err)
+
+	conflict, err := m.Save(ctx, *storage.NewSavedURL("alice2", "https://example.com/", "alice"))
+	assert.ErrorIs(t, err, storage.ErrURLConflict)
+	assert.Equal(t, "alice1", conflict)
+
+	urls, err := m.GetByUser(ctx, "bob", storage.URLFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+	assert.Equal(t, "bob1", urls[0].ShortURL)
+}
+
+func TestSaveDedupGlobal(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("alice1", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	conflict, err := m.Save(ctx, *storage.NewSavedURL("bob1", "https://example.com/", "bob"))
+	assert.ErrorIs(t, err, storage.ErrURLConflict)
+	assert.Equal(t, "alice1", conflict)
+}
+
+func TestSaveArrayReportsExisting(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("old", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	results, err := m.SaveArray(ctx, []storage.SavedURL{
+		*storage.NewSavedURL("new1", "https://example.com/", "alice"),
+		*storage.NewSavedURL("new2", "https://example.org/", "alice"),
+		*storage.NewSavedURL("new3", "https://example.org/", "alice"),
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SaveResult{
+		{Status: storage.SaveExisting, ShortURL: "old"},
+		{Status: storage.SaveCreated, ShortURL: "new2"},
+		{Status: storage.SaveExisting, ShortURL: "new2"},
+	}, results)
+
+	urls, err := m.GetByUser(ctx, "alice", storage.URLFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+}
+
+func TestSaveArrayAtomic(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("old", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	results, err := m.SaveArray(ctx, []storage.SavedURL{
+		*storage.NewSavedURL("new1", "https://example.org/", "alice"),
+		*storage.NewSavedURL("new2", "https://example.com/", "alice"),
+	}, true)
+	assert.ErrorIs(t, err, storage.ErrURLConflict)
+	assert.Equal(t, []storage.SaveResult{
+		{Status: storage.SaveSkipped, ShortURL: "new1"},
+		{Status: storage.SaveExisting, ShortURL: "old"},
+	}, results)
+
+	urls, err := m.GetByUser(ctx, "alice", storage.URLFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
+
+func TestImportReportsTaken(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("docs", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	results, err := m.Import(ctx, []storage.SavedURL{
+		*storage.NewSavedURL("docs", "https://example.org/", "alice"),
+		*storage.NewSavedURL("blog", "https://example.net/", "alice"),
+		*storage.NewSavedURL("blog", "https://example.io/", "alice"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SaveResult{
+		{Status: storage.SaveTaken, ShortURL: "docs"},
+		{Status: storage.SaveCreated, ShortURL: "blog"},
+		{Status: storage.SaveTaken, ShortURL: "blog"},
+	}, results)
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("abc", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	deleted, err := m.Delete(ctx, []models.DeleteTask{{URL: "abc", UserID: "bob"}})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted, "links of other users are not deleted")
+
+	deleted, err = m.Delete(ctx, []models.DeleteTask{{URL: "abc", UserID: "alice"}})
+	assert.NoError(t, err)
+	if assert.Len(t, deleted, 1) {
+		assert.Equal(t, "abc", deleted[0].ShortURL)
+		assert.True(t, deleted[0].IsDeleted)
+	}
+
+	deleted, err = m.Delete(ctx, []models.DeleteTask{{URL: "abc", UserID: "alice"}})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted, "a deleted link is not deleted again")
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	_, err := m.Save(ctx, *storage.NewSavedURL("old", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+	_, err = m.Delete(ctx, []models.DeleteTask{{URL: "old", UserID: "alice"}})
+	assert.NoError(t, err)
+
+	_, err = m.Save(ctx, *storage.NewSavedURL("new", "https://example.com/", "alice"))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, m.Restore(ctx, "old"), storage.ErrURLConflict)
+	assert.ErrorIs(t, m.Restore(ctx, "missing"), storage.ErrURLNotFound)
+
+	_, err = m.Delete(ctx, []models.DeleteTask{{URL: "new", UserID: "alice"}})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Restore(ctx, "old"))
+
+	savedURL, err := m.Get(ctx, "old")
+	assert.NoError(t, err)
+	assert.False(t, savedURL.IsDeleted)
+}
+
+func TestConsumeClick(t *testing.T) {
+	ctx := context.Background()
+	m := &MemoryStorage{}
+	assert.NoError(t, m.Init(ctx))
+
+	limited := *storage.NewSavedURL("once", "https://example.com/", "alice")
+	limited.MaxClicks = 2
+	limited.ClicksLeft = 2
+	_, err := m.Save(ctx, limited)
+	assert.NoError(t, err)
+	_, err = m.Save(ctx, *storage.NewSavedURL("open", "https://example.org/", "alice"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.ConsumeClick(ctx, "once"))
+	assert.NoError(t, m.ConsumeClick(ctx, "once"))
+	assert.ErrorIs(t, m.ConsumeClick(ctx, "once"), storage.ErrClickLimitReached)
+
+	saved, err := m.Get(ctx, "once")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, saved.ClicksLeft)
+
+	assert.NoError(t, m.ConsumeClick(ctx, "open"), "links without a limit are not counted")
+	assert.ErrorIs(t, m.ConsumeClick(ctx, "missing"), storage.ErrURLNotFound)
+}
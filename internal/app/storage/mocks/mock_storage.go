@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/JustWorking42/shortener-go-yandex/internal/app/models"
 	storage "github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
@@ -36,6 +37,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// ClaimDeliveries mocks base method.
+func (m *MockStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storage.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", ctx, now, lease, limit)
+	ret0, _ := ret[0].([]storage.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockStorageMockRecorder) ClaimDeliveries(ctx, now, lease, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockStorage)(nil).ClaimDeliveries), ctx, now, lease, limit)
+}
+
 // Clean mocks base method.
 func (m *MockStorage) Clean(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -64,20 +80,91 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
-// Delete mocks base method.
-func (m *MockStorage) Delete(ctx context.Context, deleteTaskSlice []models.DeleteTask) error {
+// ConsumeClick mocks base method.
+func (m *MockStorage) ConsumeClick(ctx context.Context, shortURL string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", ctx, deleteTaskSlice)
+	ret := m.ctrl.Call(m, "ConsumeClick", ctx, shortURL)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// ConsumeClick indicates an expected call of ConsumeClick.
+func (mr *MockStorageMockRecorder) ConsumeClick(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeClick", reflect.TypeOf((*MockStorage)(nil).ConsumeClick), ctx, shortURL)
+}
+
+// Delete mocks base method.
+func (m *MockStorage) Delete(ctx context.Context, deleteTaskSlice []models.DeleteTask) ([]storage.SavedURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, deleteTaskSlice)
+	ret0, _ := ret[0].([]storage.SavedURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Delete indicates an expected call of Delete.
 func (mr *MockStorageMockRecorder) Delete(ctx, deleteTaskSlice interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), ctx, deleteTaskSlice)
 }
 
+// DeleteWebhook mocks base method.
+func (m *MockStorage) DeleteWebhook(ctx context.Context, userID, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, userID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockStorageMockRecorder) DeleteWebhook(ctx, userID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockStorage)(nil).DeleteWebhook), ctx, userID, id)
+}
+
+// EnqueueDeliveries mocks base method.
+func (m *MockStorage) EnqueueDeliveries(ctx context.Context, deliveries []storage.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDeliveries", ctx, deliveries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueDeliveries indicates an expected call of EnqueueDeliveries.
+func (mr *MockStorageMockRecorder) EnqueueDeliveries(ctx, deliveries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDeliveries", reflect.TypeOf((*MockStorage)(nil).EnqueueDeliveries), ctx, deliveries)
+}
+
+// ExportAll mocks base method.
+func (m *MockStorage) ExportAll(ctx context.Context, fn func(storage.SavedURL) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportAll", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportAll indicates an expected call of ExportAll.
+func (mr *MockStorageMockRecorder) ExportAll(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAll", reflect.TypeOf((*MockStorage)(nil).ExportAll), ctx, fn)
+}
+
+// ExportByUser mocks base method.
+func (m *MockStorage) ExportByUser(ctx context.Context, userID string, filter storage.URLFilter, fn func(storage.SavedURL) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportByUser", ctx, userID, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportByUser indicates an expected call of ExportByUser.
+func (mr *MockStorageMockRecorder) ExportByUser(ctx, userID, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportByUser", reflect.TypeOf((*MockStorage)(nil).ExportByUser), ctx, userID, filter, fn)
+}
+
 // Get mocks base method.
 func (m *MockStorage) Get(ctx context.Context, key string) (storage.SavedURL, error) {
 	m.ctrl.T.Helper()
@@ -94,18 +181,48 @@ func (mr *MockStorageMockRecorder) Get(ctx, key interface{}) *gomock.Call {
 }
 
 // GetByUser mocks base method.
-func (m *MockStorage) GetByUser(ctx context.Context, userID string) ([]storage.SavedURL, error) {
+func (m *MockStorage) GetByUser(ctx context.Context, userID string, filter storage.URLFilter) ([]storage.SavedURL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByUser", ctx, userID)
+	ret := m.ctrl.Call(m, "GetByUser", ctx, userID, filter)
 	ret0, _ := ret[0].([]storage.SavedURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByUser indicates an expected call of GetByUser.
-func (mr *MockStorageMockRecorder) GetByUser(ctx, userID interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetByUser(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUser", reflect.TypeOf((*MockStorage)(nil).GetByUser), ctx, userID, filter)
+}
+
+// GetClickStats mocks base method.
+func (m *MockStorage) GetClickStats(ctx context.Context, shortURL string, filter storage.ClickFilter) (storage.ClickStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClickStats", ctx, shortURL, filter)
+	ret0, _ := ret[0].(storage.ClickStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClickStats indicates an expected call of GetClickStats.
+func (mr *MockStorageMockRecorder) GetClickStats(ctx, shortURL, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClickStats", reflect.TypeOf((*MockStorage)(nil).GetClickStats), ctx, shortURL, filter)
+}
+
+// GetDeliveries mocks base method.
+func (m *MockStorage) GetDeliveries(ctx context.Context, userID, webhookID string, limit int) ([]storage.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, userID, webhookID, limit)
+	ret0, _ := ret[0].([]storage.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockStorageMockRecorder) GetDeliveries(ctx, userID, webhookID, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUser", reflect.TypeOf((*MockStorage)(nil).GetByUser), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockStorage)(nil).GetDeliveries), ctx, userID, webhookID, limit)
 }
 
 // GetStats mocks base method.
@@ -123,6 +240,51 @@ func (mr *MockStorageMockRecorder) GetStats(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockStorage)(nil).GetStats), ctx)
 }
 
+// GetTags mocks base method.
+func (m *MockStorage) GetTags(ctx context.Context, userID string) ([]storage.TagCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTags", ctx, userID)
+	ret0, _ := ret[0].([]storage.TagCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTags indicates an expected call of GetTags.
+func (mr *MockStorageMockRecorder) GetTags(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTags", reflect.TypeOf((*MockStorage)(nil).GetTags), ctx, userID)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockStorage) GetWebhooks(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", ctx, userID)
+	ret0, _ := ret[0].([]storage.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockStorageMockRecorder) GetWebhooks(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockStorage)(nil).GetWebhooks), ctx, userID)
+}
+
+// Import mocks base method.
+func (m *MockStorage) Import(ctx context.Context, savedUrls []storage.SavedURL) ([]storage.SaveResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, savedUrls)
+	ret0, _ := ret[0].([]storage.SaveResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockStorageMockRecorder) Import(ctx, savedUrls interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockStorage)(nil).Import), ctx, savedUrls)
+}
+
 // Init mocks base method.
 func (m *MockStorage) Init(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -166,6 +328,35 @@ func (mr *MockStorageMockRecorder) Ping(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), ctx)
 }
 
+// PruneDeliveries mocks base method.
+func (m *MockStorage) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneDeliveries", ctx, before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PruneDeliveries indicates an expected call of PruneDeliveries.
+func (mr *MockStorageMockRecorder) PruneDeliveries(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneDeliveries", reflect.TypeOf((*MockStorage)(nil).PruneDeliveries), ctx, before)
+}
+
+// Restore mocks base method.
+func (m *MockStorage) Restore(ctx context.Context, shortURL string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockStorageMockRecorder) Restore(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockStorage)(nil).Restore), ctx, shortURL)
+}
+
 // Save mocks base method.
 func (m *MockStorage) Save(ctx context.Context, savedURL storage.SavedURL) (string, error) {
 	m.ctrl.T.Helper()
@@ -182,15 +373,100 @@ func (mr *MockStorageMockRecorder) Save(ctx, savedURL interface{}) *gomock.Call
 }
 
 // SaveArray mocks base method.
-func (m *MockStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL) error {
+func (m *MockStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL, atomic bool) ([]storage.SaveResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveArray", ctx, savedUrls, atomic)
+	ret0, _ := ret[0].([]storage.SaveResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveArray indicates an expected call of SaveArray.
+func (mr *MockStorageMockRecorder) SaveArray(ctx, savedUrls, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveArray", reflect.TypeOf((*MockStorage)(nil).SaveArray), ctx, savedUrls, atomic)
+}
+
+// SaveClicks mocks base method.
+func (m *MockStorage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveArray", ctx, savedUrls)
+	ret := m.ctrl.Call(m, "SaveClicks", ctx, clicks)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveArray indicates an expected call of SaveArray.
-func (mr *MockStorageMockRecorder) SaveArray(ctx, savedUrls interface{}) *gomock.Call {
+// SaveClicks indicates an expected call of SaveClicks.
+func (mr *MockStorageMockRecorder) SaveClicks(ctx, clicks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveClicks", reflect.TypeOf((*MockStorage)(nil).SaveClicks), ctx, clicks)
+}
+
+// SaveWebhook mocks base method.
+func (m *MockStorage) SaveWebhook(ctx context.Context, webhook storage.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhook", ctx, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhook indicates an expected call of SaveWebhook.
+func (mr *MockStorageMockRecorder) SaveWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhook", reflect.TypeOf((*MockStorage)(nil).SaveWebhook), ctx, webhook)
+}
+
+// UpdateDelivery mocks base method.
+func (m *MockStorage) UpdateDelivery(ctx context.Context, delivery storage.Delivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDelivery indicates an expected call of UpdateDelivery.
+func (mr *MockStorageMockRecorder) UpdateDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDelivery", reflect.TypeOf((*MockStorage)(nil).UpdateDelivery), ctx, delivery)
+}
+
+// UpdateHealth mocks base method.
+func (m *MockStorage) UpdateHealth(ctx context.Context, checks []storage.HealthCheck) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateHealth", ctx, checks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateHealth indicates an expected call of UpdateHealth.
+func (mr *MockStorageMockRecorder) UpdateHealth(ctx, checks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHealth", reflect.TypeOf((*MockStorage)(nil).UpdateHealth), ctx, checks)
+}
+
+// UpdateTags mocks base method.
+func (m *MockStorage) UpdateTags(ctx context.Context, userID, shortURL string, tags []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTags", ctx, userID, shortURL, tags)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTags indicates an expected call of UpdateTags.
+func (mr *MockStorageMockRecorder) UpdateTags(ctx, userID, shortURL, tags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTags", reflect.TypeOf((*MockStorage)(nil).UpdateTags), ctx, userID, shortURL, tags)
+}
+
+// UpdateVariants mocks base method.
+func (m *MockStorage) UpdateVariants(ctx context.Context, userID, shortURL string, variants []models.Variant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVariants", ctx, userID, shortURL, variants)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateVariants indicates an expected call of UpdateVariants.
+func (mr *MockStorageMockRecorder) UpdateVariants(ctx, userID, shortURL, variants interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveArray", reflect.TypeOf((*MockStorage)(nil).SaveArray), ctx, savedUrls)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVariants", reflect.TypeOf((*MockStorage)(nil).UpdateVariants), ctx, userID, shortURL, variants)
 }
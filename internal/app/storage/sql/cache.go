@@ -0,0 +1,201 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/cache"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// cacheChannel is the channel the invalidations are sent on with NOTIFY.
+	cacheChannel = "shortener_cache_invalidations"
+	// maxNotifyPayload keeps the payloads below the 8000 bytes NOTIFY accepts.
+	maxNotifyPayload = 7000
+	// cacheReconnectDelay is the pause before listening again after the connection was lost.
+	cacheReconnectDelay = time.Second
+	// cachePruneInterval is how often expired entries are removed.
+	cachePruneInterval = time.Minute
+)
+
+// CacheBackend is a cache.Backend shared by the instances that use the same database.
+// Entries are kept in an unlogged table, which is not replicated and may be emptied by a crash,
+// and invalidations are sent to the other instances with LISTEN and NOTIFY.
+type CacheBackend struct {
+	storage *PostgresStorage
+}
+
+// CacheBackend creates the table of the shared cache and returns the backend that uses it.
+func (s *PostgresStorage) CacheBackend(ctx context.Context) (*CacheBackend, error) {
+	_, err := s.db.Exec(ctx, `
+		CREATE UNLOGGED TABLE IF NOT EXISTS cacheEntriesTable (
+			key TEXT PRIMARY KEY,
+			entry JSONB NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return &CacheBackend{storage: s}, nil
+}
+
+// Get returns the entry of the key unless it has expired.
+func (b *CacheBackend) Get(ctx context.Context, key string) (cache.Entry, bool, error) {
+	var entry cache.Entry
+	err := b.storage.db.QueryRow(ctx, `SELECT entry FROM cacheEntriesTable WHERE key = $1 AND expires_at > now()`, key).Scan(&entry)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cache.Entry{}, false, nil
+	}
+	if err != nil {
+		return cache.Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Set stores the entry of the key for ttl.
+func (b *CacheBackend) Set(ctx context.Context, key string, entry cache.Entry, ttl time.Duration) error {
+	_, err := b.storage.db.Exec(ctx, `
+		INSERT INTO cacheEntriesTable (key, entry, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET entry = EXCLUDED.entry, expires_at = EXCLUDED.expires_at
+	`, key, entry, time.Now().Add(ttl))
+	return err
+}
+
+// Invalidate removes the keys, or every entry if there are none, and notifies the listeners
+// when the removal is committed.
+func (b *CacheBackend) Invalidate(ctx context.Context, keys []string) error {
+	tx, err := b.storage.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(keys) == 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM cacheEntriesTable`); err != nil {
+			return err
+		}
+	} else if _, err := tx.Exec(ctx, `DELETE FROM cacheEntriesTable WHERE key = ANY($1)`, keys); err != nil {
+		return err
+	}
+
+	for _, payload := range notifyPayloads(keys) {
+		if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, cacheChannel, payload); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// notifyPayloads splits the keys into JSON arrays that fit into a notification.
+// No keys give a single empty array.
+func notifyPayloads(keys []string) []string {
+	var payloads []string
+	var chunk []string
+	size := 0
+	for _, key := range keys {
+		if len(chunk) > 0 && size+len(key)+3 > maxNotifyPayload {
+			payload, _ := json.Marshal(chunk)
+			payloads = append(payloads, string(payload))
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, key)
+		size += len(key) + 3
+	}
+	if len(chunk) > 0 || len(payloads) == 0 {
+		payload, _ := json.Marshal(append([]string{}, chunk...))
+		payloads = append(payloads, string(payload))
+	}
+	return payloads
+}
+
+// Subscribe listens for the invalidations on a connection of its own and calls fn with their keys
+// until ctx is done. When the connection is lost it listens again, and calls fn with no keys,
+// because invalidations may have been missed in between. Expired entries are removed while it runs.
+func (b *CacheBackend) Subscribe(ctx context.Context, fn func(keys []string)) error {
+	conn, err := b.listen(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		prune := time.NewTicker(cachePruneInterval)
+		defer prune.Stop()
+		for {
+			err := b.receive(ctx, conn, prune.C, fn)
+			conn.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			b.storage.logger.Sugar().Warnf("cache invalidations connection lost: %v", err)
+			if conn = b.relisten(ctx); conn == nil {
+				return
+			}
+			fn(nil)
+		}
+	}()
+	return nil
+}
+
+// relisten listens again after a pause until it succeeds. It returns nil when ctx is done.
+func (b *CacheBackend) relisten(ctx context.Context) *pgx.Conn {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(cacheReconnectDelay):
+		}
+		conn, err := b.listen(ctx)
+		if err == nil {
+			return conn
+		}
+		b.storage.logger.Sugar().Warnf("failed to listen for cache invalidations: %v", err)
+	}
+}
+
+// listen opens a connection outside of the pool and subscribes it to the invalidations.
+// A pooled connection would be returned to the pool still listening.
+func (b *CacheBackend) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, b.storage.db.Config().ConnConfig.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, `LISTEN `+cacheChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// receive calls fn for every notification on the connection and removes the expired entries on
+// every tick of prune. It returns when the connection fails or ctx is done.
+func (b *CacheBackend) receive(ctx context.Context, conn *pgx.Conn, prune <-chan time.Time, fn func(keys []string)) error {
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, cachePruneInterval)
+		notification, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		select {
+		case <-prune:
+			if _, err := b.storage.db.Exec(ctx, `DELETE FROM cacheEntriesTable WHERE expires_at <= now()`); err != nil && ctx.Err() == nil {
+				b.storage.logger.Sugar().Warnf("failed to prune the shared cache: %v", err)
+			}
+		default:
+		}
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) && !conn.IsClosed() {
+				continue
+			}
+			return err
+		}
+
+		var keys []string
+		if err := json.Unmarshal([]byte(notification.Payload), &keys); err != nil {
+			b.storage.logger.Sugar().Warnf("invalid cache invalidation: %v", err)
+			continue
+		}
+		fn(keys)
+	}
+}
@@ -3,28 +3,35 @@ package sql
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
 // PostgresStorage represents a PostgreSQL storage for URLs.
 type PostgresStorage struct {
-	db     *pgxpool.Pool
-	logger *zap.Logger
+	db         *pgxpool.Pool
+	logger     *zap.Logger
+	dedupScope storage.DedupScope
 }
 
 // NewPostgresStorage creates a new PostgreSQL storage.
-func NewPostgresStorage(ctx context.Context, connString string, logger *zap.Logger) (*PostgresStorage, error) {
+// dedupScope defines which URLs conflict with each other on Save.
+func NewPostgresStorage(ctx context.Context, connString string, logger *zap.Logger, dedupScope storage.DedupScope) (*PostgresStorage, error) {
 	db, err := pgxpool.New(ctx, connString)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PostgresStorage{db: db, logger: logger}, nil
+	return &PostgresStorage{db: db, logger: logger, dedupScope: dedupScope}, nil
 }
 
 // Init initializes the PostgreSQL storage.
@@ -32,7 +39,7 @@ func (s *PostgresStorage) Init(ctx context.Context) error {
 	_, err := s.db.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS urlsTable (
 			short_url TEXT PRIMARY KEY,
-			original_url TEXT NOT NULL UNIQUE,
+			original_url TEXT NOT NULL,
 			user_id VARCHAR(32) NOT NULL,
 			is_deleted bool DEFAULT false
 		)
@@ -57,71 +64,271 @@ func (s *PostgresStorage) Ping(ctx context.Context) error {
 // Save saves a URL to the PostgreSQL storage.
 // It returns the short URL and an error if there was a conflict.
 func (s *PostgresStorage) Save(ctx context.Context, savedURL storage.SavedURL) (string, error) {
-	sqlRequest := `INSERT INTO urlsTable (short_url, original_url, user_id) VALUES ($1, $2, $3) ON CONFLICT (original_url) DO UPDATE SET original_url = EXCLUDED.original_url RETURNING short_url`
-	row := s.db.QueryRow(ctx, sqlRequest, savedURL.ShortURL, savedURL.OriginalURL, savedURL.UserID)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, s.saveRequest(), savedURL.ShortURL, savedURL.OriginalURL, savedURL.UserID, savedURL.RedirectStatus, savedURL.PassQuery, savedURL.PassPath, savedURL.UTM, savedURL.Targets, savedURL.Variants, savedURL.MaxClicks, savedURL.ClicksLeft, savedURL.NotBefore, savedURL.NotAfter, savedURL.PlaceholderURL, savedURL.Interstitial, savedURL.CreatedAt, savedURL.OpenGraph, savedURL.Health)
 	var shortURL string
-	err := row.Scan(&shortURL)
+	err = row.Scan(&shortURL)
 	if err != nil {
 		return "", err
 	}
 	if savedURL.ShortURL != shortURL {
 		return shortURL, storage.ErrURLConflict
 	}
+	if err := insertTags(ctx, tx, shortURL, savedURL.Tags); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
 	s.logger.Sugar().Infof("%v is succesfully save", shortURL)
 	return "", nil
 }
 
-// SaveArray saves an array of URLs to the PostgreSQL storage.
-func (s *PostgresStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL) error {
-	sqlRequest := `INSERT INTO urlsTable (short_url, original_url, user_id)
-	VALUES ($1, $2, $3)
-	ON CONFLICT (short_url) DO NOTHING`
+// saveRequest returns the insert statement for Save that resolves conflicts according to the dedup scope.
+// On conflict the statement returns the short URL of the existing row.
+func (s *PostgresStorage) saveRequest() string {
+	insert := `INSERT INTO urlsTable (short_url, original_url, user_id, redirect_status, pass_query, pass_path, utm, targets, variants, max_clicks, clicks_left, not_before, not_after, placeholder_url, interstitial, created_at, open_graph, health) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+	switch s.dedupScope {
+	case storage.DedupNone:
+		return insert + ` RETURNING short_url`
+	case storage.DedupUser:
+		return insert + ` ON CONFLICT (domain, user_id, original_url) WHERE is_deleted = false DO UPDATE SET original_url = EXCLUDED.original_url RETURNING short_url`
+	default:
+		return insert + ` ON CONFLICT (domain, original_url) WHERE is_deleted = false DO UPDATE SET original_url = EXCLUDED.original_url RETURNING short_url`
+	}
+}
+
+// SaveArray saves an array of URLs to the PostgreSQL storage in one transaction.
+// Every URL is inserted with the same conflict handling as Save, so conflicting URLs,
+// including duplicates within the batch, are reported as existing.
+func (s *PostgresStorage) SaveArray(ctx context.Context, savedUrls []storage.SavedURL, atomic bool) ([]storage.SaveResult, error) {
 	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
+	b := &pgx.Batch{}
+	for _, url := range savedUrls {
+		b.Queue(s.saveRequest(), url.ShortURL, url.OriginalURL, url.UserID, url.RedirectStatus, url.PassQuery, url.PassPath, url.UTM, url.Targets, url.Variants, url.MaxClicks, url.ClicksLeft, url.NotBefore, url.NotAfter, url.PlaceholderURL, url.Interstitial, url.CreatedAt, url.OpenGraph, url.Health)
+	}
+
+	br := tx.SendBatch(ctx, b)
+	results := make([]storage.SaveResult, len(savedUrls))
+	for i, url := range savedUrls {
+		var shortURL string
+		if err := br.QueryRow().Scan(&shortURL); err != nil {
+			br.Close()
+			return nil, err
+		}
+		results[i] = storage.SaveResult{Status: storage.SaveCreated, ShortURL: shortURL}
+		if shortURL != url.ShortURL {
+			results[i].Status = storage.SaveExisting
+		}
+	}
+	if err := br.Close(); err != nil {
+		return nil, err
+	}
+
+	if atomic && storage.HasConflicts(results) {
+		storage.SkipCreated(results)
+		return results, storage.ErrURLConflict
+	}
+
+	for i, url := range savedUrls {
+		if results[i].Status != storage.SaveCreated {
+			continue
+		}
+		if err := insertTags(ctx, tx, url.ShortURL, url.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	s.logger.Sugar().Infof("%v is succesfully save", savedUrls)
+	return results, nil
+}
+
+// Import saves a chunk of imported URLs to the PostgreSQL storage.
+// The chunk is copied into a temporary table with COPY and moved to urlsTable with a single insert
+// that skips conflicting rows. Skipped rows are then resolved to existing links or taken short URLs.
+func (s *PostgresStorage) Import(ctx context.Context, savedUrls []storage.SavedURL) ([]storage.SaveResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE urlsImport (
+			idx INT NOT NULL,
+			short_url TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			user_id VARCHAR(32) NOT NULL,
+			is_deleted BOOL NOT NULL,
+			redirect_status INT NOT NULL,
+			pass_query BOOL NOT NULL,
+			pass_path BOOL NOT NULL,
+			utm JSONB,
+			targets JSONB,
+			variants JSONB,
+			max_clicks INT NOT NULL,
+			clicks_left INT NOT NULL,
+			not_before TIMESTAMPTZ,
+			not_after TIMESTAMPTZ,
+			placeholder_url TEXT NOT NULL,
+			interstitial BOOL NOT NULL,
+			created_at TIMESTAMPTZ,
+			open_graph JSONB,
+			health JSONB
+		) ON COMMIT DROP
+	`)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = tx.Prepare(ctx, "saveArray", sqlRequest)
 
+	rows := make([][]any, len(savedUrls))
+	for i, url := range savedUrls {
+		rows[i] = []any{i, url.ShortURL, url.OriginalURL, url.UserID, url.IsDeleted, url.RedirectStatus, url.PassQuery, url.PassPath, url.UTM, url.Targets, url.Variants, url.MaxClicks, url.ClicksLeft, url.NotBefore, url.NotAfter, url.PlaceholderURL, url.Interstitial, url.CreatedAt, url.OpenGraph, url.Health}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"urlsimport"}, []string{"idx", "short_url", "original_url", "user_id", "is_deleted", "redirect_status", "pass_query", "pass_path", "utm", "targets", "variants", "max_clicks", "clicks_left", "not_before", "not_after", "placeholder_url", "interstitial", "created_at", "open_graph", "health"}, pgx.CopyFromRows(rows))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, url := range savedUrls {
-		_, err := tx.Exec(ctx, "saveArray", url.ShortURL, url.OriginalURL, url.UserID)
+
+	inserted, err := tx.Query(ctx, `
+		INSERT INTO urlsTable (short_url, original_url, user_id, is_deleted, redirect_status, pass_query, pass_path, utm, targets, variants, max_clicks, clicks_left, not_before, not_after, placeholder_url, interstitial, created_at, open_graph, health)
+		SELECT short_url, original_url, user_id, is_deleted, redirect_status, pass_query, pass_path, utm, targets, variants, max_clicks, clicks_left, not_before, not_after, placeholder_url, interstitial, created_at, open_graph, health FROM urlsImport ORDER BY idx
+		ON CONFLICT DO NOTHING
+		RETURNING short_url
+	`)
+	if err != nil {
+		return nil, err
+	}
+	created := make(map[string]bool, len(savedUrls))
+	for inserted.Next() {
+		var shortURL string
+		if err := inserted.Scan(&shortURL); err != nil {
+			inserted.Close()
+			return nil, err
+		}
+		created[shortURL] = true
+	}
+	inserted.Close()
+	if err := inserted.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]storage.SaveResult, len(savedUrls))
+	var tagRows [][]any
+	for i, url := range savedUrls {
+		if created[url.ShortURL] {
+			// Only the first row with a short URL can be the inserted one.
+			delete(created, url.ShortURL)
+			results[i] = storage.SaveResult{Status: storage.SaveCreated, ShortURL: url.ShortURL}
+			for _, tag := range url.Tags {
+				tagRows = append(tagRows, []any{url.ShortURL, tag})
+			}
+			continue
+		}
+
+		existing := ""
+		if !url.IsDeleted {
+			existing, err = s.findExisting(ctx, tx, url)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if existing != "" {
+			results[i] = storage.SaveResult{Status: storage.SaveExisting, ShortURL: existing}
+		} else {
+			results[i] = storage.SaveResult{Status: storage.SaveTaken, ShortURL: url.ShortURL}
+		}
+	}
+
+	if len(tagRows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"urltagstable"}, []string{"short_url", "tag"}, pgx.CopyFromRows(tagRows))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+
 	if err := tx.Commit(ctx); err != nil {
-		return err
+		return nil, err
 	}
+	return results, nil
+}
 
-	s.logger.Sugar().Infof("%v is succesfully save", savedUrls)
-	return nil
+// findExisting returns the short URL of the saved link that conflicts with savedURL according to the dedup scope.
+// It returns an empty string if there is no such link.
+func (s *PostgresStorage) findExisting(ctx context.Context, tx pgx.Tx, savedURL storage.SavedURL) (string, error) {
+	var row pgx.Row
+	switch s.dedupScope {
+	case storage.DedupNone:
+		return "", nil
+	case storage.DedupUser:
+		row = tx.QueryRow(ctx, `SELECT short_url FROM urlsTable WHERE original_url = $1 AND user_id = $2 AND domain = $3 AND is_deleted = false`, savedURL.OriginalURL, savedURL.UserID, storage.KeyDomain(savedURL.ShortURL))
+	default:
+		row = tx.QueryRow(ctx, `SELECT short_url FROM urlsTable WHERE original_url = $1 AND domain = $2 AND is_deleted = false`, savedURL.OriginalURL, storage.KeyDomain(savedURL.ShortURL))
+	}
+
+	var shortURL string
+	err := row.Scan(&shortURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return shortURL, err
 }
 
 // Get gets a URL from the PostgreSQL storage by its short URL.
 func (s *PostgresStorage) Get(ctx context.Context, key string) (storage.SavedURL, error) {
-	sqlRequest := `SELECT original_url, is_deleted
+	sqlRequest := `SELECT original_url, user_id, is_deleted, redirect_status, pass_query, pass_path, utm, targets, variants, max_clicks, clicks_left, not_before, not_after, placeholder_url, interstitial, created_at, open_graph, health
 	FROM urlsTable
 	WHERE short_url = $1
 `
 	row := s.db.QueryRow(ctx, sqlRequest, key)
-	var originalURL string
-	var isDeleted bool
-	err := row.Scan(&originalURL, &isDeleted)
+	savedURL := storage.SavedURL{ShortURL: key}
+	err := row.Scan(&savedURL.OriginalURL, &savedURL.UserID, &savedURL.IsDeleted, &savedURL.RedirectStatus, &savedURL.PassQuery, &savedURL.PassPath, &savedURL.UTM, &savedURL.Targets, &savedURL.Variants, &savedURL.MaxClicks, &savedURL.ClicksLeft, &savedURL.NotBefore, &savedURL.NotAfter, &savedURL.PlaceholderURL, &savedURL.Interstitial, &savedURL.CreatedAt, &savedURL.OpenGraph, &savedURL.Health)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.SavedURL{}, storage.ErrURLNotFound
+	}
 	if err != nil {
 		s.logger.Sugar().Errorf("postgress get error: %v", err)
 		return storage.SavedURL{}, err
 	}
 
-	return storage.SavedURL{ShortURL: key, OriginalURL: originalURL, IsDeleted: isDeleted}, nil
+	return savedURL, nil
+}
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// Restore marks a deleted URL in the PostgreSQL storage as not deleted.
+func (s *PostgresStorage) Restore(ctx context.Context, shortURL string) error {
+	tag, err := s.db.Exec(ctx, `UPDATE urlsTable SET is_deleted = false WHERE short_url = $1`, shortURL)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return storage.ErrURLConflict
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrURLNotFound
+	}
+	return nil
 }
 
 // Clean cleans the PostgreSQL storage.
 func (s *PostgresStorage) Clean(ctx context.Context) error {
-	sqlRequest := `TRUNCATE TABLE urlsTable`
+	sqlRequest := `TRUNCATE TABLE urlsTable, urlTagsTable, clicksTable, webhooksTable, webhookDeliveriesTable`
 	_, err := s.db.Exec(ctx, sqlRequest)
 	return err
 }
@@ -147,60 +354,463 @@ func (s *PostgresStorage) IsUserIDExists(ctx context.Context, userID string) (bo
 	return exists, nil
 }
 
-// GetByUser gets all URLs associated with a user ID from the PostgreSQL storage.
-func (s *PostgresStorage) GetByUser(ctx context.Context, userID string) ([]storage.SavedURL, error) {
-	rows, err := s.db.Query(ctx, "SELECT * FROM urlsTable WHERE user_id=$1", userID)
+// GetByUser gets all URLs associated with a user ID from the PostgreSQL storage that match the filter.
+func (s *PostgresStorage) GetByUser(ctx context.Context, userID string, filter storage.URLFilter) ([]storage.SavedURL, error) {
+	var savedURLs []storage.SavedURL
+	err := s.ExportByUser(ctx, userID, filter, func(savedURL storage.SavedURL) error {
+		savedURLs = append(savedURLs, savedURL)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return savedURLs, nil
+}
+
+// ExportByUser calls fn for every URL of the user that matches the filter while the rows are read from the database.
+func (s *PostgresStorage) ExportByUser(ctx context.Context, userID string, filter storage.URLFilter, fn func(storage.SavedURL) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT u.short_url, u.original_url, u.user_id, u.is_deleted, u.redirect_status, u.pass_query, u.pass_path, u.utm, u.targets, u.variants, u.max_clicks, u.clicks_left, u.not_before, u.not_after, u.placeholder_url, u.interstitial, u.created_at, u.open_graph, u.health,
+		       COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.tag IS NOT NULL), '{}')
+		FROM urlsTable u
+		LEFT JOIN urlTagsTable t ON t.short_url = u.short_url
+		WHERE u.user_id = $1
+		  AND ($2 = '' OR EXISTS (SELECT 1 FROM urlTagsTable f WHERE f.short_url = u.short_url AND f.tag = $2))
+		  AND ($3 = '' OR u.utm->>'campaign' = $3)
+		  AND (NOT $4 OR COALESCE((u.health->>'broken')::bool, false))
+		GROUP BY u.short_url
+	`, userID, filter.Tag, filter.Campaign, filter.Broken)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var savedURL storage.SavedURL
+		err = rows.Scan(&savedURL.ShortURL, &savedURL.OriginalURL, &savedURL.UserID, &savedURL.IsDeleted, &savedURL.RedirectStatus, &savedURL.PassQuery, &savedURL.PassPath, &savedURL.UTM, &savedURL.Targets, &savedURL.Variants, &savedURL.MaxClicks, &savedURL.ClicksLeft, &savedURL.NotBefore, &savedURL.NotAfter, &savedURL.PlaceholderURL, &savedURL.Interstitial, &savedURL.CreatedAt, &savedURL.OpenGraph, &savedURL.Health, &savedURL.Tags)
+		if err != nil {
+			return err
+		}
+		if err := fn(savedURL); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportAll calls fn for every URL while the rows are read from the database.
+// A single query is used, so the rows come from one snapshot of the table.
+func (s *PostgresStorage) ExportAll(ctx context.Context, fn func(storage.SavedURL) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT u.short_url, u.original_url, u.user_id, u.is_deleted, u.redirect_status, u.pass_query, u.pass_path, u.utm, u.targets, u.variants, u.max_clicks, u.clicks_left, u.not_before, u.not_after, u.placeholder_url, u.interstitial, u.created_at, u.open_graph, u.health,
+		       COALESCE(array_agg(t.tag ORDER BY t.tag) FILTER (WHERE t.tag IS NOT NULL), '{}')
+		FROM urlsTable u
+		LEFT JOIN urlTagsTable t ON t.short_url = u.short_url
+		GROUP BY u.short_url
+		ORDER BY u.short_url
+	`)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	var savedURLs []storage.SavedURL
 	for rows.Next() {
 		var savedURL storage.SavedURL
-		err = rows.Scan(&savedURL.ShortURL, &savedURL.OriginalURL, &savedURL.UserID, &savedURL.IsDeleted)
+		err = rows.Scan(&savedURL.ShortURL, &savedURL.OriginalURL, &savedURL.UserID, &savedURL.IsDeleted, &savedURL.RedirectStatus, &savedURL.PassQuery, &savedURL.PassPath, &savedURL.UTM, &savedURL.Targets, &savedURL.Variants, &savedURL.MaxClicks, &savedURL.ClicksLeft, &savedURL.NotBefore, &savedURL.NotAfter, &savedURL.PlaceholderURL, &savedURL.Interstitial, &savedURL.CreatedAt, &savedURL.OpenGraph, &savedURL.Health, &savedURL.Tags)
 		if err != nil {
+			return err
+		}
+		if err := fn(savedURL); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetTags returns the tags of the user's links with the number of links per tag.
+func (s *PostgresStorage) GetTags(ctx context.Context, userID string) ([]storage.TagCount, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT t.tag, COUNT(*)
+		FROM urlTagsTable t
+		JOIN urlsTable u ON u.short_url = t.short_url
+		WHERE u.user_id = $1 AND u.is_deleted = false
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagCounts := []storage.TagCount{}
+	for rows.Next() {
+		var tagCount storage.TagCount
+		if err := rows.Scan(&tagCount.Tag, &tagCount.Count); err != nil {
 			return nil, err
 		}
-		savedURLs = append(savedURLs, savedURL)
+		tagCounts = append(tagCounts, tagCount)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return savedURLs, nil
+	return tagCounts, nil
+}
+
+// UpdateTags replaces the tags of a link owned by the user.
+func (s *PostgresStorage) UpdateTags(ctx context.Context, userID string, shortURL string, tags []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM urlsTable WHERE short_url = $1 AND user_id = $2)`, shortURL, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrURLNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM urlTagsTable WHERE short_url = $1`, shortURL); err != nil {
+		return err
+	}
+	if err := insertTags(ctx, tx, shortURL, tags); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UpdateVariants replaces the variants of a link owned by the user.
+func (s *PostgresStorage) UpdateVariants(ctx context.Context, userID string, shortURL string, variants []models.Variant) error {
+	tag, err := s.db.Exec(ctx, `UPDATE urlsTable SET variants = $3 WHERE short_url = $1 AND user_id = $2`, shortURL, userID, variants)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrURLNotFound
+	}
+	return nil
+}
+
+// UpdateHealth records the results of destination checks in one batch.
+func (s *PostgresStorage) UpdateHealth(ctx context.Context, checks []storage.HealthCheck) error {
+	b := &pgx.Batch{}
+	for _, check := range checks {
+		b.Queue(`UPDATE urlsTable SET health = $2 WHERE short_url = $1`, check.ShortURL, check.Health)
+	}
+	return s.db.SendBatch(ctx, b).Close()
+}
+
+// ConsumeClick takes one of the remaining clicks of the link with a conditional update,
+// so concurrent redirects never serve more clicks than the limit.
+func (s *PostgresStorage) ConsumeClick(ctx context.Context, shortURL string) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE urlsTable SET clicks_left = clicks_left - 1
+		WHERE short_url = $1 AND max_clicks > 0 AND clicks_left > 0
+	`, shortURL)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var maxClicks int
+	err = s.db.QueryRow(ctx, `SELECT max_clicks FROM urlsTable WHERE short_url = $1`, shortURL).Scan(&maxClicks)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrURLNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if maxClicks <= 0 {
+		return nil
+	}
+	return storage.ErrClickLimitReached
+}
+
+// SaveClicks copies the clicks to the clicksTable.
+func (s *PostgresStorage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	rows := make([][]any, len(clicks))
+	for i, click := range clicks {
+		rows[i] = []any{click.ShortURL, click.Variant, click.Time, click.Visitor, click.Referrer, click.Browser, click.OS, click.Country}
+	}
+	_, err := s.db.CopyFrom(ctx, pgx.Identifier{"clickstable"},
+		[]string{"short_url", "variant", "clicked_at", "visitor", "referrer", "browser", "os", "country"}, pgx.CopyFromRows(rows))
+	return err
+}
+
+// clicksWhere selects the clicks of the link given by $1 within the time range given by $2 and $3.
+const clicksWhere = `WHERE short_url = $1 AND ($2::timestamptz IS NULL OR clicked_at >= $2) AND ($3::timestamptz IS NULL OR clicked_at < $3)`
+
+// GetClickStats counts the clicks of the link that match the filter, with a query per breakdown.
+func (s *PostgresStorage) GetClickStats(ctx context.Context, shortURL string, filter storage.ClickFilter) (storage.ClickStats, error) {
+	args := []any{shortURL, nullTime(filter.From), nullTime(filter.To)}
+	stats := storage.NewClickStats()
+
+	err := s.db.QueryRow(ctx, `SELECT COUNT(*), COUNT(DISTINCT NULLIF(visitor, '')) FROM clicksTable `+clicksWhere, args...).
+		Scan(&stats.Clicks, &stats.Visitors)
+	if err != nil {
+		return storage.ClickStats{}, err
+	}
+
+	breakdowns := []struct {
+		column string
+		counts map[string]int
+	}{
+		{"variant", stats.Variants},
+		{"referrer", stats.Referrers},
+		{"browser", stats.Browsers},
+		{"os", stats.OS},
+		{"country", stats.Countries},
+	}
+	for _, breakdown := range breakdowns {
+		sqlRequest := fmt.Sprintf(`SELECT %[1]s, COUNT(*) FROM clicksTable %[2]s AND %[1]s <> '' GROUP BY %[1]s`, breakdown.column, clicksWhere)
+		if err := s.countClicks(ctx, sqlRequest, args, breakdown.counts); err != nil {
+			return storage.ClickStats{}, err
+		}
+	}
+
+	if filter.Bucket == storage.BucketNone {
+		return stats, nil
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT date_trunc($4, clicked_at AT TIME ZONE 'UTC') AS bucket, COUNT(*), COUNT(DISTINCT NULLIF(visitor, ''))
+		FROM clicksTable `+clicksWhere+`
+		GROUP BY bucket ORDER BY bucket`, append(args, string(filter.Bucket))...)
+	if err != nil {
+		return storage.ClickStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket storage.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks, &bucket.Visitors); err != nil {
+			return storage.ClickStats{}, err
+		}
+		bucket.Start = bucket.Start.UTC()
+		stats.Buckets = append(stats.Buckets, bucket)
+	}
+	return stats, rows.Err()
+}
+
+// nullTime returns nil for the zero time, so it is passed to a query as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// countClicks reads the number of clicks per value returned by the query into counts.
+func (s *PostgresStorage) countClicks(ctx context.Context, sqlRequest string, args []any, counts map[string]int) error {
+	rows, err := s.db.Query(ctx, sqlRequest, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value string
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return err
+		}
+		counts[value] = count
+	}
+	return rows.Err()
+}
+
+// SaveWebhook inserts the webhook into the webhooksTable.
+func (s *PostgresStorage) SaveWebhook(ctx context.Context, webhook storage.Webhook) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO webhooksTable (id, user_id, url, secret, events, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, webhook.Events, webhook.CreatedAt)
+	return err
+}
+
+// GetWebhooks returns the webhooks of the user in the order they were registered.
+func (s *PostgresStorage) GetWebhooks(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, user_id, url, secret, events, created_at FROM webhooksTable WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []storage.Webhook
+	for rows.Next() {
+		var webhook storage.Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &webhook.Events, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook deletes the webhook of the user, its deliveries are deleted by the foreign key.
+func (s *PostgresStorage) DeleteWebhook(ctx context.Context, userID string, id string) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM webhooksTable WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// deliveryColumns are the columns of the webhookDeliveriesTable in the order scanDelivery reads them.
+const deliveryColumns = `id, webhook_id, user_id, event, payload, status, attempts, next_attempt_at, response_status, error, created_at, delivered_at`
+
+// EnqueueDeliveries inserts the deliveries into the webhookDeliveriesTable in one batch.
+func (s *PostgresStorage) EnqueueDeliveries(ctx context.Context, deliveries []storage.Delivery) error {
+	b := &pgx.Batch{}
+	for _, delivery := range deliveries {
+		b.Queue(`INSERT INTO webhookDeliveriesTable (`+deliveryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			delivery.ID, delivery.WebhookID, delivery.UserID, delivery.Event, string(delivery.Payload), delivery.Status, delivery.Attempts,
+			delivery.NextAttempt, delivery.ResponseStatus, delivery.Error, delivery.CreatedAt, delivery.DeliveredAt)
+	}
+	return s.db.SendBatch(ctx, b).Close()
+}
+
+// ClaimDeliveries postpones the due deliveries with a single update.
+// Rows claimed by another instance at the same time are skipped instead of waited for.
+func (s *PostgresStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storage.Delivery, error) {
+	rows, err := s.db.Query(ctx, `
+		UPDATE webhookDeliveriesTable SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhookDeliveriesTable
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt in the webhookDeliveriesTable.
+func (s *PostgresStorage) UpdateDelivery(ctx context.Context, delivery storage.Delivery) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE webhookDeliveriesTable
+		SET status = $2, attempts = $3, next_attempt_at = $4, response_status = $5, error = $6, delivered_at = $7
+		WHERE id = $1
+	`, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.ResponseStatus, delivery.Error, delivery.DeliveredAt)
+	return err
+}
+
+// GetDeliveries returns the latest deliveries of the webhook of the user.
+func (s *PostgresStorage) GetDeliveries(ctx context.Context, userID string, webhookID string, limit int) ([]storage.Delivery, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+deliveryColumns+` FROM webhookDeliveriesTable
+		WHERE webhook_id = $1 AND user_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, webhookID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDeliveries(rows)
+}
+
+// PruneDeliveries deletes the finished deliveries created before the time.
+func (s *PostgresStorage) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM webhookDeliveriesTable WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanDeliveries reads the rows selected with deliveryColumns.
+func scanDeliveries(rows pgx.Rows) ([]storage.Delivery, error) {
+	var deliveries []storage.Delivery
+	for rows.Next() {
+		var delivery storage.Delivery
+		var payload string
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.UserID, &delivery.Event, &payload, &delivery.Status, &delivery.Attempts,
+			&delivery.NextAttempt, &delivery.ResponseStatus, &delivery.Error, &delivery.CreatedAt, &delivery.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// insertTags attaches tags to a link inside the transaction.
+func insertTags(ctx context.Context, tx pgx.Tx, shortURL string, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.Exec(ctx, `INSERT INTO urlTagsTable (short_url, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING`, shortURL, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Delete deletes a URL from the PostgreSQL storage.
-func (s *PostgresStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) error {
+// Only rows that are not deleted yet are updated, and the updated rows are returned.
+func (s *PostgresStorage) Delete(ctx context.Context, taskSlice []models.DeleteTask) ([]storage.SavedURL, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		s.logger.Sugar().Errorf("error while starting transaction %v", err)
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
 	b := &pgx.Batch{}
 	for _, task := range taskSlice {
-		b.Queue(`UPDATE urlsTable SET is_deleted = true WHERE short_url = $1 AND user_id = $2`, task.URL, task.UserID)
+		b.Queue(`
+			UPDATE urlsTable SET is_deleted = true
+			WHERE short_url = $1 AND user_id = $2 AND NOT is_deleted
+			RETURNING short_url, original_url, user_id, is_deleted,
+			          ARRAY(SELECT t.tag FROM urlTagsTable t WHERE t.short_url = urlsTable.short_url ORDER BY t.tag)
+		`, task.URL, task.UserID)
 	}
 
 	br := tx.SendBatch(ctx, b)
 
+	var deleted []storage.SavedURL
 	for i := 0; i < len(taskSlice); i++ {
-		_, err := br.Exec()
+		var savedURL storage.SavedURL
+		err := br.QueryRow().Scan(&savedURL.ShortURL, &savedURL.OriginalURL, &savedURL.UserID, &savedURL.IsDeleted, &savedURL.Tags)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
 		if err != nil {
 			s.logger.Sugar().Errorf("error while deliting %v", err)
-			return err
+			br.Close()
+			return nil, err
 		}
+		deleted = append(deleted, savedURL)
 	}
 	br.Close()
 	err = tx.Commit(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return deleted, nil
 }
 
 // migration performs the first migration on the urlsTable in the PostgreSQL database.
@@ -218,21 +828,269 @@ func (s *PostgresStorage) migration(ctx context.Context) error {
 }
 
 // secondMigration performs the second migration on the urlsTable in the PostgreSQL database.
-// It adds an is_deleted column to the table if it doesn't exist.
+// It adds an is_deleted column to the table if it doesn't exist, and then calls the tagsMigration method.
 func (s *PostgresStorage) secondMigration(ctx context.Context) error {
 	_, err := s.db.Exec(ctx, `
 	ALTER TABLE urlsTable
 	ADD COLUMN IF NOT EXISTS is_deleted bool DEFAULT false
+`)
+	if err != nil {
+		return err
+	}
+	return s.tagsMigration(ctx)
+}
+
+// dedupMigration replaces the global UNIQUE constraint on original_url with
+// partial unique indexes that match the configured dedup scope.
+// Deleted rows are excluded, so a deleted link can be created again.
+// The indexes include the domain, which is derived from the short URL, so every domain deduplicates on its own.
+func (s *PostgresStorage) dedupMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable DROP CONSTRAINT IF EXISTS urlstable_original_url_key;
+	ALTER TABLE urlsTable ADD COLUMN IF NOT EXISTS domain TEXT GENERATED ALWAYS AS (
+		CASE WHEN strpos(short_url, '~') > 0 THEN split_part(short_url, '~', 1) ELSE '' END
+	) STORED;
+	DROP INDEX IF EXISTS urls_original_url_global_idx;
+	DROP INDEX IF EXISTS urls_original_url_user_idx
+`)
+	if err != nil {
+		return err
+	}
+
+	var statements string
+	switch s.dedupScope {
+	case storage.DedupNone:
+		statements = `
+		DROP INDEX IF EXISTS urls_domain_original_url_global_idx;
+		DROP INDEX IF EXISTS urls_domain_original_url_user_idx`
+	case storage.DedupUser:
+		statements = `
+		DROP INDEX IF EXISTS urls_domain_original_url_global_idx;
+		CREATE UNIQUE INDEX IF NOT EXISTS urls_domain_original_url_user_idx ON urlsTable (domain, user_id, original_url) WHERE is_deleted = false`
+	default:
+		statements = `
+		DROP INDEX IF EXISTS urls_domain_original_url_user_idx;
+		CREATE UNIQUE INDEX IF NOT EXISTS urls_domain_original_url_global_idx ON urlsTable (domain, original_url) WHERE is_deleted = false`
+	}
+	_, err = s.db.Exec(ctx, statements)
+	if err != nil {
+		return err
+	}
+	return s.redirectMigration(ctx)
+}
+
+// redirectMigration adds the redirect_status column, zero means the server default, and then calls the passthroughMigration method.
+func (s *PostgresStorage) redirectMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS redirect_status INT NOT NULL DEFAULT 0
+`)
+	if err != nil {
+		return err
+	}
+	return s.passthroughMigration(ctx)
+}
+
+// passthroughMigration adds the columns that forward the query string and the trailing path on redirect,
+// and then calls the utmMigration method.
+func (s *PostgresStorage) passthroughMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS pass_query BOOL NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS pass_path BOOL NOT NULL DEFAULT false
+`)
+	if err != nil {
+		return err
+	}
+	return s.utmMigration(ctx)
+}
+
+// utmMigration adds the utm column with the campaign parameters of a link and an index on the campaign,
+// and then calls the targetingMigration method.
+func (s *PostgresStorage) utmMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS utm JSONB;
+	CREATE INDEX IF NOT EXISTS urls_user_campaign_idx ON urlsTable (user_id, (utm->>'campaign'))
+`)
+	if err != nil {
+		return err
+	}
+	return s.targetingMigration(ctx)
+}
+
+// targetingMigration adds the targets column with the device and platform rules of a link,
+// and then calls the variantsMigration method.
+func (s *PostgresStorage) targetingMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS targets JSONB
+`)
+	if err != nil {
+		return err
+	}
+	return s.variantsMigration(ctx)
+}
+
+// variantsMigration adds the variants column for A/B splits and creates the clicksTable with the served redirects,
+// and then calls the clickLimitMigration method.
+func (s *PostgresStorage) variantsMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS variants JSONB;
+	CREATE TABLE IF NOT EXISTS clicksTable (
+		short_url TEXT NOT NULL,
+		variant TEXT NOT NULL DEFAULT '',
+		clicked_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS clicks_short_url_idx ON clicksTable (short_url, clicked_at)
+`)
+	if err != nil {
+		return err
+	}
+	return s.clickLimitMigration(ctx)
+}
+
+// clickLimitMigration adds the click limit of a link and the number of clicks it has left,
+// and then calls the windowMigration method.
+func (s *PostgresStorage) clickLimitMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS max_clicks INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS clicks_left INT NOT NULL DEFAULT 0
+`)
+	if err != nil {
+		return err
+	}
+	return s.windowMigration(ctx)
+}
+
+// windowMigration adds the activation window of a link and the placeholder URL used before it opens,
+// and then calls the previewMigration method.
+func (s *PostgresStorage) windowMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS placeholder_url TEXT NOT NULL DEFAULT ''
+`)
+	if err != nil {
+		return err
+	}
+	return s.previewMigration(ctx)
+}
+
+// previewMigration adds the interstitial setting of a link and its creation time,
+// and then calls the openGraphMigration method.
+// The creation time of existing links is unknown and stays NULL.
+func (s *PostgresStorage) previewMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS interstitial BOOL NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ
+`)
+	if err != nil {
+		return err
+	}
+	return s.openGraphMigration(ctx)
+}
+
+// openGraphMigration adds the preview tags of a link, and then calls the healthMigration method.
+func (s *PostgresStorage) openGraphMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS open_graph JSONB
+`)
+	if err != nil {
+		return err
+	}
+	return s.healthMigration(ctx)
+}
+
+// healthMigration adds the result of the last check of the destination of a link,
+// and then calls the webhooksMigration method.
+func (s *PostgresStorage) healthMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE urlsTable
+	ADD COLUMN IF NOT EXISTS health JSONB
+`)
+	if err != nil {
+		return err
+	}
+	return s.webhooksMigration(ctx)
+}
+
+// webhooksMigration creates the webhooksTable with the registered webhooks and the
+// webhookDeliveriesTable that serves as their outbox, and then calls the visitorsMigration method.
+func (s *PostgresStorage) webhooksMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	CREATE TABLE IF NOT EXISTS webhooksTable (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT[] NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooksTable (user_id);
+	CREATE TABLE IF NOT EXISTS webhookDeliveriesTable (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL REFERENCES webhooksTable (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		response_status INT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		delivered_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhookDeliveriesTable (next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhookDeliveriesTable (webhook_id, created_at)
+`)
+	if err != nil {
+		return err
+	}
+	return s.visitorsMigration(ctx)
+}
+
+// visitorsMigration adds the visitor hash, referrer domain, browser, OS and country of a click.
+func (s *PostgresStorage) visitorsMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	ALTER TABLE clicksTable
+	ADD COLUMN IF NOT EXISTS visitor TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS referrer TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS browser TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS os TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT ''
 `)
 	return err
 }
 
+// tagsMigration creates the urlTagsTable that links short URLs with their tags, and then calls the dedupMigration method.
+func (s *PostgresStorage) tagsMigration(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+	CREATE TABLE IF NOT EXISTS urlTagsTable (
+		short_url TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (short_url, tag)
+	);
+	CREATE INDEX IF NOT EXISTS url_tags_tag_idx ON urlTagsTable (tag)
+`)
+	if err != nil {
+		return err
+	}
+	return s.dedupMigration(ctx)
+}
+
 // GetStats returns the number of users and urls in the database.
 func (s *PostgresStorage) GetStats(ctx context.Context) (storage.Stats, error) {
 	var stats storage.Stats
 	err := s.db.QueryRow(ctx, `
 		SELECT COUNT(*) FILTER (WHERE is_deleted = false) AS urls,
-		       COUNT(DISTINCT user_id) AS users
+		       COUNT(DISTINCT user_id) FILTER (WHERE is_deleted = false) AS users
 		FROM urlsTable
 	`).Scan(&stats.URLs, &stats.Users)
 	if err != nil {
@@ -3,7 +3,12 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
 )
@@ -21,20 +26,89 @@ type Storage interface {
 	// It returns the short URL and an error if there was a conflict.
 	Save(ctx context.Context, savedURL SavedURL) (string, error)
 
-	// SaveArray saves an array of URLs to the storage.
-	SaveArray(ctx context.Context, savedUrls []SavedURL) error
+	// SaveArray saves an array of URLs to the storage and returns a result for every URL in the same order.
+	// URLs that conflict with saved ones, or with earlier URLs of the batch, are reported as existing.
+	// If atomic is true and any URL conflicts, nothing is saved and ErrURLConflict is returned.
+	SaveArray(ctx context.Context, savedUrls []SavedURL, atomic bool) ([]SaveResult, error)
+
+	// Import saves a chunk of imported URLs and returns a result for every URL in the same order.
+	// It resolves conflicts like SaveArray and reports URLs whose short URL is already used as taken.
+	// The deleted flag of the URLs is kept, so Import can also load migrated and backed up URLs.
+	Import(ctx context.Context, savedUrls []SavedURL) ([]SaveResult, error)
 
 	// Get retrieves a URL from the storage.
+	// It returns ErrURLNotFound if the URL does not exist.
 	Get(ctx context.Context, key string) (SavedURL, error)
 
 	// IsUserIDExists checks if a user ID exists in the storage.
 	IsUserIDExists(ctx context.Context, userID string) (bool, error)
 
-	// GetByUser retrieves all URLs associated with a user.
-	GetByUser(ctx context.Context, userID string) ([]SavedURL, error)
+	// GetByUser retrieves all URLs associated with a user that match the filter.
+	GetByUser(ctx context.Context, userID string, filter URLFilter) ([]SavedURL, error)
+
+	// ExportByUser calls fn for every URL of a user that matches the filter without loading all of them at once.
+	// Iteration stops at the first error returned by fn.
+	ExportByUser(ctx context.Context, userID string, filter URLFilter, fn func(SavedURL) error) error
+
+	// ExportAll calls fn for every stored URL, including deleted ones, with its owner and tags.
+	// The URLs come from a consistent snapshot of the storage. Iteration stops at the first error returned by fn.
+	ExportAll(ctx context.Context, fn func(SavedURL) error) error
+
+	// GetTags returns the tags used by a user together with the number of links carrying each tag.
+	GetTags(ctx context.Context, userID string) ([]TagCount, error)
+
+	// UpdateTags replaces the tags of a link owned by the user.
+	UpdateTags(ctx context.Context, userID string, shortURL string, tags []string) error
+
+	// UpdateVariants replaces the A/B variants of a link owned by the user.
+	UpdateVariants(ctx context.Context, userID string, shortURL string, variants []models.Variant) error
+
+	// ConsumeClick atomically takes one of the remaining clicks of a link with a click limit.
+	// It returns ErrClickLimitReached if no clicks are left.
+	ConsumeClick(ctx context.Context, shortURL string) error
+
+	// UpdateHealth records the results of destination checks. Links that no longer exist are skipped.
+	UpdateHealth(ctx context.Context, checks []HealthCheck) error
+
+	// SaveClicks records served redirects.
+	SaveClicks(ctx context.Context, clicks []Click) error
+
+	// GetClickStats returns the statistics of the recorded clicks of a link that match the filter.
+	GetClickStats(ctx context.Context, shortURL string, filter ClickFilter) (ClickStats, error)
+
+	// SaveWebhook registers a webhook.
+	SaveWebhook(ctx context.Context, webhook Webhook) error
+
+	// GetWebhooks returns the webhooks registered by the user.
+	GetWebhooks(ctx context.Context, userID string) ([]Webhook, error)
+
+	// DeleteWebhook removes a webhook of the user together with its deliveries.
+	// It returns ErrWebhookNotFound if the user has no such webhook.
+	DeleteWebhook(ctx context.Context, userID string, id string) error
+
+	// EnqueueDeliveries adds deliveries to the outbox.
+	EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error
+
+	// ClaimDeliveries returns up to limit pending deliveries that are due at now, oldest first,
+	// and postpones them to now+lease, so other instances don't send them while they are being sent.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+
+	// UpdateDelivery records the outcome of an attempt to send a delivery.
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+
+	// GetDeliveries returns up to limit deliveries of a webhook of the user, newest first.
+	GetDeliveries(ctx context.Context, userID string, webhookID string, limit int) ([]Delivery, error)
+
+	// PruneDeliveries removes the finished deliveries created before the time and returns how many were removed.
+	PruneDeliveries(ctx context.Context, before time.Time) (int, error)
 
 	// Delete deletes specified URLs.
-	Delete(ctx context.Context, deleteTaskSlice []models.DeleteTask) error
+	// It returns the URLs deleted by this call: tasks for URLs of other users or URLs already deleted are skipped.
+	Delete(ctx context.Context, deleteTaskSlice []models.DeleteTask) ([]SavedURL, error)
+
+	// Restore marks a deleted URL as not deleted.
+	// It returns ErrURLNotFound if the URL does not exist and ErrURLConflict if the original URL was shortened again.
+	Restore(ctx context.Context, shortURL string) error
 
 	// Clean cleans the storage.
 	Clean(ctx context.Context) error
@@ -49,12 +123,672 @@ type Storage interface {
 // ErrURLConflict is an error that occurs when a URL is already taken.
 var ErrURLConflict = errors.New("url is already taken")
 
+// ErrURLNotFound is an error that occurs when a URL does not exist or belongs to another user.
+var ErrURLNotFound = errors.New("url not found")
+
+// ErrClickLimitReached is returned when a link with a click limit has served all of its redirects.
+var ErrClickLimitReached = errors.New("click limit reached")
+
+// ErrWebhookNotFound is returned when a webhook does not exist or belongs to another user.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// SaveStatus describes what happened to a URL of a batch.
+type SaveStatus string
+
+const (
+	// SaveCreated means a new short URL was created.
+	SaveCreated SaveStatus = "created"
+	// SaveExisting means the URL was already shortened and the existing short URL is returned.
+	SaveExisting SaveStatus = "existing"
+	// SaveInvalid means the URL was rejected by validation or screening.
+	SaveInvalid SaveStatus = "invalid"
+	// SaveSkipped means the URL was valid but not saved because an atomic batch failed.
+	SaveSkipped SaveStatus = "skipped"
+	// SaveTaken means the short URL is already used by another link.
+	SaveTaken SaveStatus = "taken"
+)
+
+// SaveResult is the outcome of saving a URL of a batch.
+type SaveResult struct {
+	Status   SaveStatus
+	ShortURL string
+}
+
+// ResolveBatch checks every URL of a batch against the saved URLs and the earlier URLs of the batch.
+// It returns a result for every URL and the URLs that have to be created.
+// URLs whose short URL is already used are reported as taken.
+func ResolveBatch(scope DedupScope, saved []SavedURL, batch []SavedURL) ([]SaveResult, []SavedURL) {
+	index := NewURLIndex(scope)
+	for _, savedURL := range saved {
+		index.Add(savedURL)
+	}
+	return index.Resolve(batch)
+}
+
+// RestoreConflict returns the saved URL that prevents the URL at index i from being restored.
+func RestoreConflict(scope DedupScope, urls []SavedURL, i int) (SavedURL, bool) {
+	restored := urls[i]
+	restored.IsDeleted = false
+	for j, existing := range urls {
+		if j != i && scope.Conflicts(existing, restored) {
+			return existing, true
+		}
+	}
+	return SavedURL{}, false
+}
+
+// dedupKey identifies the URLs that conflict with each other within a dedup scope.
+type dedupKey struct {
+	domain      string
+	userID      string
+	originalURL string
+}
+
+// URLIndex looks up the saved URL a new URL conflicts with and the short URLs that are taken
+// without going through every saved URL, so imports of millions of links stay linear.
+// It is built once from the saved URLs and kept up to date with Add while URLs are appended.
+type URLIndex struct {
+	scope     DedupScope
+	originals map[dedupKey]string
+	taken     map[string]bool
+}
+
+// NewURLIndex creates an empty index for the dedup scope.
+func NewURLIndex(scope DedupScope) *URLIndex {
+	return &URLIndex{scope: scope, originals: make(map[dedupKey]string), taken: make(map[string]bool)}
+}
+
+// key returns the dedup key of the URL, or false if the URL conflicts with no other URL.
+func (x *URLIndex) key(savedURL SavedURL) (dedupKey, bool) {
+	if savedURL.IsDeleted || x.scope == DedupNone {
+		return dedupKey{}, false
+	}
+	key := dedupKey{domain: KeyDomain(savedURL.ShortURL), originalURL: savedURL.OriginalURL}
+	if x.scope == DedupUser {
+		key.userID = savedURL.UserID
+	}
+	return key, true
+}
+
+// Add indexes a saved URL. The first saved URL of a dedup key is the one conflicts are reported with.
+func (x *URLIndex) Add(savedURL SavedURL) {
+	x.taken[savedURL.ShortURL] = true
+	if key, ok := x.key(savedURL); ok {
+		if _, exists := x.originals[key]; !exists {
+			x.originals[key] = savedURL.ShortURL
+		}
+	}
+}
+
+// Conflict returns the short URL of the saved URL the new URL is a duplicate of.
+func (x *URLIndex) Conflict(savedURL SavedURL) (string, bool) {
+	key, ok := x.key(savedURL)
+	if !ok {
+		return "", false
+	}
+	shortURL, ok := x.originals[key]
+	return shortURL, ok
+}
+
+// Resolve checks every URL of a batch against the index and the earlier URLs of the batch.
+// It returns a result for every URL and the URLs that have to be created.
+// The index is not changed, the created URLs are added once they are saved.
+func (x *URLIndex) Resolve(batch []SavedURL) ([]SaveResult, []SavedURL) {
+	results := make([]SaveResult, len(batch))
+	var created []SavedURL
+	pending := NewURLIndex(x.scope)
+
+	for i, savedURL := range batch {
+		existing, ok := x.Conflict(savedURL)
+		if !ok {
+			existing, ok = pending.Conflict(savedURL)
+		}
+		if ok {
+			results[i] = SaveResult{Status: SaveExisting, ShortURL: existing}
+			continue
+		}
+		if x.taken[savedURL.ShortURL] || pending.taken[savedURL.ShortURL] {
+			results[i] = SaveResult{Status: SaveTaken, ShortURL: savedURL.ShortURL}
+			continue
+		}
+		results[i] = SaveResult{Status: SaveCreated, ShortURL: savedURL.ShortURL}
+		pending.Add(savedURL)
+		created = append(created, savedURL)
+	}
+	return results, created
+}
+
+// HasConflicts reports whether any URL of the batch was not created.
+func HasConflicts(results []SaveResult) bool {
+	for _, result := range results {
+		if result.Status != SaveCreated {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipCreated marks the created results as skipped after an atomic batch failed.
+func SkipCreated(results []SaveResult) {
+	for i := range results {
+		if results[i].Status == SaveCreated {
+			results[i].Status = SaveSkipped
+		}
+	}
+}
+
+// DedupScope defines which saved URLs are considered duplicates of each other.
+type DedupScope string
+
+const (
+	// DedupGlobal makes an original URL unique across all users.
+	DedupGlobal DedupScope = "global"
+	// DedupUser makes an original URL unique per user, so every user owns their links.
+	DedupUser DedupScope = "user"
+	// DedupNone disables deduplication, every request creates a new link.
+	DedupNone DedupScope = "none"
+)
+
+// ParseDedupScope converts a string to a DedupScope.
+// An empty string means DedupGlobal.
+func ParseDedupScope(scope string) (DedupScope, error) {
+	switch DedupScope(scope) {
+	case "", DedupGlobal:
+		return DedupGlobal, nil
+	case DedupUser, DedupNone:
+		return DedupScope(scope), nil
+	}
+	return "", fmt.Errorf("unknown dedup scope %q", scope)
+}
+
+// Conflicts reports whether the new URL is a duplicate of the existing one within the scope.
+// Deleted URLs and URLs on different domains never conflict.
+func (s DedupScope) Conflicts(existing, savedURL SavedURL) bool {
+	if existing.IsDeleted || savedURL.IsDeleted || existing.OriginalURL != savedURL.OriginalURL {
+		return false
+	}
+	if KeyDomain(existing.ShortURL) != KeyDomain(savedURL.ShortURL) {
+		return false
+	}
+
+	switch s {
+	case DedupNone:
+		return false
+	case DedupUser:
+		return existing.UserID == savedURL.UserID
+	default:
+		return true
+	}
+}
+
+// domainSeparator separates the domain from the short ID in the keys of links on additional domains.
+// Short IDs never contain it.
+const domainSeparator = "~"
+
+// DomainKey returns the key under which a short ID on the domain is stored.
+// Links on the default domain, which is empty, are stored under their short ID,
+// so short IDs are unique per domain.
+func DomainKey(domain, id string) string {
+	if domain == "" {
+		return id
+	}
+	return domain + domainSeparator + id
+}
+
+// SplitKey splits a stored key into the domain and the short ID.
+func SplitKey(key string) (domain, id string) {
+	if i := strings.Index(key, domainSeparator); i >= 0 {
+		return key[:i], key[i+len(domainSeparator):]
+	}
+	return "", key
+}
+
+// KeyDomain returns the domain of a stored key, empty for the default domain.
+func KeyDomain(key string) string {
+	domain, _ := SplitKey(key)
+	return domain
+}
+
 // SavedURL represents a saved URL.
 type SavedURL struct {
 	ShortURL    string `json:"shortUrl"`
 	OriginalURL string `json:"originalUrl"`
 	UserID      string `json:"userID"`
 	IsDeleted   bool
+	Tags        []string `json:"tags,omitempty"`
+	// RedirectStatus is the HTTP status of the redirect, zero for the server default.
+	RedirectStatus int `json:"redirectStatus,omitempty"`
+	// PassQuery forwards the query string of the short URL to the destination.
+	PassQuery bool `json:"passQuery,omitempty"`
+	// PassPath appends the path after the short ID to the destination.
+	PassPath bool `json:"passPath,omitempty"`
+	// UTM holds the campaign parameters that were added to the original URL.
+	UTM *models.UTM `json:"utm,omitempty"`
+	// Targets are the device and platform rules checked before redirecting to the original URL.
+	Targets []models.TargetRule `json:"targets,omitempty"`
+	// Variants split the redirects between several destinations, see package split.
+	Variants []models.Variant `json:"variants,omitempty"`
+	// MaxClicks is the number of redirects the link serves, zero for no limit.
+	MaxClicks int `json:"maxClicks,omitempty"`
+	// ClicksLeft is the number of redirects the link still serves if it has a limit.
+	ClicksLeft int `json:"clicksLeft,omitempty"`
+	// NotBefore and NotAfter limit the time the link redirects, see redirect.CheckWindow.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+	// PlaceholderURL is where the link redirects before NotBefore.
+	PlaceholderURL string `json:"placeholderUrl,omitempty"`
+	// Interstitial shows the preview page of the link instead of redirecting.
+	Interstitial bool `json:"interstitial,omitempty"`
+	// CreatedAt is when the link was created, nil for links created before it was recorded.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// OpenGraph holds the preview tags set on the link, see package unfurl.
+	OpenGraph *models.OpenGraph `json:"openGraph,omitempty"`
+	// Health is the result of the last check of the destination, nil if it was not checked yet.
+	Health *models.LinkHealth `json:"health,omitempty"`
+}
+
+// HealthCheck is the result of checking the destination of a link.
+type HealthCheck struct {
+	ShortURL string
+	Health   models.LinkHealth
+}
+
+// ConsumeClick takes one of the remaining clicks of the saved URL.
+// It returns ErrClickLimitReached if the link has a limit and no clicks are left.
+func ConsumeClick(savedURL *SavedURL) error {
+	if savedURL.MaxClicks <= 0 {
+		return nil
+	}
+	if savedURL.ClicksLeft <= 0 {
+		return ErrClickLimitReached
+	}
+	savedURL.ClicksLeft--
+	return nil
+}
+
+// ApplyHealth sets the results of destination checks on the saved URLs they belong to.
+func ApplyHealth(savedURLs []SavedURL, checks []HealthCheck) {
+	results := make(map[string]models.LinkHealth, len(checks))
+	for _, check := range checks {
+		results[check.ShortURL] = check.Health
+	}
+	for i := range savedURLs {
+		if health, ok := results[savedURLs[i].ShortURL]; ok {
+			savedURLs[i].Health = &health
+		}
+	}
+}
+
+// Click is a served redirect.
+// Visitor identifies the client without storing its address, see package clicks. Referrer is the domain
+// of the page the visitor came from, Browser and OS are parsed from the user agent, and Country is
+// the ISO code of the client location. Empty fields are unknown.
+type Click struct {
+	ShortURL string    `json:"shortUrl"`
+	Variant  string    `json:"variant,omitempty"`
+	Time     time.Time `json:"time"`
+	Visitor  string    `json:"visitor,omitempty"`
+	Referrer string    `json:"referrer,omitempty"`
+	Browser  string    `json:"browser,omitempty"`
+	OS       string    `json:"os,omitempty"`
+	Country  string    `json:"country,omitempty"`
+}
+
+// StatsBucket is the length of the periods clicks are grouped by.
+type StatsBucket string
+
+const (
+	// BucketNone doesn't group the clicks by period.
+	BucketNone StatsBucket = ""
+	// BucketHour groups the clicks by hour.
+	BucketHour StatsBucket = "hour"
+	// BucketDay groups the clicks by UTC day.
+	BucketDay StatsBucket = "day"
+	// BucketWeek groups the clicks by UTC week starting on Monday.
+	BucketWeek StatsBucket = "week"
+)
+
+// ParseStatsBucket converts a string to a StatsBucket.
+// An empty string means BucketNone.
+func ParseStatsBucket(bucket string) (StatsBucket, error) {
+	switch StatsBucket(bucket) {
+	case BucketNone, BucketHour, BucketDay, BucketWeek:
+		return StatsBucket(bucket), nil
+	}
+	return "", fmt.Errorf("unknown stats bucket %q, expected hour, day or week", bucket)
+}
+
+// Start returns the start of the period the time falls into, in UTC.
+func (b StatsBucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case BucketWeek:
+		sinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-sinceMonday, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+// ClickFilter selects the clicks counted by GetClickStats and the periods they are grouped by.
+// From is inclusive and To is exclusive, zero times are not applied.
+type ClickFilter struct {
+	From   time.Time
+	To     time.Time
+	Bucket StatsBucket
+}
+
+// Match reports whether the click happened within the time range of the filter.
+func (f ClickFilter) Match(click Click) bool {
+	if !f.From.IsZero() && click.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !click.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// ClickStats holds the number of clicks of a link, in total and broken down by A/B variant,
+// referrer domain, browser, OS and country. Clicks with an unknown value are left out of a breakdown.
+type ClickStats struct {
+	Clicks int
+	// Visitors is the number of distinct visitors. A visitor is identified anew every day,
+	// so a visitor who comes back on another day is counted again.
+	Visitors  int
+	Variants  map[string]int
+	Referrers map[string]int
+	Browsers  map[string]int
+	OS        map[string]int
+	Countries map[string]int
+	// Buckets are the clicks per period, oldest first, if the filter groups them.
+	// Periods without clicks are left out.
+	Buckets []ClickBucket
+}
+
+// ClickBucket holds the clicks of a period.
+type ClickBucket struct {
+	Start    time.Time
+	Clicks   int
+	Visitors int
+}
+
+// NewClickStats returns empty statistics.
+func NewClickStats() ClickStats {
+	return ClickStats{
+		Variants:  make(map[string]int),
+		Referrers: make(map[string]int),
+		Browsers:  make(map[string]int),
+		OS:        make(map[string]int),
+		Countries: make(map[string]int),
+	}
+}
+
+// CountClicks counts the clicks of the link that match the filter.
+func CountClicks(clicks []Click, shortURL string, filter ClickFilter) ClickStats {
+	stats := NewClickStats()
+	visitors := make(map[string]bool)
+	buckets := make(map[time.Time]*ClickBucket)
+	bucketVisitors := make(map[time.Time]map[string]bool)
+	for _, click := range clicks {
+		if click.ShortURL != shortURL || !filter.Match(click) {
+			continue
+		}
+		stats.Clicks++
+		countValue(stats.Variants, click.Variant)
+		countValue(stats.Referrers, click.Referrer)
+		countValue(stats.Browsers, click.Browser)
+		countValue(stats.OS, click.OS)
+		countValue(stats.Countries, click.Country)
+		if click.Visitor != "" {
+			visitors[click.Visitor] = true
+		}
+
+		if filter.Bucket == BucketNone {
+			continue
+		}
+		start := filter.Bucket.Start(click.Time)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &ClickBucket{Start: start}
+			buckets[start] = bucket
+			bucketVisitors[start] = make(map[string]bool)
+		}
+		bucket.Clicks++
+		if click.Visitor != "" && !bucketVisitors[start][click.Visitor] {
+			bucketVisitors[start][click.Visitor] = true
+			bucket.Visitors++
+		}
+	}
+	stats.Visitors = len(visitors)
+
+	for _, bucket := range buckets {
+		stats.Buckets = append(stats.Buckets, *bucket)
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool { return stats.Buckets[i].Start.Before(stats.Buckets[j].Start) })
+	return stats
+}
+
+// countValue counts a click for a known value of a breakdown.
+func countValue(counts map[string]int, value string) {
+	if value != "" {
+		counts[value]++
+	}
+}
+
+// TopReferrers is the number of referrer domains listed in the statistics of a link.
+const TopReferrers = 10
+
+// Count is a value of a breakdown of clicks with its number of clicks.
+type Count struct {
+	Name   string
+	Clicks int
+}
+
+// TopCounts returns the values of the breakdown with the most clicks first, ties in alphabetical order.
+// A positive limit keeps only that many values.
+func TopCounts(counts map[string]int, limit int) []Count {
+	top := make([]Count, 0, len(counts))
+	for name, clicks := range counts {
+		top = append(top, Count{Name: name, Clicks: clicks})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Clicks != top[j].Clicks {
+			return top[i].Clicks > top[j].Clicks
+		}
+		return top[i].Name < top[j].Name
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+// Webhook is an endpoint registered by a user to receive the events of their links, see package webhooks.
+type Webhook struct {
+	ID     string `json:"id"`
+	UserID string `json:"userID"`
+	URL    string `json:"url"`
+	// Secret signs the deliveries.
+	Secret string `json:"secret"`
+	// Events are the event types sent to the endpoint.
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DeliveryStatus is the state of a delivery in the outbox.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending means the delivery is waiting for its next attempt.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered means the endpoint accepted the delivery.
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed means every attempt failed and the delivery was given up.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is an event queued for a webhook with the outcome of the last attempt to send it.
+type Delivery struct {
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhookID"`
+	UserID    string          `json:"userID"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    DeliveryStatus  `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// NextAttempt is when a pending delivery is sent next.
+	NextAttempt time.Time `json:"nextAttempt"`
+	// ResponseStatus is the status the endpoint responded with, zero if it could not be reached and Error says why.
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// ClaimDeliveries postpones up to limit pending deliveries that are due at now to now+lease
+// and returns them, oldest first.
+func ClaimDeliveries(deliveries []Delivery, now time.Time, lease time.Duration, limit int) []Delivery {
+	var due []int
+	for i, delivery := range deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttempt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return deliveries[due[i]].NextAttempt.Before(deliveries[due[j]].NextAttempt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]Delivery, len(due))
+	for i, index := range due {
+		deliveries[index].NextAttempt = now.Add(lease)
+		claimed[i] = deliveries[index]
+	}
+	return claimed
+}
+
+// ListDeliveries returns up to limit deliveries of the webhook of the user, newest first.
+func ListDeliveries(deliveries []Delivery, userID, webhookID string, limit int) []Delivery {
+	var listed []Delivery
+	for i := len(deliveries) - 1; i >= 0 && len(listed) < limit; i-- {
+		if deliveries[i].UserID == userID && deliveries[i].WebhookID == webhookID {
+			listed = append(listed, deliveries[i])
+		}
+	}
+	return listed
+}
+
+// PruneDeliveries removes the finished deliveries created before the time.
+// It returns the remaining deliveries and the number of removed ones.
+func PruneDeliveries(deliveries []Delivery, before time.Time) ([]Delivery, int) {
+	kept := deliveries[:0]
+	for _, delivery := range deliveries {
+		if delivery.Status != DeliveryPending && delivery.CreatedAt.Before(before) {
+			continue
+		}
+		kept = append(kept, delivery)
+	}
+	return kept, len(deliveries) - len(kept)
+}
+
+// RemoveWebhook removes the webhook of the user and its deliveries.
+// It returns the remaining webhooks and deliveries, and ErrWebhookNotFound if the user has no such webhook.
+func RemoveWebhook(webhooks []Webhook, deliveries []Delivery, userID, id string) ([]Webhook, []Delivery, error) {
+	for i, webhook := range webhooks {
+		if webhook.ID != id || webhook.UserID != userID {
+			continue
+		}
+		webhooks = append(webhooks[:i], webhooks[i+1:]...)
+		kept := deliveries[:0]
+		for _, delivery := range deliveries {
+			if delivery.WebhookID != id {
+				kept = append(kept, delivery)
+			}
+		}
+		return webhooks, kept, nil
+	}
+	return webhooks, deliveries, ErrWebhookNotFound
+}
+
+// ReplaceDelivery replaces the delivery with the same ID. Deliveries of removed webhooks are skipped.
+func ReplaceDelivery(deliveries []Delivery, delivery Delivery) {
+	for i := range deliveries {
+		if deliveries[i].ID == delivery.ID {
+			deliveries[i] = delivery
+			return
+		}
+	}
+}
+
+// TagCount represents a tag and the number of links it is attached to.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// URLFilter narrows down the URLs returned by GetByUser.
+// Empty fields are not applied.
+type URLFilter struct {
+	Tag      string
+	Campaign string
+	// Broken limits the URLs to the ones whose destination was broken when last checked.
+	Broken bool
+}
+
+// Match reports whether the saved URL satisfies the filter.
+func (f URLFilter) Match(savedURL SavedURL) bool {
+	if f.Tag != "" && !HasTag(savedURL.Tags, f.Tag) {
+		return false
+	}
+	if f.Campaign != "" && (savedURL.UTM == nil || savedURL.UTM.Campaign != f.Campaign) {
+		return false
+	}
+	if f.Broken && (savedURL.Health == nil || !savedURL.Health.Broken) {
+		return false
+	}
+	return true
+}
+
+// CountTags counts the tags of the user's links that are not deleted.
+// The result is sorted by count in descending order, then by tag.
+func CountTags(savedURLs []SavedURL, userID string) []TagCount {
+	counts := make(map[string]int)
+	for _, savedURL := range savedURLs {
+		if savedURL.UserID != userID || savedURL.IsDeleted {
+			continue
+		}
+		for _, tag := range savedURL.Tags {
+			counts[tag]++
+		}
+	}
+
+	tagCounts := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool {
+		if tagCounts[i].Count != tagCounts[j].Count {
+			return tagCounts[i].Count > tagCounts[j].Count
+		}
+		return tagCounts[i].Tag < tagCounts[j].Tag
+	})
+	return tagCounts
+}
+
+// HasTag reports whether tags contains the tag.
+func HasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 type Stats struct {
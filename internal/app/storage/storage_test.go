@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDedupScope(t *testing.T) {
+	scope, err := ParseDedupScope("")
+	assert.NoError(t, err)
+	assert.Equal(t, DedupGlobal, scope)
+
+	scope, err = ParseDedupScope("user")
+	assert.NoError(t, err)
+	assert.Equal(t, DedupUser, scope)
+
+	_, err = ParseDedupScope("tenant")
+	assert.Error(t, err)
+}
+
+func TestDedupScopeConflicts(t *testing.T) {
+	existing := SavedURL{ShortURL: "abc", OriginalURL: "https://example.com/", UserID: "alice"}
+	sameUser := SavedURL{ShortURL: "def", OriginalURL: "https://example.com/", UserID: "alice"}
+	otherUser := SavedURL{ShortURL: "ghi", OriginalURL: "https://example.com/", UserID: "bob"}
+	otherURL := SavedURL{ShortURL: "jkl", OriginalURL: "https://example.org/", UserID: "alice"}
+	otherDomain := SavedURL{ShortURL: DomainKey("brand.link", "mno"), OriginalURL: "https://example.com/", UserID: "alice"}
+	deleted := existing
+	deleted.IsDeleted = true
+
+	tests := []struct {
+		scope    DedupScope
+		existing SavedURL
+		savedURL SavedURL
+		want     bool
+	}{
+		{scope: DedupGlobal, existing: existing, savedURL: sameUser, want: true},
+		{scope: DedupGlobal, existing: existing, savedURL: otherUser, want: true},
+		{scope: DedupGlobal, existing: existing, savedURL: otherURL, want: false},
+		{scope: DedupGlobal, existing: deleted, savedURL: sameUser, want: false},
+		{scope: DedupGlobal, existing: existing, savedURL: otherDomain, want: false},
+		{scope: "", existing: existing, savedURL: otherUser, want: true},
+		{scope: DedupUser, existing: existing, savedURL: sameUser, want: true},
+		{scope: DedupUser, existing: existing, savedURL: otherUser, want: false},
+		{scope: DedupNone, existing: existing, savedURL: sameUser, want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.scope.Conflicts(tt.existing, tt.savedURL), "scope %q", tt.scope)
+	}
+}
+
+func TestDomainKey(t *testing.T) {
+	assert.Equal(t, "abc", DomainKey("", "abc"))
+
+	key := DomainKey("brand.link", "abc")
+	domain, id := SplitKey(key)
+	assert.Equal(t, "brand.link", domain)
+	assert.Equal(t, "abc", id)
+
+	domain, id = SplitKey("abc")
+	assert.Equal(t, "", domain)
+	assert.Equal(t, "abc", id)
+}
+
+func TestURLFilterMatch(t *testing.T) {
+	savedURL := SavedURL{Tags: []string{"promo"}, UTM: &models.UTM{Campaign: "spring"}}
+
+	assert.True(t, URLFilter{}.Match(savedURL))
+	assert.True(t, URLFilter{Tag: "promo", Campaign: "spring"}.Match(savedURL))
+	assert.False(t, URLFilter{Campaign: "autumn"}.Match(savedURL))
+	assert.False(t, URLFilter{Campaign: "spring"}.Match(SavedURL{}))
+}
+
+func TestStatsBucketStart(t *testing.T) {
+	// A Sunday evening in Moscow is a Sunday afternoon in UTC.
+	clicked := time.Date(2024, 3, 10, 17, 45, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	assert.Equal(t, time.Date(2024, 3, 10, 14, 0, 0, 0, time.UTC), BucketHour.Start(clicked))
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), BucketDay.Start(clicked))
+	assert.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), BucketWeek.Start(clicked), "weeks start on Monday")
+
+	_, err := ParseStatsBucket("month")
+	assert.Error(t, err)
+}
+
+func TestCountClicks(t *testing.T) {
+	monday := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	clicks := []Click{
+		{ShortURL: "abc", Time: monday, Visitor: "v1", Referrer: "google.com", Browser: "chrome", OS: "windows", Country: "DE"},
+		{ShortURL: "abc", Time: monday.Add(time.Minute), Visitor: "v1", Referrer: "google.com", Browser: "chrome", OS: "windows", Country: "DE"},
+		{ShortURL: "abc", Time: monday.Add(time.Hour), Visitor: "v2", Browser: "safari", OS: "ios", Variant: "b"},
+		{ShortURL: "abc", Time: monday.Add(48 * time.Hour), Visitor: "v3", Referrer: "t.me"},
+		{ShortURL: "abc", Time: monday.Add(-time.Hour)},
+		{ShortURL: "other", Time: monday, Visitor: "v9"},
+	}
+
+	stats := CountClicks(clicks, "abc", ClickFilter{From: monday, Bucket: BucketDay})
+	assert.Equal(t, 4, stats.Clicks)
+	assert.Equal(t, 3, stats.Visitors)
+	assert.Equal(t, map[string]int{"google.com": 2, "t.me": 1}, stats.Referrers)
+	assert.Equal(t, map[string]int{"chrome": 2, "safari": 1}, stats.Browsers)
+	assert.Equal(t, map[string]int{"windows": 2, "ios": 1}, stats.OS)
+	assert.Equal(t, map[string]int{"DE": 2}, stats.Countries)
+	assert.Equal(t, map[string]int{"b": 1}, stats.Variants)
+	assert.Equal(t, []ClickBucket{
+		{Start: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Clicks: 3, Visitors: 2},
+		{Start: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), Clicks: 1, Visitors: 1},
+	}, stats.Buckets)
+
+	stats = CountClicks(clicks, "abc", ClickFilter{To: monday})
+	assert.Equal(t, 1, stats.Clicks, "to is exclusive")
+	assert.Equal(t, 0, stats.Visitors)
+	assert.Nil(t, stats.Buckets)
+}
+
+func TestTopCounts(t *testing.T) {
+	counts := map[string]int{"t.me": 3, "google.com": 5, "bing.com": 3, "vk.com": 1}
+
+	assert.Equal(t, []Count{{"google.com", 5}, {"bing.com", 3}, {"t.me", 3}}, TopCounts(counts, 3))
+	assert.Len(t, TopCounts(counts, 0), 4)
+}
+
+func TestURLIndex(t *testing.T) {
+	index := NewURLIndex(DedupUser)
+	index.Add(SavedURL{ShortURL: "abc", OriginalURL: "https://example.com/", UserID: "alice"})
+	index.Add(SavedURL{ShortURL: "old", OriginalURL: "https://example.com/old", UserID: "alice", IsDeleted: true})
+	index.Add(SavedURL{ShortURL: DomainKey("brand.link", "abc"), OriginalURL: "https://example.com/", UserID: "alice"})
+
+	results, created := index.Resolve([]SavedURL{
+		{ShortURL: "a1", OriginalURL: "https://example.com/", UserID: "alice"},
+		{ShortURL: "b1", OriginalURL: "https://example.com/", UserID: "bob"},
+		{ShortURL: "b2", OriginalURL: "https://example.com/", UserID: "bob"},
+		{ShortURL: "old", OriginalURL: "https://example.com/new", UserID: "bob"},
+		{ShortURL: "o2", OriginalURL: "https://example.com/old", UserID: "alice"},
+	})
+	assert.Equal(t, []SaveResult{
+		{Status: SaveExisting, ShortURL: "abc"},
+		{Status: SaveCreated, ShortURL: "b1"},
+		{Status: SaveExisting, ShortURL: "b1"},
+		{Status: SaveTaken, ShortURL: "old"},
+		{Status: SaveCreated, ShortURL: "o2"},
+	}, results)
+	assert.Len(t, created, 2)
+
+	_, ok := index.Conflict(SavedURL{ShortURL: "b3", OriginalURL: "https://example.com/", UserID: "bob"})
+	assert.False(t, ok, "the index only changes when the created URLs are added")
+	for _, savedURL := range created {
+		index.Add(savedURL)
+	}
+	shortURL, ok := index.Conflict(SavedURL{ShortURL: "b3", OriginalURL: "https://example.com/", UserID: "bob"})
+	assert.True(t, ok)
+	assert.Equal(t, "b1", shortURL)
+}
@@ -0,0 +1,255 @@
+// Package targeting selects the destination of a link for the device, platform and location of the client.
+//
+// A link may have rules that send matching clients to another URL, for example iOS users
+// to the App Store or German visitors to the German site. The rules are checked in order
+// and the first match wins, clients that match no rule are sent to the original URL of the link.
+package targeting
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+)
+
+// MaxRules is the number of targeting rules a link may have.
+const MaxRules = 20
+
+// Operating systems recognized in user agents.
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+)
+
+// Device classes recognized in user agents.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// Browsers recognized in user agents.
+const (
+	BrowserChrome  = "chrome"
+	BrowserSafari  = "safari"
+	BrowserFirefox = "firefox"
+	BrowserEdge    = "edge"
+	BrowserOpera   = "opera"
+	BrowserYandex  = "yandex"
+	BrowserSamsung = "samsung"
+)
+
+// browserMarkers are the parts of user agents that name the browser. Most browsers also
+// mention Chrome and Safari for compatibility, so they are checked in order.
+var browserMarkers = []struct {
+	marker  string
+	browser string
+}{
+	{"edg/", BrowserEdge},
+	{"edga/", BrowserEdge},
+	{"edgios/", BrowserEdge},
+	{"opr/", BrowserOpera},
+	{"opera", BrowserOpera},
+	{"yabrowser", BrowserYandex},
+	{"samsungbrowser", BrowserSamsung},
+	{"firefox/", BrowserFirefox},
+	{"fxios", BrowserFirefox},
+	{"chrome/", BrowserChrome},
+	{"crios", BrowserChrome},
+	{"safari/", BrowserSafari},
+}
+
+// Agents tell bots and crawlers apart from people.
+const (
+	AgentBot   = "bot"
+	AgentHuman = "human"
+)
+
+// ErrInvalidTarget is returned for targeting rules that can't be applied.
+var ErrInvalidTarget = errors.New("invalid targeting rule")
+
+// botMarkers are the parts of user agents that are sent by crawlers, link previews and scripts.
+var botMarkers = []string{
+	"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly", "preview",
+	"curl/", "wget/", "python-requests", "go-http-client", "headlesschrome",
+}
+
+// Client is the platform of a request, parsed from its User-Agent header, and its location.
+type Client struct {
+	OS     string
+	Device string
+	// Browser is empty if it isn't recognized.
+	Browser string
+	Bot     bool
+	// Country is the upper case ISO code of the client location, empty if it's unknown.
+	Country string
+}
+
+// Parse returns the platform of the user agent.
+// The OS and the browser are empty if they aren't recognized. Requests without a user agent are treated as bots.
+func Parse(userAgent string) Client {
+	ua := strings.ToLower(userAgent)
+	client := Client{Bot: ua == ""}
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			client.Bot = true
+			break
+		}
+	}
+	for _, marker := range browserMarkers {
+		if strings.Contains(ua, marker.marker) {
+			client.Browser = marker.browser
+			break
+		}
+	}
+
+	// iOS and Android user agents also mention Mac OS X and Linux, so they are checked first.
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		client.OS, client.Device = OSiOS, DeviceMobile
+	case strings.Contains(ua, "ipad"):
+		client.OS, client.Device = OSiOS, DeviceTablet
+	case strings.Contains(ua, "android"):
+		client.OS, client.Device = OSAndroid, DeviceTablet
+		if strings.Contains(ua, "mobile") {
+			client.Device = DeviceMobile
+		}
+	case strings.Contains(ua, "windows"):
+		client.OS, client.Device = OSWindows, DeviceDesktop
+	case strings.Contains(ua, "cros"):
+		client.OS, client.Device = OSChromeOS, DeviceDesktop
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		client.OS, client.Device = OSMacOS, DeviceDesktop
+	case strings.Contains(ua, "linux"):
+		client.OS, client.Device = OSLinux, DeviceDesktop
+	default:
+		client.Device = DeviceDesktop
+		if strings.Contains(ua, "mobile") {
+			client.Device = DeviceMobile
+		}
+	}
+	return client
+}
+
+// Matches reports whether the client satisfies every condition of the rule.
+func (c Client) Matches(rule models.TargetRule) bool {
+	if rule.OS != "" && rule.OS != c.OS {
+		return false
+	}
+	if rule.Device != "" && rule.Device != c.Device {
+		return false
+	}
+	if len(rule.Countries) > 0 && !contains(rule.Countries, c.Country) {
+		return false
+	}
+	switch rule.Agent {
+	case AgentBot:
+		return c.Bot
+	case AgentHuman:
+		return !c.Bot
+	}
+	return true
+}
+
+// Select returns the URL of the first rule the client matches.
+// It returns false if no rule matches and the original URL should be used.
+func Select(rules []models.TargetRule, client Client) (string, bool) {
+	for _, rule := range rules {
+		if client.Matches(rule) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// Normalize lowercases the conditions of the rules and checks that they can be matched.
+// The URLs of the rules are left to the caller.
+func Normalize(rules []models.TargetRule) ([]models.TargetRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > MaxRules {
+		return nil, fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidTarget, MaxRules)
+	}
+
+	normalized := make([]models.TargetRule, len(rules))
+	for i, rule := range rules {
+		rule.OS = strings.ToLower(strings.TrimSpace(rule.OS))
+		rule.Device = strings.ToLower(strings.TrimSpace(rule.Device))
+		rule.Agent = strings.ToLower(strings.TrimSpace(rule.Agent))
+		countries, err := normalizeCountries(rule.Countries)
+		if err != nil {
+			return nil, err
+		}
+		rule.Countries = countries
+
+		switch {
+		case rule.OS == "" && rule.Device == "" && rule.Agent == "" && len(rule.Countries) == 0:
+			return nil, fmt.Errorf("%w: rule %d has no conditions", ErrInvalidTarget, i+1)
+		case !oneOf(rule.OS, OSiOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS):
+			return nil, fmt.Errorf("%w: unknown os %q", ErrInvalidTarget, rule.OS)
+		case !oneOf(rule.Device, DeviceMobile, DeviceTablet, DeviceDesktop):
+			return nil, fmt.Errorf("%w: unknown device %q", ErrInvalidTarget, rule.Device)
+		case !oneOf(rule.Agent, AgentBot, AgentHuman):
+			return nil, fmt.Errorf("%w: unknown agent %q, expected bot or human", ErrInvalidTarget, rule.Agent)
+		case strings.TrimSpace(rule.URL) == "":
+			return nil, fmt.Errorf("%w: rule %d has no url", ErrInvalidTarget, i+1)
+		}
+		normalized[i] = rule
+	}
+	return normalized, nil
+}
+
+// NeedsCountry reports whether any of the rules has a country condition,
+// so the location of the client has to be looked up.
+func NeedsCountry(rules []models.TargetRule) bool {
+	for _, rule := range rules {
+		if len(rule.Countries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCountries upper cases the country codes and checks that they have two letters.
+func normalizeCountries(countries []string) ([]string, error) {
+	var normalized []string
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			return nil, fmt.Errorf("%w: invalid country %q, expected a two letter ISO code", ErrInvalidTarget, country)
+		}
+		if !contains(normalized, country) {
+			normalized = append(normalized, country)
+		}
+	}
+	return normalized, nil
+}
+
+// contains reports whether the value is in the list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOf reports whether the value is empty or one of the allowed values.
+func oneOf(value string, allowed ...string) bool {
+	if value == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	iPhoneUA    = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	androidUA   = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36"
+	tabletUA    = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	macUA       = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+	googlebotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	edgeUA      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0"
+	firefoxUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      Client
+	}{
+		{userAgent: iPhoneUA, want: Client{OS: OSiOS, Device: DeviceMobile, Browser: BrowserSafari}},
+		{userAgent: androidUA, want: Client{OS: OSAndroid, Device: DeviceMobile, Browser: BrowserChrome}},
+		{userAgent: tabletUA, want: Client{OS: OSAndroid, Device: DeviceTablet, Browser: BrowserChrome}},
+		{userAgent: macUA, want: Client{OS: OSMacOS, Device: DeviceDesktop, Browser: BrowserSafari}},
+		{userAgent: edgeUA, want: Client{OS: OSWindows, Device: DeviceDesktop, Browser: BrowserEdge}},
+		{userAgent: firefoxUA, want: Client{OS: OSLinux, Device: DeviceDesktop, Browser: BrowserFirefox}},
+		{userAgent: googlebotUA, want: Client{Device: DeviceDesktop, Bot: true}},
+		{userAgent: "", want: Client{Device: DeviceDesktop, Bot: true}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Parse(tt.userAgent), tt.userAgent)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	rules := []models.TargetRule{
+		{OS: OSiOS, Agent: AgentHuman, URL: "https://apps.apple.com/app/id1"},
+		{OS: OSAndroid, URL: "https://play.google.com/store/apps/details?id=app"},
+		{Agent: AgentBot, URL: "https://example.com/landing"},
+	}
+
+	target, ok := Select(rules, Parse(iPhoneUA))
+	assert.True(t, ok)
+	assert.Equal(t, "https://apps.apple.com/app/id1", target)
+
+	target, ok = Select(rules, Parse(tabletUA))
+	assert.True(t, ok)
+	assert.Equal(t, "https://play.google.com/store/apps/details?id=app", target)
+
+	target, ok = Select(rules, Parse(googlebotUA))
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/landing", target)
+
+	_, ok = Select(rules, Parse(macUA))
+	assert.False(t, ok, "desktop falls back to the original URL")
+}
+
+func TestSelectByCountry(t *testing.T) {
+	rules := []models.TargetRule{
+		{Countries: []string{"DE", "AT"}, OS: OSiOS, URL: "https://apps.apple.com/de/app/id1"},
+		{Countries: []string{"DE", "AT"}, URL: "https://example.de"},
+	}
+	assert.True(t, NeedsCountry(rules))
+
+	client := Parse(iPhoneUA)
+	client.Country = "AT"
+	target, ok := Select(rules, client)
+	assert.True(t, ok)
+	assert.Equal(t, "https://apps.apple.com/de/app/id1", target)
+
+	client = Parse(macUA)
+	client.Country = "DE"
+	target, ok = Select(rules, client)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.de", target)
+
+	client.Country = ""
+	_, ok = Select(rules, client)
+	assert.False(t, ok, "unknown location falls back to the original URL")
+}
+
+func TestNormalize(t *testing.T) {
+	rules, err := Normalize([]models.TargetRule{{OS: " iOS ", URL: "https://apps.apple.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.TargetRule{{OS: OSiOS, URL: "https://apps.apple.com"}}, rules)
+
+	rules, err = Normalize([]models.TargetRule{{Countries: []string{"de", "DE", " at"}, URL: "https://example.de"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DE", "AT"}, rules[0].Countries)
+
+	invalid := [][]models.TargetRule{
+		{{Countries: []string{"DEU"}, URL: "https://example.de"}},
+		{{URL: "https://example.com"}},
+		{{OS: "symbian", URL: "https://example.com"}},
+		{{Device: "watch", URL: "https://example.com"}},
+		{{Agent: "robot", URL: "https://example.com"}},
+		{{OS: OSiOS}},
+	}
+	for _, rules := range invalid {
+		_, err := Normalize(rules)
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+	}
+}
@@ -0,0 +1,145 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/outbound"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxPageSize limits how much of a page is read looking for its tags.
+	maxPageSize = 512 << 10
+	// userAgent identifies the fetcher to the destinations.
+	userAgent = "Mozilla/5.0 (compatible; ShortenerPreview/1.0)"
+)
+
+// HTTPFetcher fetches the tags of a page over HTTP.
+type HTTPFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPFetcher creates a new HTTPFetcher that only fetches pages on public addresses.
+func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
+	return &HTTPFetcher{client: outbound.NewClient(timeout)}
+}
+
+// Fetch implements the Fetcher interface.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (models.OpenGraph, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return models.OpenGraph{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return models.OpenGraph{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.OpenGraph{}, fmt.Errorf("destination responded with status %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return models.OpenGraph{}, fmt.Errorf("destination is not an HTML page: %q", mediaType)
+	}
+
+	tags := Parse(io.LimitReader(resp.Body, maxPageSize))
+	if tags.Image != "" {
+		// The image may be given relative to the page, after any redirects.
+		image, err := resp.Request.URL.Parse(tags.Image)
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") {
+			tags.Image = ""
+		} else {
+			tags.Image = image.String()
+		}
+	}
+	return tags, nil
+}
+
+// Parse reads the preview tags from the head of an HTML page.
+// OpenGraph tags win over Twitter card tags, which win over the title and the description of the page.
+func Parse(r io.Reader) models.OpenGraph {
+	found := make(map[string]string)
+	var title strings.Builder
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return pickTags(found, title.String())
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return pickTags(found, title.String())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				return pickTags(found, title.String())
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					attr, value, more := tokenizer.TagAttr()
+					switch string(attr) {
+					case "property", "name":
+						key = strings.ToLower(string(value))
+					case "content":
+						content = strings.TrimSpace(string(value))
+					}
+					if !more {
+						break
+					}
+				}
+				if _, ok := found[key]; !ok && key != "" && content != "" {
+					found[key] = content
+				}
+			}
+		}
+	}
+}
+
+// pickTags returns the first tag found for each of the preview values.
+func pickTags(found map[string]string, title string) models.OpenGraph {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := found[key]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	tags := models.OpenGraph{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		Image:       first("og:image", "og:image:url", "twitter:image", "twitter:image:src"),
+	}
+	if tags.Title == "" {
+		tags.Title = strings.TrimSpace(title)
+	}
+	return tags
+}
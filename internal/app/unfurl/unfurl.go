@@ -0,0 +1,146 @@
+// Package unfurl serves link previews to the crawlers of social networks and chat apps.
+//
+// A crawler that follows a redirect shows the tags of the destination, or nothing if the
+// destination can't be fetched. Crawlers get a page with the OpenGraph and Twitter card tags
+// of the link instead: the tags set on the link, completed by the tags fetched from the
+// destination and cached.
+package unfurl
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+)
+
+// crawlers are lowercase fragments of the user agents of known link preview crawlers.
+var crawlers = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"slack-imgproxy",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"linkedinbot",
+	"skypeuripreview",
+	"pinterestbot",
+	"redditbot",
+	"embedly",
+	"vkshare",
+	"mastodon",
+	"iframely",
+	"bitlybot",
+	"viber",
+	"kakaotalk-scrap",
+	"snapchat",
+}
+
+// IsCrawler reports whether the user agent belongs to a known link preview crawler.
+func IsCrawler(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, crawler := range crawlers {
+		if strings.Contains(userAgent, crawler) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetcher returns the preview tags of a page.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (models.OpenGraph, error)
+}
+
+// cacheEntry is a fetched page, empty if the fetch failed.
+type cacheEntry struct {
+	tags    models.OpenGraph
+	expires time.Time
+}
+
+// Unfurler returns the preview tags of links and caches the tags fetched from destinations.
+// Failed fetches are cached too, so a broken destination is not fetched for every crawler.
+type Unfurler struct {
+	fetcher Fetcher
+	ttl     time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewUnfurler creates an unfurler that keeps the tags of up to size destinations for ttl.
+func NewUnfurler(fetcher Fetcher, ttl time.Duration, size int) *Unfurler {
+	return &Unfurler{
+		fetcher: fetcher,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Tags returns the tags of a link with the destination. The tags set on the link win,
+// the destination is fetched only if some of them are missing.
+func (u *Unfurler) Tags(ctx context.Context, destination string, linkTags *models.OpenGraph) models.OpenGraph {
+	var tags models.OpenGraph
+	if linkTags != nil {
+		tags = *linkTags
+	}
+	if tags.Title != "" && tags.Description != "" && tags.Image != "" {
+		return tags
+	}
+
+	fetched := u.fetch(ctx, destination)
+	if tags.Title == "" {
+		tags.Title = fetched.Title
+	}
+	if tags.Description == "" {
+		tags.Description = fetched.Description
+	}
+	if tags.Image == "" {
+		tags.Image = fetched.Image
+	}
+	return tags
+}
+
+// fetch returns the cached tags of the destination, fetching them if they are missing or expired.
+func (u *Unfurler) fetch(ctx context.Context, destination string) models.OpenGraph {
+	now := time.Now()
+
+	u.mu.Lock()
+	entry, ok := u.entries[destination]
+	u.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.tags
+	}
+
+	tags, err := u.fetcher.Fetch(ctx, destination)
+	if err != nil {
+		tags = models.OpenGraph{}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.entries) >= u.size {
+		u.evict(now)
+	}
+	u.entries[destination] = cacheEntry{tags: tags, expires: now.Add(u.ttl)}
+	return tags
+}
+
+// evict removes the expired entries, or an arbitrary one if none has expired.
+func (u *Unfurler) evict(now time.Time) {
+	for key, entry := range u.entries {
+		if !now.Before(entry.expires) {
+			delete(u.entries, key)
+		}
+	}
+	for key := range u.entries {
+		if len(u.entries) < u.size {
+			return
+		}
+		delete(u.entries, key)
+	}
+}
@@ -0,0 +1,101 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFetcher struct {
+	tags  models.OpenGraph
+	err   error
+	calls int
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, rawURL string) (models.OpenGraph, error) {
+	f.calls++
+	return f.tags, f.err
+}
+
+func TestIsCrawler(t *testing.T) {
+	assert.True(t, IsCrawler("Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"))
+	assert.True(t, IsCrawler("facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"))
+	assert.True(t, IsCrawler("Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)"))
+	assert.False(t, IsCrawler("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"))
+	assert.False(t, IsCrawler(""))
+}
+
+func TestParse(t *testing.T) {
+	tags := Parse(strings.NewReader(`<!DOCTYPE html>
+<html><head>
+	<title> Plain title </title>
+	<meta name="twitter:title" content="Twitter title">
+	<meta property="og:title" content="OpenGraph title">
+	<meta name="description" content="Page description">
+	<meta name="twitter:image" content="https://example.com/card.png">
+</head><body><meta property="og:image" content="https://example.com/ignored.png"></body></html>`))
+	assert.Equal(t, models.OpenGraph{
+		Title:       "OpenGraph title",
+		Description: "Page description",
+		Image:       "https://example.com/card.png",
+	}, tags)
+
+	assert.Equal(t, models.OpenGraph{Title: "Only a title"}, Parse(strings.NewReader(`<title>Only a title</title>`)))
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<head><meta property="og:title" content="Launch"><meta property="og:image" content="/img/launch.png"></head>`))
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := &HTTPFetcher{client: server.Client()}
+
+	tags, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OpenGraph{Title: "Launch", Image: server.URL + "/img/launch.png"}, tags)
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/data")
+	assert.Error(t, err)
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestUnfurlerTags(t *testing.T) {
+	fetcher := &fakeFetcher{tags: models.OpenGraph{Title: "Fetched", Description: "Fetched description", Image: "https://example.com/a.png"}}
+	unfurler := NewUnfurler(fetcher, time.Minute, 10)
+
+	tags := unfurler.Tags(context.Background(), "https://example.com/", &models.OpenGraph{Title: "Set on the link"})
+	assert.Equal(t, models.OpenGraph{Title: "Set on the link", Description: "Fetched description", Image: "https://example.com/a.png"}, tags)
+
+	unfurler.Tags(context.Background(), "https://example.com/", nil)
+	assert.Equal(t, 1, fetcher.calls, "fetched tags are cached")
+
+	unfurler.Tags(context.Background(), "https://example.com/other", &models.OpenGraph{Title: "T", Description: "D", Image: "I"})
+	assert.Equal(t, 1, fetcher.calls, "complete link tags are not fetched")
+
+	failing := &fakeFetcher{err: errors.New("timeout")}
+	unfurler = NewUnfurler(failing, time.Minute, 1)
+	assert.Equal(t, models.OpenGraph{}, unfurler.Tags(context.Background(), "https://example.com/", nil))
+	unfurler.Tags(context.Background(), "https://example.com/", nil)
+	assert.Equal(t, 1, failing.calls, "failed fetches are cached")
+
+	unfurler.Tags(context.Background(), "https://example.com/other", nil)
+	assert.Len(t, unfurler.entries, 1, "the cache keeps its size")
+}
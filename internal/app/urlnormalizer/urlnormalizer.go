@@ -0,0 +1,204 @@
+// Package urlnormalizer provides validation and canonicalization of URLs before they are shortened.
+package urlnormalizer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidURL is returned when a URL can't be shortened.
+var ErrInvalidURL = errors.New("invalid url")
+
+// ValidationError describes why a URL was rejected.
+type ValidationError struct {
+	URL    string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidURL, e.Reason)
+}
+
+// Unwrap allows errors.Is to match ErrInvalidURL.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidURL
+}
+
+// defaultSchemes are the schemes allowed when none are configured.
+var defaultSchemes = []string{"http", "https"}
+
+// defaultPorts maps schemes to the ports that are dropped from the canonical form.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// trackingParams are the query parameters removed when tracking stripping is enabled.
+// Parameters ending with "*" are treated as prefixes.
+var trackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"yclid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_openstat",
+}
+
+// Normalizer validates URLs and converts them to a canonical form.
+type Normalizer struct {
+	allowedSchemes []string
+	stripTracking  bool
+}
+
+// NewNormalizer creates a new Normalizer.
+// allowedSchemes is a comma separated list of schemes, http and https are allowed when it is empty.
+func NewNormalizer(allowedSchemes string, stripTracking bool) *Normalizer {
+	var schemes []string
+	for _, scheme := range strings.Split(allowedSchemes, ",") {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme != "" {
+			schemes = append(schemes, scheme)
+		}
+	}
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
+	return &Normalizer{
+		allowedSchemes: schemes,
+		stripTracking:  stripTracking,
+	}
+}
+
+// Normalize validates the raw URL and returns its canonical form.
+// The host is converted to lowercase punycode, the default port is removed
+// and, if enabled, tracking parameters are stripped from the query.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	trimmed := strings.TrimSpace(rawURL)
+	if trimmed == "" {
+		return "", &ValidationError{URL: rawURL, Reason: "url is empty"}
+	}
+	if strings.ContainsAny(trimmed, " \t\r\n") {
+		return "", &ValidationError{URL: rawURL, Reason: "url contains whitespace"}
+	}
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return "", &ValidationError{URL: rawURL, Reason: "url can't be parsed"}
+	}
+
+	if parsed.Scheme == "" {
+		return "", &ValidationError{URL: rawURL, Reason: "url must be absolute"}
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	if !n.isSchemeAllowed(scheme) {
+		return "", &ValidationError{URL: rawURL, Reason: fmt.Sprintf("scheme %q is not allowed", scheme)}
+	}
+	parsed.Scheme = scheme
+
+	if parsed.Opaque != "" || parsed.Host == "" {
+		return "", &ValidationError{URL: rawURL, Reason: "url must contain a host"}
+	}
+	if parsed.User != nil {
+		return "", &ValidationError{URL: rawURL, Reason: "credentials in url are not allowed"}
+	}
+
+	host, err := normalizeHost(scheme, parsed.Host)
+	if err != nil {
+		return "", &ValidationError{URL: rawURL, Reason: err.Error()}
+	}
+	parsed.Host = host
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	if n.stripTracking && parsed.RawQuery != "" {
+		parsed.RawQuery = stripTrackingParams(parsed.Query(), parsed.RawQuery)
+	}
+
+	return parsed.String(), nil
+}
+
+// isSchemeAllowed checks whether the scheme is in the allowed list.
+func (n *Normalizer) isSchemeAllowed(scheme string) bool {
+	for _, allowed := range n.allowedSchemes {
+		if allowed == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost converts the host to lowercase punycode and removes the default port of the scheme.
+func normalizeHost(scheme, hostPort string) (string, error) {
+	host, port := hostPort, ""
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, port = h, p
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", errors.New("url must contain a host")
+	}
+
+	host = strings.Trim(host, "[]")
+	ip := net.ParseIP(host)
+	if ip == nil {
+		asciiHost, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("host %q is not a valid domain name", host)
+		}
+		host = strings.ToLower(asciiHost)
+	} else {
+		host = ip.String()
+	}
+
+	if port != "" && port != defaultPorts[scheme] {
+		return net.JoinHostPort(host, port), nil
+	}
+	if ip != nil && ip.To4() == nil {
+		return "[" + host + "]", nil
+	}
+	return host, nil
+}
+
+// stripTrackingParams removes tracking parameters from the query.
+// The original raw query is returned untouched when nothing was removed.
+func stripTrackingParams(query url.Values, rawQuery string) string {
+	stripped := false
+	for key := range query {
+		if isTrackingParam(key) {
+			query.Del(key)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return rawQuery
+	}
+	return query.Encode()
+}
+
+// isTrackingParam reports whether the query parameter is used for tracking.
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, param := range trackingParams {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == param {
+			return true
+		}
+	}
+	return false
+}
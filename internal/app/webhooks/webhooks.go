@@ -0,0 +1,720 @@
+// Package webhooks sends the events of links to the endpoints registered by their owners.
+//
+// Events are written to an outbox in the storage, right away or, for queued events such as clicks,
+// in batches, so they survive restarts. They are retried with exponential backoff until the endpoint
+// accepts them or the attempts run out.
+// Every request carries an HMAC-SHA256 signature of the timestamp and the body made with the secret
+// of the webhook, see Sign. The outcome of every delivery is kept for Options.Retention and can be
+// listed by the owner.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/models"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/outbound"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"go.uber.org/zap"
+)
+
+// Event types.
+const (
+	// EventCreated is sent when a link is created.
+	EventCreated = "link.created"
+	// EventUpdated is sent when the tags or the variants of a link change.
+	EventUpdated = "link.updated"
+	// EventDeleted is sent when a deleted link is removed in the storage, which happens in batches.
+	EventDeleted = "link.deleted"
+	// EventClicked is sent for every served redirect.
+	EventClicked = "link.clicked"
+	// EventBroken is sent when the health check finds that the destination of a link stopped working.
+	EventBroken = "link.broken"
+)
+
+// Events are the event types a webhook can subscribe to.
+var Events = []string{EventCreated, EventUpdated, EventDeleted, EventClicked, EventBroken}
+
+// Headers of the requests sent to the endpoints.
+const (
+	// HeaderEvent is the type of the event.
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery is the ID of the delivery, the same for every attempt.
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderTimestamp is the Unix time the request was signed at.
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature is "sha256=" followed by the signature, see Sign.
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// maxWebhooks is the number of webhooks a user can register.
+	maxWebhooks = 10
+	// maxURLLength limits the URL of an endpoint.
+	maxURLLength = 2048
+	// requestTimeout limits a single attempt.
+	requestTimeout = 10 * time.Second
+	// maxResponseRead is how much of a response is read before the connection is dropped.
+	maxResponseRead = 64 << 10
+	// claimSize is the number of deliveries sent at once.
+	claimSize = 100
+	// claimLease is how long claimed deliveries are hidden from other instances.
+	// It covers sending a whole claim, after it the deliveries of a crashed instance are sent again.
+	claimLease = 5 * time.Minute
+	// firstRetry and maxRetry bound the delay between two attempts.
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+	// bufferSize is the number of queued events waiting for the outbox before Queue writes them itself.
+	bufferSize = 4096
+	// enqueueSize is the number of queued events written to the outbox at once.
+	enqueueSize = 100
+	// enqueueInterval is how often queued events are written to the outbox when there are fewer than enqueueSize of them.
+	enqueueInterval = time.Second
+	// cacheTTL is how long the webhooks of a user are cached.
+	// Webhooks registered on another instance start receiving events after it.
+	cacheTTL = time.Minute
+	// cacheSize is the number of users whose webhooks are cached.
+	cacheSize = 10000
+	// deliveryLogSize is the number of deliveries listed per webhook.
+	deliveryLogSize = 100
+	// pruneInterval is how often old deliveries are removed.
+	pruneInterval = time.Hour
+	// userAgent identifies the dispatcher to the endpoints.
+	userAgent = "ShortenerWebhooks/1.0"
+)
+
+// ErrInvalidWebhook is returned for a webhook with an invalid URL or unknown event types.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrTooManyWebhooks is returned when a user registers more than maxWebhooks webhooks.
+var ErrTooManyWebhooks = errors.New("too many webhooks")
+
+// Event is the body of the requests sent to the endpoints.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Link      Link      `json:"link"`
+	// Changes lists what changed in an update, "tags" or "variants".
+	Changes []string `json:"changes,omitempty"`
+	// Variant is the A/B variant a click was sent to.
+	Variant string `json:"variant,omitempty"`
+	// Health is the result of the check that found a link broken.
+	Health *models.LinkHealth `json:"health,omitempty"`
+}
+
+// Link is the link an event is about.
+type Link struct {
+	ShortURL    string   `json:"short_url"`
+	OriginalURL string   `json:"original_url,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Options configures a Dispatcher.
+type Options struct {
+	// Client sends the requests, a client that only reaches public addresses if nil.
+	// Redirects are never followed, so a redirect fails the attempt.
+	Client *http.Client
+	// MaxAttempts is the number of attempts before a delivery is given up, 8 if zero.
+	MaxAttempts int
+	// Concurrency is the number of deliveries sent at once, 4 if zero.
+	Concurrency int
+	// PollInterval is how often the outbox is checked for due deliveries, a second if zero.
+	PollInterval time.Duration
+	// Retention is how long finished deliveries are kept, a week if zero.
+	Retention time.Duration
+	// Logger reports failures of the outbox.
+	Logger *zap.Logger
+}
+
+// Dispatcher manages the webhooks of the users and sends them the events of their links.
+type Dispatcher struct {
+	storage storage.Storage
+	options Options
+	events  chan queuedEvent
+	wake    chan struct{}
+
+	mu    sync.Mutex
+	cache map[string]cachedWebhooks
+}
+
+// queuedEvent is an event waiting to be written to the outbox.
+type queuedEvent struct {
+	userID string
+	event  Event
+}
+
+// cachedWebhooks are the webhooks of a user.
+type cachedWebhooks struct {
+	webhooks []storage.Webhook
+	expires  time.Time
+}
+
+// NewDispatcher creates a dispatcher that keeps the webhooks and the outbox in the storage.
+// Emitted events are written to the outbox and sent once Run is started, queued events are written by Run.
+func NewDispatcher(store storage.Storage, options Options) *Dispatcher {
+	if options.Client == nil {
+		options.Client = outbound.NewClient(requestTimeout)
+	}
+	client := *options.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	options.Client = &client
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 8
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 4
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.Retention <= 0 {
+		options.Retention = 7 * 24 * time.Hour
+	}
+	return &Dispatcher{
+		storage: store,
+		options: options,
+		events:  make(chan queuedEvent, bufferSize),
+		wake:    make(chan struct{}, 1),
+		cache:   make(map[string]cachedWebhooks),
+	}
+}
+
+// Register validates and saves a webhook of the user. No events means every event type.
+// The returned webhook holds the secret the deliveries are signed with.
+func (d *Dispatcher) Register(ctx context.Context, userID, rawURL string, events []string) (storage.Webhook, error) {
+	if err := validateURL(rawURL); err != nil {
+		return storage.Webhook{}, err
+	}
+	events, err := normalizeEvents(events)
+	if err != nil {
+		return storage.Webhook{}, err
+	}
+
+	existing, err := d.storage.GetWebhooks(ctx, userID)
+	if err != nil {
+		return storage.Webhook{}, err
+	}
+	if len(existing) >= maxWebhooks {
+		return storage.Webhook{}, fmt.Errorf("%w: a user can register up to %d", ErrTooManyWebhooks, maxWebhooks)
+	}
+
+	webhook := storage.Webhook{
+		ID:        newID(),
+		UserID:    userID,
+		URL:       rawURL,
+		Secret:    "whsec_" + newID() + newID(),
+		Events:    events,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := d.storage.SaveWebhook(ctx, webhook); err != nil {
+		return storage.Webhook{}, err
+	}
+	d.forget(userID)
+	return webhook, nil
+}
+
+// validateURL checks that the endpoint is an absolute HTTP or HTTPS URL.
+// Private addresses are rejected when the request is sent, after the host is resolved.
+func validateURL(rawURL string) error {
+	if len(rawURL) > maxURLLength {
+		return fmt.Errorf("%w: the url is longer than %d characters", ErrInvalidWebhook, maxURLLength)
+	}
+	endpoint, err := url.Parse(rawURL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("%w: expected an absolute http or https url", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// normalizeEvents checks the event types and removes duplicates. No events means every event type.
+func normalizeEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return append([]string(nil), Events...), nil
+	}
+	seen := make(map[string]bool, len(events))
+	var normalized []string
+	for _, event := range events {
+		if !isEvent(event) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+// isEvent reports whether the event type is known.
+func isEvent(event string) bool {
+	for _, known := range Events {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the webhooks of the user.
+func (d *Dispatcher) List(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	return d.storage.GetWebhooks(ctx, userID)
+}
+
+// Delete removes a webhook of the user and its deliveries.
+// It returns storage.ErrWebhookNotFound if the user has no such webhook.
+func (d *Dispatcher) Delete(ctx context.Context, userID, id string) error {
+	if err := d.storage.DeleteWebhook(ctx, userID, id); err != nil {
+		return err
+	}
+	d.forget(userID)
+	return nil
+}
+
+// Deliveries returns the latest deliveries of a webhook of the user, newest first.
+// It returns storage.ErrWebhookNotFound if the user has no such webhook.
+func (d *Dispatcher) Deliveries(ctx context.Context, userID, id string) ([]storage.Delivery, error) {
+	webhooks, err := d.storage.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findWebhook(webhooks, id); !ok {
+		return nil, storage.ErrWebhookNotFound
+	}
+	return d.storage.GetDeliveries(ctx, userID, id, deliveryLogSize)
+}
+
+// Subscribed reports whether a webhook of the user receives the event type,
+// so callers can skip preparing events nobody receives. A nil dispatcher has no webhooks.
+func (d *Dispatcher) Subscribed(ctx context.Context, userID, eventType string) bool {
+	if d == nil {
+		return false
+	}
+	webhooks, err := d.webhooks(ctx, userID)
+	if err != nil {
+		// The event is prepared anyway, the outbox reports the failure if it persists.
+		return true
+	}
+	for _, webhook := range webhooks {
+		if subscribes(webhook, eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit writes the events of links of the user to the outbox before it returns, so an event is either
+// stored or its error is returned and logged. The write isn't interrupted when ctx is canceled, so the
+// events of a request survive the client going away. The ID and the time of the events are set here.
+// A nil dispatcher does nothing.
+func (d *Dispatcher) Emit(ctx context.Context, userID string, events ...Event) error {
+	if d == nil || len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		stamp(&events[i])
+	}
+	deliveries, err := d.deliveries(context.WithoutCancel(ctx), userID, events)
+	if err == nil {
+		err = d.store(context.WithoutCancel(ctx), deliveries)
+	}
+	if err != nil {
+		d.logf("failed to queue %d webhook events: %v", len(events), err)
+	}
+	return err
+}
+
+// Queue hands the event of a link of the user to Run, which writes it to the outbox with others,
+// so hot paths such as redirects don't wait for the storage. When the buffer is full the event is
+// written by Queue itself like Emit does. The ID and the time of the event are set here.
+// A nil dispatcher does nothing.
+func (d *Dispatcher) Queue(userID string, event Event) {
+	if d == nil {
+		return
+	}
+	stamp(&event)
+	select {
+	case d.events <- queuedEvent{userID: userID, event: event}:
+	default:
+		d.Emit(context.Background(), userID, event)
+	}
+}
+
+// stamp sets the ID and the time of a new event.
+func stamp(event *Event) {
+	if event.ID == "" {
+		event.ID = newID()
+		event.CreatedAt = time.Now().UTC()
+	}
+}
+
+// Run writes the queued events to the outbox and sends the due deliveries until the context is done.
+// The queued events are written to the outbox before it returns, deliveries that were not sent stay
+// in the outbox for the next start. The returned WaitGroup is done when both have stopped.
+// A nil dispatcher does nothing.
+func (d *Dispatcher) Run(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if d == nil {
+		return &wg
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.runOutbox(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		d.runDeliveries(ctx)
+	}()
+	return &wg
+}
+
+// runOutbox writes the queued events to the outbox in batches.
+// A batch that can't be written is retried on the next tick and no more events are taken meanwhile,
+// so Queue writes them itself once the buffer is full.
+func (d *Dispatcher) runOutbox(ctx context.Context) {
+	ticker := time.NewTicker(enqueueInterval)
+	defer ticker.Stop()
+
+	batch := make([]queuedEvent, 0, enqueueSize)
+	for {
+		events := d.events
+		if len(batch) >= enqueueSize {
+			events = nil
+		}
+		select {
+		case event := <-events:
+			batch = append(batch, event)
+			if len(batch) >= enqueueSize {
+				batch = d.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = d.flush(ctx, batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-d.events:
+					batch = append(batch, event)
+				default:
+					if batch = d.flush(context.Background(), batch); len(batch) > 0 {
+						d.logf("lost %d webhook events on shutdown", len(batch))
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch to the outbox. It returns the batch emptied, or unchanged if it can't be written.
+func (d *Dispatcher) flush(ctx context.Context, batch []queuedEvent) []queuedEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+	var deliveries []storage.Delivery
+	for _, queued := range batch {
+		userDeliveries, err := d.deliveries(ctx, queued.userID, []Event{queued.event})
+		if err != nil {
+			d.logf("failed to queue %d webhook events: %v", len(batch), err)
+			return batch
+		}
+		deliveries = append(deliveries, userDeliveries...)
+	}
+	if err := d.store(ctx, deliveries); err != nil {
+		d.logf("failed to queue %d webhook events: %v", len(batch), err)
+		return batch
+	}
+	return batch[:0]
+}
+
+// store adds the deliveries to the outbox and wakes up the sender.
+func (d *Dispatcher) store(ctx context.Context, deliveries []storage.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := d.storage.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// deliveries returns a delivery for every webhook of the user that receives one of the events.
+func (d *Dispatcher) deliveries(ctx context.Context, userID string, events []Event) ([]storage.Delivery, error) {
+	webhooks, err := d.webhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []storage.Delivery
+	for _, event := range events {
+		var payload []byte
+		for _, webhook := range webhooks {
+			if !subscribes(webhook, event.Type) {
+				continue
+			}
+			if payload == nil {
+				if payload, err = json.Marshal(event); err != nil {
+					return nil, err
+				}
+			}
+			deliveries = append(deliveries, storage.Delivery{
+				ID:          newID(),
+				WebhookID:   webhook.ID,
+				UserID:      userID,
+				Event:       event.Type,
+				Payload:     payload,
+				Status:      storage.DeliveryPending,
+				NextAttempt: event.CreatedAt,
+				CreatedAt:   event.CreatedAt,
+			})
+		}
+	}
+	return deliveries, nil
+}
+
+// runDeliveries sends the due deliveries every PollInterval, or as soon as new ones are emitted,
+// and removes the old ones every pruneInterval.
+func (d *Dispatcher) runDeliveries(ctx context.Context) {
+	poll := time.NewTicker(d.options.PollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-d.wake:
+		case <-prune.C:
+			if _, err := d.storage.PruneDeliveries(ctx, time.Now().Add(-d.options.Retention)); err != nil && ctx.Err() == nil {
+				d.logf("failed to prune webhook deliveries: %v", err)
+			}
+			continue
+		}
+		if err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			d.logf("failed to send webhook deliveries: %v", err)
+		}
+	}
+}
+
+// DeliverDue claims the due deliveries, sends them and records the outcomes.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	deliveries, err := d.storage.ClaimDeliveries(ctx, time.Now().UTC(), claimLease, claimSize)
+	if err != nil {
+		return err
+	}
+
+	var saveErr error
+	var saveMu sync.Mutex
+	sem := make(chan struct{}, d.options.Concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery storage.Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			delivery, ok := d.attempt(ctx, delivery)
+			if !ok {
+				// Interrupted by shutdown: the delivery is sent again once the lease expires.
+				return
+			}
+			if err := d.storage.UpdateDelivery(ctx, delivery); err != nil {
+				saveMu.Lock()
+				saveErr = errors.Join(saveErr, err)
+				saveMu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return saveErr
+}
+
+// attempt sends the delivery once and returns it with the outcome.
+// It reports false if the attempt was interrupted because the context is done.
+func (d *Dispatcher) attempt(ctx context.Context, delivery storage.Delivery) (storage.Delivery, bool) {
+	webhooks, err := d.webhooks(ctx, delivery.UserID)
+	if err != nil {
+		return delivery, false
+	}
+	webhook, ok := findWebhook(webhooks, delivery.WebhookID)
+	if !ok {
+		delivery.Status = storage.DeliveryFailed
+		delivery.Error = "webhook was deleted"
+		return delivery, true
+	}
+
+	status, err := d.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		return delivery, false
+	}
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.Error = ""
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case status < http.StatusOK || status >= http.StatusMultipleChoices:
+		delivery.Error = fmt.Sprintf("endpoint responded with status %d", status)
+	}
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = storage.DeliveryDelivered
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.options.MaxAttempts:
+		delivery.Status = storage.DeliveryFailed
+	default:
+		delivery.NextAttempt = now.Add(backoff(delivery.Attempts))
+	}
+	return delivery, true
+}
+
+// send posts the payload of the delivery to the endpoint and returns the status of the response.
+func (d *Dispatcher) send(ctx context.Context, webhook storage.Webhook, delivery storage.Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.options.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseRead))
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret.
+// Receivers compute it the same way and reject requests with an old timestamp, so requests can't be replayed.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay after the attempt: firstRetry doubled for every earlier attempt, up to maxRetry.
+func backoff(attempt int) time.Duration {
+	delay := firstRetry
+	for i := 1; i < attempt && delay < maxRetry; i++ {
+		delay *= 2
+	}
+	if delay > maxRetry {
+		delay = maxRetry
+	}
+	return delay
+}
+
+// webhooks returns the cached webhooks of the user, loading them if they are missing or expired.
+func (d *Dispatcher) webhooks(ctx context.Context, userID string) ([]storage.Webhook, error) {
+	now := time.Now()
+
+	d.mu.Lock()
+	entry, ok := d.cache[userID]
+	d.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.webhooks, nil
+	}
+
+	webhooks, err := d.storage.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.cache) >= cacheSize {
+		d.evict(now)
+	}
+	d.cache[userID] = cachedWebhooks{webhooks: webhooks, expires: now.Add(cacheTTL)}
+	return webhooks, nil
+}
+
+// forget drops the cached webhooks of the user after they changed.
+func (d *Dispatcher) forget(userID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.cache, userID)
+}
+
+// evict removes the expired entries, or arbitrary ones if none has expired.
+// The caller must hold the lock.
+func (d *Dispatcher) evict(now time.Time) {
+	for userID, entry := range d.cache {
+		if !now.Before(entry.expires) {
+			delete(d.cache, userID)
+		}
+	}
+	for userID := range d.cache {
+		if len(d.cache) < cacheSize {
+			return
+		}
+		delete(d.cache, userID)
+	}
+}
+
+// logf reports a failure if the dispatcher has a logger.
+func (d *Dispatcher) logf(template string, args ...any) {
+	if d.options.Logger != nil {
+		d.options.Logger.Sugar().Errorf(template, args...)
+	}
+}
+
+// subscribes reports whether the webhook receives the event type.
+func subscribes(webhook storage.Webhook, eventType string) bool {
+	for _, event := range webhook.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// findWebhook returns the webhook with the ID.
+func findWebhook(webhooks []storage.Webhook, id string) (storage.Webhook, bool) {
+	for _, webhook := range webhooks {
+		if webhook.ID == id {
+			return webhook, true
+		}
+	}
+	return storage.Webhook{}, false
+}
+
+// newID returns a random ID of 32 hex characters.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
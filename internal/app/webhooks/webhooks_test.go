@@ -0,0 +1,233 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage"
+	"github.com/JustWorking42/shortener-go-yandex/internal/app/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// endpoint records the requests it receives and responds with the queued statuses, then with 204.
+type endpoint struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	status := http.StatusNoContent
+	if len(e.statuses) > 0 {
+		status, e.statuses = e.statuses[0], e.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newDispatcher(t *testing.T, server *httptest.Server, options Options) (*Dispatcher, *memory.MemoryStorage) {
+	store := &memory.MemoryStorage{}
+	assert.NoError(t, store.Init(context.Background()))
+	options.Client = server.Client()
+	return NewDispatcher(store, options), store
+}
+
+func TestRegister(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	dispatcher, _ := newDispatcher(t, server, Options{})
+	ctx := context.Background()
+
+	webhook, err := dispatcher.Register(ctx, "alice", "https://crm.example.com/hooks", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Events, webhook.Events, "no events means every event")
+	assert.NotEmpty(t, webhook.Secret)
+
+	webhook, err = dispatcher.Register(ctx, "alice", "https://crm.example.com/clicks", []string{EventClicked, EventClicked})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{EventClicked}, webhook.Events)
+
+	_, err = dispatcher.Register(ctx, "alice", "ftp://crm.example.com/", nil)
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = dispatcher.Register(ctx, "alice", "https://crm.example.com/", []string{"link.renamed"})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+
+	webhooks, err := dispatcher.List(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 2)
+
+	assert.ErrorIs(t, dispatcher.Delete(ctx, "bob", webhook.ID), storage.ErrWebhookNotFound)
+	assert.NoError(t, dispatcher.Delete(ctx, "alice", webhook.ID))
+	_, err = dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.ErrorIs(t, err, storage.ErrWebhookNotFound)
+}
+
+func TestDeliverDue(t *testing.T) {
+	receiver := &endpoint{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	dispatcher, store := newDispatcher(t, server, Options{MaxAttempts: 2})
+	ctx := context.Background()
+
+	webhook, err := dispatcher.Register(ctx, "alice", server.URL+"/hooks", []string{EventCreated})
+	assert.NoError(t, err)
+	assert.True(t, dispatcher.Subscribed(ctx, "alice", EventCreated))
+	assert.False(t, dispatcher.Subscribed(ctx, "alice", EventClicked))
+	assert.False(t, dispatcher.Subscribed(ctx, "bob", EventCreated))
+
+	assert.NoError(t, dispatcher.Emit(ctx, "alice",
+		Event{Type: EventCreated, Link: Link{ShortURL: "http://localhost:8080/abc", OriginalURL: "https://example.com/"}},
+		Event{Type: EventClicked, Link: Link{ShortURL: "http://localhost:8080/abc"}}))
+	assert.NoError(t, dispatcher.Emit(ctx, "bob", Event{Type: EventCreated, Link: Link{ShortURL: "http://localhost:8080/xyz"}}))
+
+	// The first attempt fails and the delivery waits for a retry.
+	assert.NoError(t, dispatcher.DeliverDue(ctx))
+	deliveries, err := dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, deliveries, 1, "only subscribed events of the owner are queued") {
+		return
+	}
+	failed := deliveries[0]
+	assert.Equal(t, storage.DeliveryPending, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, failed.ResponseStatus)
+	assert.WithinDuration(t, time.Now().Add(firstRetry), failed.NextAttempt, 5*time.Second)
+
+	assert.NoError(t, dispatcher.DeliverDue(ctx))
+	assert.Len(t, receiver.requests, 1, "the delivery is not sent before its retry is due")
+
+	failed.NextAttempt = time.Now()
+	assert.NoError(t, store.UpdateDelivery(ctx, failed))
+	assert.NoError(t, dispatcher.DeliverDue(ctx))
+
+	deliveries, err = dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	if assert.Len(t, receiver.requests, 2) {
+		request, body := receiver.requests[1], receiver.bodies[1]
+		assert.Equal(t, EventCreated, request.Header.Get(HeaderEvent))
+		assert.Equal(t, failed.ID, request.Header.Get(HeaderDelivery), "retries keep the delivery ID")
+		timestamp, err := strconv.ParseInt(request.Header.Get(HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256="+Sign(webhook.Secret, timestamp, body), request.Header.Get(HeaderSignature))
+
+		var event Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, EventCreated, event.Type)
+		assert.Equal(t, "https://example.com/", event.Link.OriginalURL)
+		assert.NotEmpty(t, event.ID)
+	}
+}
+
+func TestDeliverDueGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer server.Close()
+
+	dispatcher, store := newDispatcher(t, server, Options{MaxAttempts: 1})
+	ctx := context.Background()
+
+	webhook, err := dispatcher.Register(ctx, "alice", server.URL, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, dispatcher.Emit(ctx, "alice", Event{Type: EventDeleted, Link: Link{ShortURL: "http://localhost:8080/abc"}}))
+	assert.NoError(t, dispatcher.DeliverDue(ctx))
+
+	deliveries, err := dispatcher.Deliveries(ctx, "alice", webhook.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, storage.DeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, http.StatusFound, deliveries[0].ResponseStatus, "redirects are not followed")
+	}
+
+	removed, err := store.PruneDeliveries(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
+
+func TestEmitWritesOutbox(t *testing.T) {
+	receiver := &endpoint{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	dispatcher, _ := newDispatcher(t, server, Options{PollInterval: time.Hour})
+	webhook, err := dispatcher.Register(context.Background(), "alice", server.URL, nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, dispatcher.Emit(ctx, "alice", Event{Type: EventClicked, Link: Link{ShortURL: "http://localhost:8080/abc"}}))
+
+	deliveries, err := dispatcher.Deliveries(context.Background(), "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1, "the event is in the outbox when Emit returns, even after the request is canceled")
+}
+
+func TestQueue(t *testing.T) {
+	receiver := &endpoint{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	dispatcher, _ := newDispatcher(t, server, Options{PollInterval: time.Hour})
+	webhook, err := dispatcher.Register(context.Background(), "alice", server.URL, nil)
+	assert.NoError(t, err)
+
+	dispatcher.Queue("alice", Event{Type: EventClicked, Link: Link{ShortURL: "http://localhost:8080/abc"}})
+	deliveries, err := dispatcher.Deliveries(context.Background(), "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries, "queued events wait for Run")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dispatcher.Run(ctx).Wait()
+
+	deliveries, err = dispatcher.Deliveries(context.Background(), "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1, "queued events reach the outbox before Run returns")
+}
+
+func TestQueueWritesWhenFull(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	dispatcher, _ := newDispatcher(t, server, Options{})
+	webhook, err := dispatcher.Register(context.Background(), "alice", server.URL, nil)
+	assert.NoError(t, err)
+
+	for i := 0; i < bufferSize+1; i++ {
+		dispatcher.Queue("alice", Event{Type: EventClicked, Link: Link{ShortURL: "http://localhost:8080/abc"}})
+	}
+	deliveries, err := dispatcher.Deliveries(context.Background(), "alice", webhook.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1, "the event that doesn't fit into the buffer is written right away")
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, firstRetry, backoff(1))
+	assert.Equal(t, 4*firstRetry, backoff(3))
+	assert.Equal(t, maxRetry, backoff(100))
+}
+
+func TestEmitOnNil(t *testing.T) {
+	var dispatcher *Dispatcher
+	assert.NoError(t, dispatcher.Emit(context.Background(), "alice", Event{Type: EventClicked}))
+	dispatcher.Queue("alice", Event{Type: EventClicked})
+	assert.False(t, dispatcher.Subscribed(context.Background(), "alice", EventClicked))
+}
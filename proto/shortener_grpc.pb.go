@@ -20,13 +20,20 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	ShortenerService_ShortUrl_FullMethodName       = "/proto.ShortenerService/ShortUrl"
-	ShortenerService_ShortUrlsBatch_FullMethodName = "/proto.ShortenerService/ShortUrlsBatch"
-	ShortenerService_GetURL_FullMethodName         = "/proto.ShortenerService/GetURL"
-	ShortenerService_GetUserURLs_FullMethodName    = "/proto.ShortenerService/GetUserURLs"
-	ShortenerService_DeleteURLs_FullMethodName     = "/proto.ShortenerService/DeleteURLs"
-	ShortenerService_GetStats_FullMethodName       = "/proto.ShortenerService/GetStats"
-	ShortenerService_PingDB_FullMethodName         = "/proto.ShortenerService/PingDB"
+	ShortenerService_ShortUrl_FullMethodName             = "/proto.ShortenerService/ShortUrl"
+	ShortenerService_ShortUrlsBatch_FullMethodName       = "/proto.ShortenerService/ShortUrlsBatch"
+	ShortenerService_GetURL_FullMethodName               = "/proto.ShortenerService/GetURL"
+	ShortenerService_GetUserURLs_FullMethodName          = "/proto.ShortenerService/GetUserURLs"
+	ShortenerService_GetLinkStats_FullMethodName         = "/proto.ShortenerService/GetLinkStats"
+	ShortenerService_GetUserTags_FullMethodName          = "/proto.ShortenerService/GetUserTags"
+	ShortenerService_UpdateTags_FullMethodName           = "/proto.ShortenerService/UpdateTags"
+	ShortenerService_UpdateVariantWeights_FullMethodName = "/proto.ShortenerService/UpdateVariantWeights"
+	ShortenerService_DeleteURLs_FullMethodName           = "/proto.ShortenerService/DeleteURLs"
+	ShortenerService_GetStats_FullMethodName             = "/proto.ShortenerService/GetStats"
+	ShortenerService_PingDB_FullMethodName               = "/proto.ShortenerService/PingDB"
+	ShortenerService_ImportURLs_FullMethodName           = "/proto.ShortenerService/ImportURLs"
+	ShortenerService_ExportUserURLs_FullMethodName       = "/proto.ShortenerService/ExportUserURLs"
+	ShortenerService_WatchClicks_FullMethodName          = "/proto.ShortenerService/WatchClicks"
 )
 
 // ShortenerServiceClient is the client API for ShortenerService service.
@@ -36,10 +43,17 @@ type ShortenerServiceClient interface {
 	ShortUrl(ctx context.Context, in *ShortenURLRequest, opts ...grpc.CallOption) (*ShortenURLResponse, error)
 	ShortUrlsBatch(ctx context.Context, in *ShortenURLsBatchRequest, opts ...grpc.CallOption) (*ShortenURLsBatchResponse, error)
 	GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error)
-	GetUserURLs(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetUserURLsResponse, error)
+	GetUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (*GetUserURLsResponse, error)
+	GetLinkStats(ctx context.Context, in *GetLinkStatsRequest, opts ...grpc.CallOption) (*LinkStats, error)
+	GetUserTags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetUserTagsResponse, error)
+	UpdateTags(ctx context.Context, in *UpdateTagsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UpdateVariantWeights(ctx context.Context, in *UpdateVariantWeightsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	GetStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetStatsResponse, error)
 	PingDB(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ImportURLs(ctx context.Context, opts ...grpc.CallOption) (ShortenerService_ImportURLsClient, error)
+	ExportUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (ShortenerService_ExportUserURLsClient, error)
+	WatchClicks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (ShortenerService_WatchClicksClient, error)
 }
 
 type shortenerServiceClient struct {
@@ -77,7 +91,7 @@ func (c *shortenerServiceClient) GetURL(ctx context.Context, in *GetURLRequest,
 	return out, nil
 }
 
-func (c *shortenerServiceClient) GetUserURLs(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetUserURLsResponse, error) {
+func (c *shortenerServiceClient) GetUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (*GetUserURLsResponse, error) {
 	out := new(GetUserURLsResponse)
 	err := c.cc.Invoke(ctx, ShortenerService_GetUserURLs_FullMethodName, in, out, opts...)
 	if err != nil {
@@ -86,6 +100,42 @@ func (c *shortenerServiceClient) GetUserURLs(ctx context.Context, in *emptypb.Em
 	return out, nil
 }
 
+func (c *shortenerServiceClient) GetLinkStats(ctx context.Context, in *GetLinkStatsRequest, opts ...grpc.CallOption) (*LinkStats, error) {
+	out := new(LinkStats)
+	err := c.cc.Invoke(ctx, ShortenerService_GetLinkStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerServiceClient) GetUserTags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetUserTagsResponse, error) {
+	out := new(GetUserTagsResponse)
+	err := c.cc.Invoke(ctx, ShortenerService_GetUserTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerServiceClient) UpdateTags(ctx context.Context, in *UpdateTagsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ShortenerService_UpdateTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerServiceClient) UpdateVariantWeights(ctx context.Context, in *UpdateVariantWeightsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ShortenerService_UpdateVariantWeights_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *shortenerServiceClient) DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, ShortenerService_DeleteURLs_FullMethodName, in, out, opts...)
@@ -113,6 +163,104 @@ func (c *shortenerServiceClient) PingDB(ctx context.Context, in *emptypb.Empty,
 	return out, nil
 }
 
+func (c *shortenerServiceClient) ImportURLs(ctx context.Context, opts ...grpc.CallOption) (ShortenerService_ImportURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ShortenerService_ServiceDesc.Streams[0], ShortenerService_ImportURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shortenerServiceImportURLsClient{stream}
+	return x, nil
+}
+
+type ShortenerService_ImportURLsClient interface {
+	Send(*ImportURLRecord) error
+	CloseAndRecv() (*ImportJob, error)
+	grpc.ClientStream
+}
+
+type shortenerServiceImportURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *shortenerServiceImportURLsClient) Send(m *ImportURLRecord) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *shortenerServiceImportURLsClient) CloseAndRecv() (*ImportJob, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportJob)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *shortenerServiceClient) ExportUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (ShortenerService_ExportUserURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ShortenerService_ServiceDesc.Streams[1], ShortenerService_ExportUserURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shortenerServiceExportUserURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ShortenerService_ExportUserURLsClient interface {
+	Recv() (*GetUserURLs, error)
+	grpc.ClientStream
+}
+
+type shortenerServiceExportUserURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *shortenerServiceExportUserURLsClient) Recv() (*GetUserURLs, error) {
+	m := new(GetUserURLs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *shortenerServiceClient) WatchClicks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (ShortenerService_WatchClicksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ShortenerService_ServiceDesc.Streams[2], ShortenerService_WatchClicks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shortenerServiceWatchClicksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ShortenerService_WatchClicksClient interface {
+	Recv() (*ClickEvent, error)
+	grpc.ClientStream
+}
+
+type shortenerServiceWatchClicksClient struct {
+	grpc.ClientStream
+}
+
+func (x *shortenerServiceWatchClicksClient) Recv() (*ClickEvent, error) {
+	m := new(ClickEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ShortenerServiceServer is the server API for ShortenerService service.
 // All implementations must embed UnimplementedShortenerServiceServer
 // for forward compatibility
@@ -120,10 +268,17 @@ type ShortenerServiceServer interface {
 	ShortUrl(context.Context, *ShortenURLRequest) (*ShortenURLResponse, error)
 	ShortUrlsBatch(context.Context, *ShortenURLsBatchRequest) (*ShortenURLsBatchResponse, error)
 	GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error)
-	GetUserURLs(context.Context, *emptypb.Empty) (*GetUserURLsResponse, error)
+	GetUserURLs(context.Context, *GetUserURLsRequest) (*GetUserURLsResponse, error)
+	GetLinkStats(context.Context, *GetLinkStatsRequest) (*LinkStats, error)
+	GetUserTags(context.Context, *emptypb.Empty) (*GetUserTagsResponse, error)
+	UpdateTags(context.Context, *UpdateTagsRequest) (*emptypb.Empty, error)
+	UpdateVariantWeights(context.Context, *UpdateVariantWeightsRequest) (*emptypb.Empty, error)
 	DeleteURLs(context.Context, *DeleteURLsRequest) (*emptypb.Empty, error)
 	GetStats(context.Context, *emptypb.Empty) (*GetStatsResponse, error)
 	PingDB(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	ImportURLs(ShortenerService_ImportURLsServer) error
+	ExportUserURLs(*GetUserURLsRequest, ShortenerService_ExportUserURLsServer) error
+	WatchClicks(*emptypb.Empty, ShortenerService_WatchClicksServer) error
 	mustEmbedUnimplementedShortenerServiceServer()
 }
 
@@ -140,9 +295,21 @@ func (UnimplementedShortenerServiceServer) ShortUrlsBatch(context.Context, *Shor
 func (UnimplementedShortenerServiceServer) GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetURL not implemented")
 }
-func (UnimplementedShortenerServiceServer) GetUserURLs(context.Context, *emptypb.Empty) (*GetUserURLsResponse, error) {
+func (UnimplementedShortenerServiceServer) GetUserURLs(context.Context, *GetUserURLsRequest) (*GetUserURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserURLs not implemented")
 }
+func (UnimplementedShortenerServiceServer) GetLinkStats(context.Context, *GetLinkStatsRequest) (*LinkStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLinkStats not implemented")
+}
+func (UnimplementedShortenerServiceServer) GetUserTags(context.Context, *emptypb.Empty) (*GetUserTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserTags not implemented")
+}
+func (UnimplementedShortenerServiceServer) UpdateTags(context.Context, *UpdateTagsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateTags not implemented")
+}
+func (UnimplementedShortenerServiceServer) UpdateVariantWeights(context.Context, *UpdateVariantWeightsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateVariantWeights not implemented")
+}
 func (UnimplementedShortenerServiceServer) DeleteURLs(context.Context, *DeleteURLsRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteURLs not implemented")
 }
@@ -152,6 +319,15 @@ func (UnimplementedShortenerServiceServer) GetStats(context.Context, *emptypb.Em
 func (UnimplementedShortenerServiceServer) PingDB(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PingDB not implemented")
 }
+func (UnimplementedShortenerServiceServer) ImportURLs(ShortenerService_ImportURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportURLs not implemented")
+}
+func (UnimplementedShortenerServiceServer) ExportUserURLs(*GetUserURLsRequest, ShortenerService_ExportUserURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportUserURLs not implemented")
+}
+func (UnimplementedShortenerServiceServer) WatchClicks(*emptypb.Empty, ShortenerService_WatchClicksServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchClicks not implemented")
+}
 func (UnimplementedShortenerServiceServer) mustEmbedUnimplementedShortenerServiceServer() {}
 
 // UnsafeShortenerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -220,7 +396,7 @@ func _ShortenerService_GetURL_Handler(srv interface{}, ctx context.Context, dec
 }
 
 func _ShortenerService_GetUserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(emptypb.Empty)
+	in := new(GetUserURLsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -232,7 +408,79 @@ func _ShortenerService_GetUserURLs_Handler(srv interface{}, ctx context.Context,
 		FullMethod: ShortenerService_GetUserURLs_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ShortenerServiceServer).GetUserURLs(ctx, req.(*emptypb.Empty))
+		return srv.(ShortenerServiceServer).GetUserURLs(ctx, req.(*GetUserURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerService_GetLinkStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLinkStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServiceServer).GetLinkStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerService_GetLinkStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServiceServer).GetLinkStats(ctx, req.(*GetLinkStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerService_GetUserTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServiceServer).GetUserTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerService_GetUserTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServiceServer).GetUserTags(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerService_UpdateTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServiceServer).UpdateTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerService_UpdateTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServiceServer).UpdateTags(ctx, req.(*UpdateTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerService_UpdateVariantWeights_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateVariantWeightsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServiceServer).UpdateVariantWeights(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerService_UpdateVariantWeights_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServiceServer).UpdateVariantWeights(ctx, req.(*UpdateVariantWeightsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -291,6 +539,74 @@ func _ShortenerService_PingDB_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShortenerService_ImportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ShortenerServiceServer).ImportURLs(&shortenerServiceImportURLsServer{stream})
+}
+
+type ShortenerService_ImportURLsServer interface {
+	SendAndClose(*ImportJob) error
+	Recv() (*ImportURLRecord, error)
+	grpc.ServerStream
+}
+
+type shortenerServiceImportURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *shortenerServiceImportURLsServer) SendAndClose(m *ImportJob) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *shortenerServiceImportURLsServer) Recv() (*ImportURLRecord, error) {
+	m := new(ImportURLRecord)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ShortenerService_ExportUserURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetUserURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShortenerServiceServer).ExportUserURLs(m, &shortenerServiceExportUserURLsServer{stream})
+}
+
+type ShortenerService_ExportUserURLsServer interface {
+	Send(*GetUserURLs) error
+	grpc.ServerStream
+}
+
+type shortenerServiceExportUserURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *shortenerServiceExportUserURLsServer) Send(m *GetUserURLs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ShortenerService_WatchClicks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShortenerServiceServer).WatchClicks(m, &shortenerServiceWatchClicksServer{stream})
+}
+
+type ShortenerService_WatchClicksServer interface {
+	Send(*ClickEvent) error
+	grpc.ServerStream
+}
+
+type shortenerServiceWatchClicksServer struct {
+	grpc.ServerStream
+}
+
+func (x *shortenerServiceWatchClicksServer) Send(m *ClickEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // ShortenerService_ServiceDesc is the grpc.ServiceDesc for ShortenerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -314,6 +630,22 @@ var ShortenerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserURLs",
 			Handler:    _ShortenerService_GetUserURLs_Handler,
 		},
+		{
+			MethodName: "GetLinkStats",
+			Handler:    _ShortenerService_GetLinkStats_Handler,
+		},
+		{
+			MethodName: "GetUserTags",
+			Handler:    _ShortenerService_GetUserTags_Handler,
+		},
+		{
+			MethodName: "UpdateTags",
+			Handler:    _ShortenerService_UpdateTags_Handler,
+		},
+		{
+			MethodName: "UpdateVariantWeights",
+			Handler:    _ShortenerService_UpdateVariantWeights_Handler,
+		},
 		{
 			MethodName: "DeleteURLs",
 			Handler:    _ShortenerService_DeleteURLs_Handler,
@@ -327,6 +659,22 @@ var ShortenerService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ShortenerService_PingDB_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportURLs",
+			Handler:       _ShortenerService_ImportURLs_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportUserURLs",
+			Handler:       _ShortenerService_ExportUserURLs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchClicks",
+			Handler:       _ShortenerService_WatchClicks_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "shortener.proto",
 }